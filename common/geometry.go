@@ -1,17 +1,109 @@
 package common
 
 import (
+	"math"
 	"meteo/data"
 )
 
-func Projection(long, lat float64, c Position, d Dimension, b data.Bounds) (x, y float64) {
-	x = ((long - b.MinLong) * d.Width / (b.MaxLong - b.MinLong)) - c.X
-	y = (d.Height - (lat-b.MinLat)*d.Height/(b.MaxLat-b.MinLat)) + c.Y
-	return x * c.Z, y * c.Z
+// frameSamples is the number of points sampled along each side of the bounds
+// to find their extent once projected, parallels being curved by conic
+// projections.
+const frameSamples = 16
+
+// Frame places a projected area in the map: the area fits in the dimension,
+// centred and keeping its aspect ratio. Frame pixels are the coordinates of
+// the map before the camera moves and scales it.
+type Frame struct {
+	Projection MapProjection
+	Dimension  Dimension
+	minX, maxY float64
+	scale      float64
+	offsetX    float64
+	offsetY    float64
+}
+
+func NewFrame(p MapProjection, d Dimension, b data.Bounds) Frame {
+	minX, minY, maxX, maxY := extent(b, p.Forward)
+	f := Frame{Projection: p, Dimension: d, minX: minX, maxY: maxY, scale: 1}
+	if maxX > minX && maxY > minY {
+		f.scale = math.Min(d.Width/(maxX-minX), d.Height/(maxY-minY))
+	}
+	f.offsetX = (d.Width - (maxX-minX)*f.scale) / 2
+	f.offsetY = (d.Height - (maxY-minY)*f.scale) / 2
+	return f
+}
+
+// extent transforms points sampled along the sides of the bounds and returns
+// the extent of the results.
+func extent(b data.Bounds, transform func(lon, lat float64) (float64, float64)) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	add := func(lon, lat float64) {
+		x, y := transform(lon, lat)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	for i := range frameSamples + 1 {
+		t := float64(i) / frameSamples
+		lon := b.MinLong + t*(b.MaxLong-b.MinLong)
+		lat := b.MinLat + t*(b.MaxLat-b.MinLat)
+		add(lon, b.MinLat)
+		add(lon, b.MaxLat)
+		add(b.MinLong, lat)
+		add(b.MaxLong, lat)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// ToPixel returns the frame pixel of a longitude and a latitude.
+func (f Frame) ToPixel(lon, lat float64) (float64, float64) {
+	x, y := f.Projection.Forward(lon, lat)
+	return f.offsetX + (x-f.minX)*f.scale, f.offsetY + (f.maxY-y)*f.scale
+}
+
+// FromPixel returns the longitude and the latitude of a frame pixel.
+func (f Frame) FromPixel(px, py float64) (float64, float64) {
+	return f.Projection.Inverse(f.minX+(px-f.offsetX)/f.scale, f.maxY-(py-f.offsetY)/f.scale)
+}
+
+func Projection(long, lat float64, c Position, f Frame) (x, y float64) {
+	x, y = f.ToPixel(long, lat)
+	return (x - c.X) * c.Z, (y + c.Y) * c.Z
+}
+
+func ProjectionFromXY(x, y float64, c Position, f Frame) (lon, lat float64) {
+	return f.FromPixel(x/c.Z+c.X, y/c.Z-c.Y)
+}
+
+// ZoomAt returns the camera of zoom z, the point (x, y) of the screen showing
+// the same place as with the camera c.
+func ZoomAt(c Position, x, y, z float64) Position {
+	return Position{
+		X: c.X + x/c.Z - x/z,
+		Y: c.Y + y/z - y/c.Z,
+		Z: z,
+	}
 }
 
-func ProjectionFromXY(x, y float64, c Position, d Dimension, b data.Bounds) (lon, lat float64) {
-	lon = b.MinLong + ((b.MaxLong - b.MinLong) / d.Width * (x/c.Z + c.X))
-	lat = b.MinLat + ((b.MaxLat - b.MinLat) / d.Height * (d.Height - (y/c.Z - c.Y)))
-	return lon, lat
+// FitBounds returns the camera centred on the area and showing all of it,
+// margin being the share of the screen left around the area.
+func FitBounds(area data.Bounds, f Frame, margin float64) Position {
+	x0, y0, x1, y1 := extent(area, f.ToPixel)
+
+	d := f.Dimension
+	z := math.Inf(1)
+	if x1 > x0 {
+		z = d.Width * (1 - margin) / (x1 - x0)
+	}
+	if y1 > y0 {
+		z = math.Min(z, d.Height*(1-margin)/(y1-y0))
+	}
+	if math.IsInf(z, 1) {
+		z = 1
+	}
+	return Position{
+		X: (x0+x1)/2 - d.Width/(2*z),
+		Y: d.Height/(2*z) - (y0+y1)/2,
+		Z: z,
+	}
 }
@@ -0,0 +1,150 @@
+package common
+
+import "math"
+
+// MapProjection turns longitudes and latitudes into coordinates of a plane,
+// x growing eastward and y northward.
+type MapProjection interface {
+	Name() string
+	Forward(lon, lat float64) (x, y float64)
+	Inverse(x, y float64) (lon, lat float64)
+}
+
+const (
+	// GRS80 ellipsoid, used by RGF93 and WGS84 alike at map scale
+	grs80SemiMajorAxis = 6378137.0
+	grs80Flattening    = 1 / 298.257222101
+)
+
+var (
+	// Lambert93 is the official projection of metropolitan France
+	// (EPSG:2154).
+	Lambert93 MapProjection = newLambertConformalConic("Lambert-93", 3, 46.5, 44, 49, 700000, 6600000)
+	// WebMercator is the projection of web map tiles (EPSG:3857).
+	WebMercator     MapProjection = webMercator{}
+	Equirectangular MapProjection = equirectangular{}
+
+	MapProjections = []MapProjection{Lambert93, WebMercator, Equirectangular}
+)
+
+// epsgProjections are the projections known by their EPSG code.
+var epsgProjections = map[int]MapProjection{
+	2154: Lambert93,
+	3857: WebMercator,
+	4326: Equirectangular,
+}
+
+// EPSGProjection returns the projection of an EPSG coordinate system.
+func EPSGProjection(code int) (MapProjection, bool) {
+	p, ok := epsgProjections[code]
+	return p, ok
+}
+
+func FindMapProjection(name string) (MapProjection, bool) {
+	for _, p := range MapProjections {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// equirectangular uses the longitudes and latitudes as plane coordinates.
+type equirectangular struct{}
+
+func (equirectangular) Name() string {
+	return "Équirectangulaire"
+}
+
+func (equirectangular) Forward(lon, lat float64) (float64, float64) {
+	return lon, lat
+}
+
+func (equirectangular) Inverse(x, y float64) (float64, float64) {
+	return x, y
+}
+
+// webMercator projects on the sphere of the semi-major axis.
+type webMercator struct{}
+
+func (webMercator) Name() string {
+	return "Web Mercator"
+}
+
+func (webMercator) Forward(lon, lat float64) (float64, float64) {
+	// the poles are sent to infinity
+	lat = math.Max(-85.05112878, math.Min(85.05112878, lat))
+	x := grs80SemiMajorAxis * lon * math.Pi / 180
+	y := grs80SemiMajorAxis * math.Log(math.Tan(math.Pi/4+lat*math.Pi/360))
+	return x, y
+}
+
+func (webMercator) Inverse(x, y float64) (float64, float64) {
+	lon := x / grs80SemiMajorAxis * 180 / math.Pi
+	lat := (2*math.Atan(math.Exp(y/grs80SemiMajorAxis)) - math.Pi/2) * 180 / math.Pi
+	return lon, lat
+}
+
+// lambertConformalConic is the Lambert conformal conic projection with two
+// standard parallels on the GRS80 ellipsoid.
+type lambertConformalConic struct {
+	name   string
+	e      float64
+	n      float64
+	aF     float64
+	rho0   float64
+	lon0   float64
+	x0, y0 float64
+}
+
+func newLambertConformalConic(name string, lon0, lat0, lat1, lat2, x0, y0 float64) *lambertConformalConic {
+	e := math.Sqrt(grs80Flattening * (2 - grs80Flattening))
+	l := &lambertConformalConic{name: name, e: e, lon0: lon0 * math.Pi / 180, x0: x0, y0: y0}
+	phi0, phi1, phi2 := lat0*math.Pi/180, lat1*math.Pi/180, lat2*math.Pi/180
+	m1, m2 := l.m(phi1), l.m(phi2)
+	t0, t1, t2 := l.t(phi0), l.t(phi1), l.t(phi2)
+	l.n = math.Log(m1/m2) / math.Log(t1/t2)
+	l.aF = grs80SemiMajorAxis * m1 / (l.n * math.Pow(t1, l.n))
+	l.rho0 = l.aF * math.Pow(t0, l.n)
+	return l
+}
+
+func (l *lambertConformalConic) m(phi float64) float64 {
+	s := l.e * math.Sin(phi)
+	return math.Cos(phi) / math.Sqrt(1-s*s)
+}
+
+func (l *lambertConformalConic) t(phi float64) float64 {
+	s := l.e * math.Sin(phi)
+	return math.Tan(math.Pi/4-phi/2) / math.Pow((1-s)/(1+s), l.e/2)
+}
+
+func (l *lambertConformalConic) Name() string {
+	return l.name
+}
+
+func (l *lambertConformalConic) Forward(lon, lat float64) (float64, float64) {
+	rho := l.aF * math.Pow(l.t(lat*math.Pi/180), l.n)
+	theta := l.n * (lon*math.Pi/180 - l.lon0)
+	return l.x0 + rho*math.Sin(theta), l.y0 + l.rho0 - rho*math.Cos(theta)
+}
+
+func (l *lambertConformalConic) Inverse(x, y float64) (float64, float64) {
+	dx, dy := x-l.x0, l.rho0-(y-l.y0)
+	rho := math.Copysign(math.Hypot(dx, dy), l.n)
+	theta := math.Atan2(dx, dy)
+	t := math.Pow(rho/l.aF, 1/l.n)
+
+	// the latitude is the fixed point of the isometric latitude equation
+	phi := math.Pi/2 - 2*math.Atan(t)
+	for range 10 {
+		s := l.e * math.Sin(phi)
+		next := math.Pi/2 - 2*math.Atan(t*math.Pow((1-s)/(1+s), l.e/2))
+		if math.Abs(next-phi) < 1e-12 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	return (theta/l.n + l.lon0) * 180 / math.Pi, phi * 180 / math.Pi
+}
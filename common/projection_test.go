@@ -0,0 +1,85 @@
+package common
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProjectionReferences(t *testing.T) {
+	// half the circumference of the equator of the sphere of Web Mercator
+	const halfWorld = 20037508.342789244
+	tests := []struct {
+		projection MapProjection
+		lon, lat   float64
+		x, y       float64
+	}{
+		// origin of EPSG:2154
+		{Lambert93, 3, 46.5, 700000, 6600000},
+		{WebMercator, 0, 0, 0, 0},
+		{WebMercator, 180, 0, halfWorld, 0},
+		{WebMercator, -180, 85.0511287798066, -halfWorld, halfWorld},
+		{Equirectangular, 2.35, 48.85, 2.35, 48.85},
+	}
+	for _, test := range tests {
+		x, y := test.projection.Forward(test.lon, test.lat)
+		if math.Abs(x-test.x) > 1e-3 || math.Abs(y-test.y) > 1e-3 {
+			t.Errorf("%s : Forward(%v, %v) = %.4f, %.4f, want %.4f, %.4f", test.projection.Name(), test.lon, test.lat, x, y, test.x, test.y)
+		}
+	}
+}
+
+// TestLambert93Scale checks that the lengths are kept along the standard
+// parallels, 44°N and 49°N, and that the central meridian is vertical.
+func TestLambert93Scale(t *testing.T) {
+	e2 := grs80Flattening * (2 - grs80Flattening)
+	for _, lat := range []float64{44, 49} {
+		phi := lat * math.Pi / 180
+		// length of a degree of the parallel on the ellipsoid
+		degree := grs80SemiMajorAxis * math.Cos(phi) / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi)) * math.Pi / 180
+		x0, y0 := Lambert93.Forward(2.995, lat)
+		x1, y1 := Lambert93.Forward(3.005, lat)
+		if scale := math.Hypot(x1-x0, y1-y0) / (0.01 * degree); math.Abs(scale-1) > 1e-6 {
+			t.Errorf("scale along %v°N = %v, want 1", lat, scale)
+		}
+	}
+	for _, lat := range []float64{42, 46.5, 51} {
+		if x, _ := Lambert93.Forward(3, lat); math.Abs(x-700000) > 1e-6 {
+			t.Errorf("Forward(3, %v) x = %v, want 700000", lat, x)
+		}
+	}
+}
+
+func TestProjectionRoundTrip(t *testing.T) {
+	for _, p := range MapProjections {
+		for lon := -5.5; lon <= 10; lon += 0.75 {
+			for lat := 41.0; lat <= 51.5; lat += 0.5 {
+				gotLon, gotLat := p.Inverse(p.Forward(lon, lat))
+				if math.Abs(gotLon-lon) > 1e-9 || math.Abs(gotLat-lat) > 1e-9 {
+					t.Fatalf("%s : Inverse(Forward(%v, %v)) = %v, %v", p.Name(), lon, lat, gotLon, gotLat)
+				}
+			}
+		}
+		for _, point := range [][2]float64{{0, 0}, {-170, -60}, {150, 70}} {
+			gotLon, gotLat := p.Inverse(p.Forward(point[0], point[1]))
+			if math.Abs(gotLon-point[0]) > 1e-9 || math.Abs(gotLat-point[1]) > 1e-9 {
+				t.Errorf("%s : Inverse(Forward(%v, %v)) = %v, %v", p.Name(), point[0], point[1], gotLon, gotLat)
+			}
+		}
+	}
+}
+
+func TestFindProjection(t *testing.T) {
+	for _, p := range MapProjections {
+		if found, ok := FindMapProjection(p.Name()); !ok || found != p {
+			t.Errorf("FindMapProjection(%q) = %v, %v", p.Name(), found, ok)
+		}
+	}
+	for code, want := range map[int]MapProjection{2154: Lambert93, 3857: WebMercator, 4326: Equirectangular} {
+		if p, ok := EPSGProjection(code); !ok || p != want {
+			t.Errorf("EPSGProjection(%d) = %v, %v, want %s", code, p, ok, want.Name())
+		}
+	}
+	if p, ok := EPSGProjection(27572); ok {
+		t.Errorf("EPSGProjection(27572) = %s, want none", p.Name())
+	}
+}
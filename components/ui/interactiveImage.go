@@ -1,73 +1,88 @@
 package ui
 
 import (
+	"context"
 	"image/color"
-	"meteo/common"
+	"math"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
-	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/widget"
 )
 
-type MapMode int
+// ZoomStep is the zoom factor of a zoom button or a mouse wheel notch.
+const ZoomStep = 1.25
+
+// scrollNotch is the scroll distance of a mouse wheel notch, trackpads sending
+// many smaller distances for a smooth zoom.
+const scrollNotch = 20.0
 
 const (
-	NORMAL MapMode = iota
-	MOVE
+	// dragThreshold is the distance in pixels the pointer moves before a press
+	// becomes a drag rather than a tap
+	dragThreshold = 4.0
+	// keyPanStep is the distance in pixels the map moves for an arrow key
+	keyPanStep = 50.0
+	// inertia keeps the map moving after a drag, its speed decaying by
+	// inertiaFriction every inertiaTick until it drops below inertiaMinSpeed
+	inertiaTick     = 16 * time.Millisecond
+	inertiaFriction = 0.9
+	inertiaMinSpeed = 0.05
 )
 
 type InteractiveMap struct {
 	widget.BaseWidget
-	Image                *canvas.Image
-	size                 fyne.Size
-	layers               []*canvas.Image
-	tooltip              *canvas.Text
-	hoverTimer           *time.Timer
-	OnTap                func(pos fyne.Position)
-	OnHover              func(pos fyne.Position) string
-	dragCursor           *canvas.Image
-	panCursor            *canvas.Image
-	mapMode              binding.Int
-	isDragging           bool
-	OnDrag               func(dx, dy float64)
-	previousDragPosition common.Position
-	isFirstDrag          bool
+	size            fyne.Size
+	layers          []*MapLayer
+	viewport        Viewport
+	OnLayersChanged func()
+	tooltip         *canvas.Text
+	hoverTimer      *time.Timer
+	hoverCancel     context.CancelFunc
+	OnTap           func(pos fyne.Position)
+	// OnZoom asks to multiply the zoom by factor, keeping pos still
+	OnZoom  func(factor float64, pos fyne.Position)
+	OnHover func(ctx context.Context, pos fyne.Position) string
+	// OnPan asks to move the map by (dx, dy) pixels
+	OnPan      func(dx, dy float64)
+	dragCursor *canvas.Image
+
+	// a press becomes a pan once the pointer moved by dragThreshold from
+	// dragStart
+	dragging  bool
+	panning   bool
+	dragStart fyne.Position
+	// velocity is the pan speed in pixels per millisecond
+	velocityX, velocityY float64
+	lastDrag             time.Time
+	stopInertia          chan struct{}
+
+	// settle draws the layers again once the camera stops
+	settle *time.Timer
 }
 
 var _ desktop.Hoverable = (*InteractiveMap)(nil)
 var _ desktop.Cursorable = (*InteractiveMap)(nil)
+var _ fyne.Scrollable = (*InteractiveMap)(nil)
+var _ fyne.Focusable = (*InteractiveMap)(nil)
 
-func NewInteractiveMap(
-	img *canvas.Image,
-	width, height float64,
-	mapMode binding.Int,
-) *InteractiveMap {
+func NewInteractiveMap(viewport Viewport) *InteractiveMap {
 	tooltip := canvas.NewText("", color.White)
 	tooltip.TextSize = 12
 	tooltip.Hidden = true
 
-	cursorSize := fyne.NewSize(16, 16)
 	dragCursor := canvas.NewImageFromResource(ResourceDragPng)
-	dragCursor.Resize(cursorSize)
+	dragCursor.Resize(fyne.NewSize(16, 16))
 	dragCursor.Hidden = true
 
-	panCursor := canvas.NewImageFromResource(ResourcePanPng)
-	panCursor.Resize(cursorSize)
-	panCursor.Hidden = true
-
 	m := &InteractiveMap{
-		Image:                img,
-		size:                 fyne.NewSize(float32(width), float32(height)),
-		tooltip:              tooltip,
-		isDragging:           false,
-		dragCursor:           dragCursor,
-		panCursor:            panCursor,
-		mapMode:              mapMode,
-		previousDragPosition: common.Position{X: 0, Y: 0, Z: 1},
-		isFirstDrag:          true,
+		size:       fyne.NewSize(float32(viewport.Dimension().Width), float32(viewport.Dimension().Height)),
+		viewport:   viewport,
+		tooltip:    tooltip,
+		dragCursor: dragCursor,
 	}
 	m.ExtendBaseWidget(m)
 
@@ -75,77 +90,129 @@ func NewInteractiveMap(
 }
 
 func (m *InteractiveMap) Dragged(e *fyne.DragEvent) {
-	mode, _ := m.mapMode.Get()
-	switch MapMode(mode) {
-	case NORMAL:
-		m.isDragging = false
-		m.dragCursor.Hidden = true
-		m.dragCursor.Refresh()
-		m.panCursor.Hidden = true
-		m.panCursor.Refresh()
-	case MOVE:
-		m.isDragging = true
-		offset := fyne.NewPos(-16, -16)
-		cursorPos := e.Position.Add(offset)
-		m.dragCursor.Move(cursorPos)
-		m.dragCursor.Hidden = false
-		m.dragCursor.Refresh()
-		m.panCursor.Hidden = true
-		m.panCursor.Refresh()
-
-		if m.OnDrag != nil {
-			if !m.isFirstDrag {
-				dx := float64(e.Position.X) - m.previousDragPosition.X
-				dy := float64(e.Position.Y) - m.previousDragPosition.Y
-				m.OnDrag(dx, dy)
-			}
-
-			m.isFirstDrag = false
-
-			m.previousDragPosition.X = float64(e.Position.X)
-			m.previousDragPosition.Y = float64(e.Position.Y)
+	if !m.dragging {
+		m.cancelInertia()
+		m.dragging = true
+		m.panning = false
+		m.dragStart = e.Position.Subtract(e.Dragged)
+	}
+	if !m.panning {
+		if PointDistance(e.Position, float64(m.dragStart.X), float64(m.dragStart.Y)) < dragThreshold {
+			return
 		}
-
+		// the pointer left the tap area: the map catches up with it
+		m.panning = true
+		m.velocityX, m.velocityY = 0, 0
+		m.lastDrag = time.Now()
+		m.hideTooltip()
+		m.pan(float64(e.Position.X-m.dragStart.X), float64(e.Position.Y-m.dragStart.Y))
+	} else {
+		dx, dy := float64(e.Dragged.DX), float64(e.Dragged.DY)
+		now := time.Now()
+		if elapsed := float64(now.Sub(m.lastDrag).Milliseconds()); elapsed > 0 {
+			// smoothed so that a last jerky event does not fling the map
+			m.velocityX = 0.5*m.velocityX + 0.5*dx/elapsed
+			m.velocityY = 0.5*m.velocityY + 0.5*dy/elapsed
+		}
+		m.lastDrag = now
+		m.pan(dx, dy)
 	}
 
-	// TODO: move camera here
+	m.dragCursor.Move(e.Position.Add(fyne.NewPos(-8, -8)))
+	m.dragCursor.Hidden = false
+	m.dragCursor.Refresh()
 }
 
+// DragEnd turns a press that barely moved into a tap, and lets the map glide
+// after a pan.
 func (m *InteractiveMap) DragEnd() {
-	m.isFirstDrag = true
-	m.isDragging = false
-	m.Refresh()
-}
-
-func (m *InteractiveMap) Cursor() desktop.Cursor {
-	mode, _ := m.mapMode.Get()
-	if MapMode(mode) == MOVE {
-		return desktop.HiddenCursor
+	m.dragging = false
+	m.dragCursor.Hidden = true
+	m.dragCursor.Refresh()
+	if !m.panning {
+		m.tap(m.dragStart)
+		return
+	}
+	m.panning = false
+	// a pause before releasing the pointer stops the map
+	if time.Since(m.lastDrag) < 100*time.Millisecond {
+		m.startInertia()
 	}
-	return desktop.DefaultCursor
 }
 
-func (m *InteractiveMap) AddLayer(img *canvas.Image) {
-	m.layers = append(m.layers, img)
-	m.Refresh()
+func (m *InteractiveMap) pan(dx, dy float64) {
+	if m.OnPan != nil && (dx != 0 || dy != 0) {
+		m.OnPan(dx, dy)
+	}
 }
 
-func (m *InteractiveMap) RemoveLayer(img *canvas.Image) {
-	layerIndex := -1
-	for i := range m.layers {
-		if m.layers[i] == img {
-			layerIndex = i
-			break
+func (m *InteractiveMap) startInertia() {
+	speed := math.Hypot(m.velocityX, m.velocityY)
+	if speed < inertiaMinSpeed || m.OnPan == nil {
+		return
+	}
+	ticker := time.NewTicker(inertiaTick)
+	stop := make(chan struct{})
+	m.stopInertia = stop
+	vx, vy := m.velocityX, m.velocityY
+	step := float64(inertiaTick.Milliseconds())
+
+	// the ticks add up their moves until the UI goroutine pans the map, so
+	// that a slow redraw gets one pending pan rather than a queue of them
+	var (
+		mu                 sync.Mutex
+		pendingX, pendingY float64
+		scheduled          bool
+	)
+	panPending := func() {
+		mu.Lock()
+		dx, dy := pendingX, pendingY
+		pendingX, pendingY, scheduled = 0, 0, false
+		mu.Unlock()
+		select {
+		case <-stop:
+			return
+		default:
 		}
+		m.pan(dx, dy)
 	}
 
-	if layerIndex != -1 {
-		// remove the layer
-		copy(m.layers[layerIndex:], m.layers[layerIndex+1:])
-		m.layers[len(m.layers)-1] = nil // avoid memory leak
-		m.layers = m.layers[:len(m.layers)-1]
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				vx, vy = vx*inertiaFriction, vy*inertiaFriction
+				mu.Lock()
+				pendingX, pendingY = pendingX+vx*step, pendingY+vy*step
+				schedule := !scheduled
+				scheduled = true
+				mu.Unlock()
+				if schedule {
+					fyne.Do(panPending)
+				}
+				if math.Hypot(vx, vy) < inertiaMinSpeed {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (m *InteractiveMap) cancelInertia() {
+	if m.stopInertia != nil {
+		close(m.stopInertia)
+		m.stopInertia = nil
 	}
+}
 
+func (m *InteractiveMap) Cursor() desktop.Cursor {
+	if m.panning {
+		return desktop.HiddenCursor
+	}
+	return desktop.DefaultCursor
 }
 
 func (m *InteractiveMap) MinSize() fyne.Size {
@@ -153,8 +220,59 @@ func (m *InteractiveMap) MinSize() fyne.Size {
 }
 
 func (m *InteractiveMap) Tapped(ev *fyne.PointEvent) {
+	m.tap(ev.Position)
+}
+
+// tap gives the keyboard focus to the map, so that arrows move it.
+func (m *InteractiveMap) tap(pos fyne.Position) {
+	m.cancelInertia()
+	if c := fyne.CurrentApp().Driver().CanvasForObject(m); c != nil {
+		c.Focus(m)
+	}
 	if m.OnTap != nil {
-		m.OnTap(ev.Position)
+		m.OnTap(pos)
+	}
+}
+
+func (m *InteractiveMap) Scrolled(ev *fyne.ScrollEvent) {
+	if m.OnZoom == nil || ev.Scrolled.DY == 0 {
+		return
+	}
+	m.cancelInertia()
+	m.OnZoom(math.Pow(ZoomStep, float64(ev.Scrolled.DY)/scrollNotch), ev.Position)
+}
+
+func (m *InteractiveMap) FocusGained() {}
+
+func (m *InteractiveMap) FocusLost() {}
+
+// TypedRune zooms around the centre of the map with + and -.
+func (m *InteractiveMap) TypedRune(r rune) {
+	if m.OnZoom == nil {
+		return
+	}
+	centre := fyne.NewPos(m.size.Width/2, m.size.Height/2)
+	switch r {
+	case '+':
+		m.OnZoom(ZoomStep, centre)
+	case '-':
+		m.OnZoom(1/ZoomStep, centre)
+	}
+}
+
+// TypedKey moves the map with the arrow keys, the map following the arrow
+// the way it follows the pointer.
+func (m *InteractiveMap) TypedKey(ev *fyne.KeyEvent) {
+	m.cancelInertia()
+	switch ev.Name {
+	case fyne.KeyLeft:
+		m.pan(keyPanStep, 0)
+	case fyne.KeyRight:
+		m.pan(-keyPanStep, 0)
+	case fyne.KeyUp:
+		m.pan(0, keyPanStep)
+	case fyne.KeyDown:
+		m.pan(0, -keyPanStep)
 	}
 }
 
@@ -163,47 +281,45 @@ func (m *InteractiveMap) MouseIn(ev *desktop.MouseEvent) {
 }
 
 func (m *InteractiveMap) MouseMoved(ev *desktop.MouseEvent) {
-	offset := fyne.NewPos(-16, -16)
-	cursorPos := ev.Position.Add(offset)
-	currentMapMode, _ := m.mapMode.Get()
-	if MapMode(currentMapMode) == MOVE {
-		if m.isDragging {
-			m.dragCursor.Move(cursorPos)
-			m.dragCursor.Hidden = false
-			m.dragCursor.Refresh()
-			m.panCursor.Hidden = true
-			m.panCursor.Refresh()
-		} else {
-			m.panCursor.Move(cursorPos)
-			m.panCursor.Hidden = false
-			m.panCursor.Refresh()
-			m.dragCursor.Hidden = true
-			m.dragCursor.Refresh()
-		}
-	} else {
+	if !m.panning {
 		m.updateTooltip(ev.Position)
 	}
 }
 
 func (m *InteractiveMap) MouseOut() {
-	m.dragCursor.Hidden = true
-	m.dragCursor.Refresh()
-	m.panCursor.Hidden = true
-	m.panCursor.Refresh()
+	m.hideTooltip()
+}
+
+func (m *InteractiveMap) hideTooltip() {
 	m.tooltip.Hidden = true
 	m.tooltip.Refresh()
+	m.cancelHover()
+}
+
+func (m *InteractiveMap) cancelHover() {
+	if m.hoverTimer != nil {
+		m.hoverTimer.Stop()
+	}
+	if m.hoverCancel != nil {
+		m.hoverCancel()
+	}
 }
 
+// updateTooltip looks up the tooltip in the background once the mouse rests
+// on the map; a lookup still running when the mouse moves again is cancelled.
 func (m *InteractiveMap) updateTooltip(pos fyne.Position) {
 	if m.OnHover == nil {
 		return
 	}
-	if m.hoverTimer != nil {
-		m.hoverTimer.Stop()
-	}
+	m.cancelHover()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.hoverCancel = cancel
 	m.hoverTimer = time.AfterFunc(200*time.Millisecond, func() {
+		text := m.OnHover(ctx, pos)
 		fyne.Do(func() {
-			text := m.OnHover(pos)
+			if ctx.Err() != nil {
+				return
+			}
 			if text == "" {
 				m.tooltip.Hidden = true
 				m.tooltip.Refresh()
@@ -227,11 +343,9 @@ func (m *InteractiveMap) CreateRenderer() fyne.WidgetRenderer {
 }
 
 func (r *mapRenderer) Layout(size fyne.Size) {
-	r.m.Image.Resize(size)
-	r.m.Image.Move(fyne.NewPos(0, 0))
 	for _, l := range r.m.layers {
-		l.Resize(size)
-		l.Move(fyne.NewPos(0, 0))
+		l.Image.Resize(size)
+		l.Image.Move(fyne.NewPos(0, 0))
 	}
 }
 
@@ -240,21 +354,21 @@ func (r *mapRenderer) MinSize() fyne.Size {
 }
 
 func (r *mapRenderer) Refresh() {
-	r.m.Image.Refresh()
+	// layers may have been added or given a new image since the last layout
+	r.Layout(r.m.Size())
 	for _, l := range r.m.layers {
-		l.Refresh()
+		l.Image.Refresh()
 	}
 	r.m.tooltip.Refresh()
 	r.m.dragCursor.Refresh()
-	r.m.panCursor.Refresh()
 }
 
 func (r *mapRenderer) Objects() []fyne.CanvasObject {
-	objs := []fyne.CanvasObject{r.m.Image}
+	objs := make([]fyne.CanvasObject, 0, len(r.m.layers)+2)
 	for _, l := range r.m.layers {
-		objs = append(objs, l)
+		objs = append(objs, l.Image)
 	}
-	objs = append(objs, r.m.tooltip, r.m.dragCursor, r.m.panCursor)
+	objs = append(objs, r.m.tooltip, r.m.dragCursor)
 	return objs
 }
 
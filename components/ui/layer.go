@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"meteo/common"
+
+	"fyne.io/fyne/v2"
+	"github.com/fogleman/gg"
+)
+
+// Viewport is the part of the map shown on screen.
+type Viewport struct {
+	Camera common.Position
+	Frame  common.Frame
+}
+
+func (v Viewport) Dimension() common.Dimension {
+	return v.Frame.Dimension
+}
+
+// Project turns a longitude and a latitude into a pixel of the map.
+func (v Viewport) Project(lon, lat float64) (float64, float64) {
+	return common.Projection(lon, lat, v.Camera, v.Frame)
+}
+
+// Unproject turns a pixel of the map into a longitude and a latitude.
+func (v Viewport) Unproject(x, y float64) (float64, float64) {
+	return common.ProjectionFromXY(x, y, v.Camera, v.Frame)
+}
+
+// Layer is a set of features drawn on the map. Features are identified by
+// their index in the layer.
+type Layer interface {
+	Draw(dc *gg.Context, v Viewport)
+	// HitTest returns the feature drawn at the position, the one drawn last
+	// when several overlap.
+	HitTest(v Viewport, pos fyne.Position) (int, bool)
+	Tooltip(feature int) string
+}
+
+// HitTolerance is the distance in pixels within which lines and points are
+// considered under the cursor.
+const HitTolerance = 4.0
+
+// PointDistance is the distance in pixels between a position and a point.
+func PointDistance(pos fyne.Position, x, y float64) float64 {
+	return math.Hypot(float64(pos.X)-x, float64(pos.Y)-y)
+}
+
+// SegmentDistance is the distance in pixels between a position and the
+// segment going from (x0, y0) to (x1, y1).
+func SegmentDistance(pos fyne.Position, x0, y0, x1, y1 float64) float64 {
+	dx, dy := x1-x0, y1-y0
+	length := dx*dx + dy*dy
+	if length == 0 {
+		return PointDistance(pos, x0, y0)
+	}
+	t := ((float64(pos.X)-x0)*dx + (float64(pos.Y)-y0)*dy) / length
+	t = math.Max(0, math.Min(1, t))
+	return PointDistance(pos, x0+t*dx, y0+t*dy)
+}
+
+func renderLayer(l Layer, v Viewport) image.Image {
+	dc := gg.NewContext(int(v.Dimension().Width), int(v.Dimension().Height))
+	l.Draw(dc, v)
+	return dc.Image()
+}
+
+// moveRaster returns the raster drawn for the viewport from, moved and scaled
+// so that it shows the same places in the viewport to. The parts of the
+// viewport it doesn't cover are transparent. Both viewports share their frame.
+func moveRaster(raster image.Image, from, to Viewport) image.Image {
+	w, h := int(to.Dimension().Width), int(to.Dimension().Height)
+	// a pixel of the screen is (frame pixel - camera) * zoom, the camera
+	// going up along Y
+	scale := to.Camera.Z / from.Camera.Z
+	dx := (from.Camera.X - to.Camera.X) * to.Camera.Z
+	dy := (to.Camera.Y - from.Camera.Y) * to.Camera.Z
+	if scale == 1 {
+		moved := image.NewRGBA(image.Rect(0, 0, w, h))
+		offset := image.Pt(int(math.Round(dx)), int(math.Round(dy)))
+		draw.Draw(moved, raster.Bounds().Add(offset), raster, raster.Bounds().Min, draw.Src)
+		return moved
+	}
+	dc := gg.NewContext(w, h)
+	dc.Translate(dx, dy)
+	dc.Scale(scale, scale)
+	dc.DrawImage(raster, 0, 0)
+	return dc.Image()
+}
@@ -0,0 +1,232 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+)
+
+// settleDelay is the time the camera stays still before the layers are drawn
+// again for the new viewport; until then they follow it by moving their last
+// drawing.
+const settleDelay = 150 * time.Millisecond
+
+type LegendEntry struct {
+	Color color.Color
+	Label string
+}
+
+// Legend describes the symbols of a layer.
+type Legend struct {
+	Title   string
+	Entries []LegendEntry
+	Note    string
+}
+
+// MapLayer is a named layer of the map, rasterized for the current viewport.
+// Layers are drawn from the first to the last one.
+type MapLayer struct {
+	ID      string
+	Name    string
+	Layer   Layer
+	Image   *canvas.Image
+	Visible bool
+	// Opacity goes from 0 (transparent) to 1
+	Opacity float64
+	Legend  *Legend
+	// raster is the drawing of the layer for the viewport drawn
+	raster image.Image
+	drawn  Viewport
+}
+
+// draw draws the layer for the viewport.
+func (l *MapLayer) draw(v Viewport) {
+	l.raster, l.drawn = renderLayer(l.Layer, v), v
+	l.show(l.raster)
+}
+
+func (l *MapLayer) show(img image.Image) {
+	if l.Image == nil {
+		l.Image = canvas.NewImageFromImage(img)
+		l.Image.FillMode = canvas.ImageFillContain
+	} else {
+		l.Image.Image = img
+	}
+	l.apply()
+}
+
+func (l *MapLayer) apply() {
+	if l.Image == nil {
+		return
+	}
+	l.Image.Hidden = !l.Visible
+	l.Image.Translucency = 1 - l.Opacity
+}
+
+// Layers returns the layers from the bottom to the top one.
+func (m *InteractiveMap) Layers() []*MapLayer {
+	return m.layers
+}
+
+func (m *InteractiveMap) Layer(id string) (*MapLayer, bool) {
+	i := m.layerIndex(id)
+	if i < 0 {
+		return nil, false
+	}
+	return m.layers[i], true
+}
+
+func (m *InteractiveMap) layerIndex(id string) int {
+	return slices.IndexFunc(m.layers, func(l *MapLayer) bool { return l.ID == id })
+}
+
+// Viewport is the part of the map currently shown.
+func (m *InteractiveMap) Viewport() Viewport {
+	return m.viewport
+}
+
+// SetViewport changes the part of the map shown. The visible layers follow
+// the camera by moving their last drawing, and are drawn again once the camera
+// stayed still for settleDelay.
+func (m *InteractiveMap) SetViewport(v Viewport) {
+	m.viewport = v
+	for _, layer := range m.layers {
+		if !layer.Visible {
+			continue
+		}
+		if layer.drawn.Frame != v.Frame {
+			// another projection or size: the drawing can't be moved
+			layer.draw(v)
+		} else {
+			layer.show(moveRaster(layer.raster, layer.drawn, v))
+		}
+	}
+	m.Refresh()
+
+	if m.settle != nil {
+		m.settle.Stop()
+	}
+	m.settle = time.AfterFunc(settleDelay, func() {
+		fyne.Do(m.drawMoved)
+	})
+}
+
+// drawMoved draws the visible layers drawn for another viewport again.
+func (m *InteractiveMap) drawMoved() {
+	moved := false
+	for _, layer := range m.layers {
+		if layer.Visible && layer.drawn != m.viewport {
+			layer.draw(m.viewport)
+			moved = true
+		}
+	}
+	if moved {
+		m.Refresh()
+	}
+}
+
+// Redraw draws every layer again, after the features they hold changed.
+func (m *InteractiveMap) Redraw() {
+	for _, layer := range m.layers {
+		layer.draw(m.viewport)
+	}
+	m.Refresh()
+}
+
+// SetLayer replaces the name and the features of a layer, keeping its
+// position, visibility and opacity. Unknown layers are added on top of the
+// others.
+func (m *InteractiveMap) SetLayer(id, name string, l Layer) *MapLayer {
+	return m.SetLayerBelow(id, name, l, "")
+}
+
+// SetLayerBelow is SetLayer, unknown layers being added right below the
+// layer below, or on top when there is no such layer.
+func (m *InteractiveMap) SetLayerBelow(id, name string, l Layer, below string) *MapLayer {
+	if layer, ok := m.Layer(id); ok {
+		layer.Name = name
+		layer.Layer = l
+		layer.draw(m.viewport)
+		m.Refresh()
+		return layer
+	}
+	layer := &MapLayer{ID: id, Name: name, Layer: l, Visible: true, Opacity: 1}
+	layer.draw(m.viewport)
+	if i := m.layerIndex(below); i >= 0 {
+		m.layers = slices.Insert(m.layers, i, layer)
+	} else {
+		m.layers = append(m.layers, layer)
+	}
+	m.layersChanged()
+	return layer
+}
+
+func (m *InteractiveMap) RemoveLayer(id string) {
+	i := m.layerIndex(id)
+	if i < 0 {
+		return
+	}
+	m.layers = slices.Delete(m.layers, i, i+1)
+	m.layersChanged()
+}
+
+func (m *InteractiveMap) SetLayerVisible(id string, visible bool) {
+	if layer, ok := m.Layer(id); ok && layer.Visible != visible {
+		layer.Visible = visible
+		// hidden layers don't follow the camera
+		if visible && layer.drawn != m.viewport {
+			layer.draw(m.viewport)
+		}
+		layer.apply()
+		m.layersChanged()
+	}
+}
+
+func (m *InteractiveMap) SetLayerOpacity(id string, opacity float64) {
+	if layer, ok := m.Layer(id); ok {
+		layer.Opacity = max(0, min(1, opacity))
+		layer.apply()
+		m.Refresh()
+	}
+}
+
+// MoveLayer moves a layer up (delta > 0) or down in the drawing order.
+func (m *InteractiveMap) MoveLayer(id string, delta int) {
+	i := m.layerIndex(id)
+	if i < 0 {
+		return
+	}
+	j := max(0, min(len(m.layers)-1, i+delta))
+	if i == j {
+		return
+	}
+	layer := m.layers[i]
+	m.layers = slices.Insert(slices.Delete(m.layers, i, i+1), j, layer)
+	m.layersChanged()
+}
+
+// FeatureAt returns the feature under the position, looking through the
+// visible layers from the top to the bottom one.
+func (m *InteractiveMap) FeatureAt(pos fyne.Position) (*MapLayer, int, bool) {
+	for i := len(m.layers) - 1; i >= 0; i-- {
+		layer := m.layers[i]
+		if !layer.Visible {
+			continue
+		}
+		if feature, ok := layer.Layer.HitTest(m.viewport, pos); ok {
+			return layer, feature, true
+		}
+	}
+	return nil, 0, false
+}
+
+func (m *InteractiveMap) layersChanged() {
+	m.Refresh()
+	if m.OnLayersChanged != nil {
+		m.OnLayersChanged()
+	}
+}
@@ -0,0 +1,290 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/fogleman/gg"
+)
+
+type ScatterPoint struct {
+	X, Y  float64
+	Label string
+}
+
+// ScatterChart draws points with an optional regression line. Hovering a
+// point shows its label and tapping it calls OnPointTapped.
+type ScatterChart struct {
+	widget.BaseWidget
+	XLabel        string
+	YLabel        string
+	OnPointTapped func(index int)
+
+	points  []ScatterPoint
+	line    func(x float64) float64
+	raster  *canvas.Raster
+	tooltip *canvas.Text
+	hovered int
+}
+
+var _ desktop.Hoverable = (*ScatterChart)(nil)
+var _ fyne.Tappable = (*ScatterChart)(nil)
+
+const (
+	scatterMarginLeft   = 60
+	scatterMarginRight  = 20
+	scatterMarginTop    = 20
+	scatterMarginBottom = 45
+	scatterTicks        = 6
+	scatterPickRadius   = 8
+)
+
+func NewScatterChart(xLabel, yLabel string) *ScatterChart {
+	c := &ScatterChart{
+		XLabel:  xLabel,
+		YLabel:  yLabel,
+		hovered: -1,
+	}
+	c.raster = canvas.NewRaster(c.draw)
+	c.tooltip = canvas.NewText("", theme.Color(theme.ColorNameForeground))
+	c.tooltip.TextSize = 12
+	c.tooltip.Hidden = true
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+// SetData replaces the points and the fitted line, which may be nil.
+func (c *ScatterChart) SetData(points []ScatterPoint, line func(x float64) float64) {
+	c.points = points
+	c.line = line
+	c.hovered = -1
+	c.tooltip.Hidden = true
+	c.Refresh()
+}
+
+func (c *ScatterChart) MinSize() fyne.Size {
+	return fyne.NewSize(300, 250)
+}
+
+// scatterFrame maps data coordinates to the plot area of a drawing of the
+// given size; scale converts widget units to drawing units.
+type scatterFrame struct {
+	minX, maxX, minY, maxY   float64
+	left, top, right, bottom float64
+}
+
+func (c *ScatterChart) frame(width, height, scale float64) scatterFrame {
+	f := scatterFrame{
+		minX: math.Inf(1), maxX: math.Inf(-1),
+		minY: math.Inf(1), maxY: math.Inf(-1),
+		left:   scatterMarginLeft * scale,
+		top:    scatterMarginTop * scale,
+		right:  width - scatterMarginRight*scale,
+		bottom: height - scatterMarginBottom*scale,
+	}
+	for _, p := range c.points {
+		f.minX, f.maxX = math.Min(f.minX, p.X), math.Max(f.maxX, p.X)
+		f.minY, f.maxY = math.Min(f.minY, p.Y), math.Max(f.maxY, p.Y)
+	}
+	if len(c.points) == 0 {
+		f.minX, f.maxX, f.minY, f.maxY = 0, 1, 0, 1
+	}
+	f.minX, f.maxX = niceRange(f.minX, f.maxX)
+	f.minY, f.maxY = niceRange(f.minY, f.maxY)
+	return f
+}
+
+func (f scatterFrame) project(x, y float64) (float64, float64) {
+	px := f.left + (x-f.minX)/(f.maxX-f.minX)*(f.right-f.left)
+	py := f.bottom - (y-f.minY)/(f.maxY-f.minY)*(f.bottom-f.top)
+	return px, py
+}
+
+func (c *ScatterChart) draw(width, height int) image.Image {
+	size := c.Size()
+	scale := 1.0
+	if size.Width > 0 {
+		scale = float64(width) / float64(size.Width)
+	}
+	f := c.frame(float64(width), float64(height), scale)
+
+	foreground := theme.Color(theme.ColorNameForeground)
+	dc := gg.NewContext(width, height)
+	dc.SetColor(theme.Color(theme.ColorNameBackground))
+	dc.Clear()
+
+	// grid and ticks
+	dc.SetLineWidth(1)
+	for _, x := range ticks(f.minX, f.maxX) {
+		px, _ := f.project(x, f.minY)
+		dc.SetColor(theme.Color(theme.ColorNameSeparator))
+		dc.DrawLine(px, f.top, px, f.bottom)
+		dc.Stroke()
+		dc.SetColor(foreground)
+		dc.DrawStringAnchored(formatTick(x), px, f.bottom+6*scale, 0.5, 1)
+	}
+	for _, y := range ticks(f.minY, f.maxY) {
+		_, py := f.project(f.minX, y)
+		dc.SetColor(theme.Color(theme.ColorNameSeparator))
+		dc.DrawLine(f.left, py, f.right, py)
+		dc.Stroke()
+		dc.SetColor(foreground)
+		dc.DrawStringAnchored(formatTick(y), f.left-6*scale, py, 1, 0.5)
+	}
+	dc.SetColor(foreground)
+	dc.DrawRectangle(f.left, f.top, f.right-f.left, f.bottom-f.top)
+	dc.Stroke()
+	dc.DrawStringAnchored(c.XLabel, (f.left+f.right)/2, float64(height)-4*scale, 0.5, 0)
+	dc.Push()
+	dc.RotateAbout(-math.Pi/2, 12*scale, (f.top+f.bottom)/2)
+	dc.DrawStringAnchored(c.YLabel, 12*scale, (f.top+f.bottom)/2, 0.5, 0.5)
+	dc.Pop()
+
+	if c.line != nil && len(c.points) > 0 {
+		x1, y1 := f.project(f.minX, c.line(f.minX))
+		x2, y2 := f.project(f.maxX, c.line(f.maxX))
+		dc.Push()
+		dc.DrawRectangle(f.left, f.top, f.right-f.left, f.bottom-f.top)
+		dc.Clip()
+		dc.SetColor(theme.Color(theme.ColorNameError))
+		dc.SetLineWidth(2 * scale)
+		dc.DrawLine(x1, y1, x2, y2)
+		dc.Stroke()
+		dc.Pop()
+	}
+
+	primary := theme.Color(theme.ColorNamePrimary)
+	for i, p := range c.points {
+		px, py := f.project(p.X, p.Y)
+		radius := 3.5 * scale
+		if i == c.hovered {
+			radius *= 1.8
+		}
+		dc.SetColor(primary)
+		dc.DrawCircle(px, py, radius)
+		dc.Fill()
+	}
+	return dc.Image()
+}
+
+// pointAt returns the index of the point under pos, or -1.
+func (c *ScatterChart) pointAt(pos fyne.Position) int {
+	size := c.Size()
+	f := c.frame(float64(size.Width), float64(size.Height), 1)
+	closest, best := -1, float64(scatterPickRadius*scatterPickRadius)
+	for i, p := range c.points {
+		px, py := f.project(p.X, p.Y)
+		dx, dy := px-float64(pos.X), py-float64(pos.Y)
+		if d := dx*dx + dy*dy; d <= best {
+			closest, best = i, d
+		}
+	}
+	return closest
+}
+
+func (c *ScatterChart) Tapped(ev *fyne.PointEvent) {
+	if i := c.pointAt(ev.Position); i >= 0 && c.OnPointTapped != nil {
+		c.OnPointTapped(i)
+	}
+}
+
+func (c *ScatterChart) MouseIn(ev *desktop.MouseEvent) {
+	c.MouseMoved(ev)
+}
+
+func (c *ScatterChart) MouseMoved(ev *desktop.MouseEvent) {
+	i := c.pointAt(ev.Position)
+	if i == c.hovered {
+		return
+	}
+	c.hovered = i
+	if i < 0 {
+		c.tooltip.Hidden = true
+	} else {
+		p := c.points[i]
+		c.tooltip.Text = p.Label
+		c.tooltip.Hidden = false
+		c.tooltip.Resize(c.tooltip.MinSize())
+		c.tooltip.Move(fyne.NewPos(ev.Position.X+10, ev.Position.Y-20))
+	}
+	c.tooltip.Refresh()
+	c.raster.Refresh()
+}
+
+func (c *ScatterChart) MouseOut() {
+	if c.hovered < 0 {
+		return
+	}
+	c.hovered = -1
+	c.tooltip.Hidden = true
+	c.tooltip.Refresh()
+	c.raster.Refresh()
+}
+
+func (c *ScatterChart) CreateRenderer() fyne.WidgetRenderer {
+	return &scatterRenderer{c: c}
+}
+
+type scatterRenderer struct {
+	c *ScatterChart
+}
+
+func (r *scatterRenderer) Layout(size fyne.Size) {
+	r.c.raster.Resize(size)
+	r.c.raster.Move(fyne.NewPos(0, 0))
+	r.c.tooltip.Resize(r.c.tooltip.MinSize())
+}
+
+func (r *scatterRenderer) MinSize() fyne.Size {
+	return r.c.MinSize()
+}
+
+func (r *scatterRenderer) Refresh() {
+	r.c.tooltip.Color = theme.Color(theme.ColorNameForeground)
+	r.c.raster.Refresh()
+	r.c.tooltip.Refresh()
+}
+
+func (r *scatterRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.c.raster, r.c.tooltip}
+}
+
+func (r *scatterRenderer) Destroy() {}
+
+// niceRange pads the range so that points do not sit on the frame.
+func niceRange(lo, hi float64) (float64, float64) {
+	if hi-lo < 1e-9 {
+		return lo - 1, hi + 1
+	}
+	pad := (hi - lo) * 0.05
+	return lo - pad, hi + pad
+}
+
+// ticks returns round values within [lo, hi].
+func ticks(lo, hi float64) []float64 {
+	step := math.Pow(10, math.Floor(math.Log10((hi-lo)/scatterTicks)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if (hi-lo)/(step*m) <= scatterTicks {
+			step *= m
+			break
+		}
+	}
+	values := make([]float64, 0, scatterTicks+1)
+	for v := math.Ceil(lo/step) * step; v <= hi; v += step {
+		values = append(values, v)
+	}
+	return values
+}
+
+func formatTick(v float64) string {
+	if math.Abs(v) >= 100 || v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
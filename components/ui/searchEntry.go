@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// SearchEntry is a single line entry forwarding the navigation keys, so that
+// a list of results can be browsed without leaving the keyboard.
+type SearchEntry struct {
+	widget.Entry
+	// OnNavigate is called with -1 or 1 when the up or down arrow is pressed,
+	// and with -10 or 10 for page up and page down.
+	OnNavigate func(delta int)
+	OnEscape   func()
+}
+
+func NewSearchEntry() *SearchEntry {
+	e := &SearchEntry{}
+	e.ExtendBaseWidget(e)
+	return e
+}
+
+func (e *SearchEntry) TypedKey(key *fyne.KeyEvent) {
+	switch key.Name {
+	case fyne.KeyUp:
+		e.navigate(-1)
+	case fyne.KeyDown:
+		e.navigate(1)
+	case fyne.KeyPageUp:
+		e.navigate(-10)
+	case fyne.KeyPageDown:
+		e.navigate(10)
+	case fyne.KeyEscape:
+		if e.OnEscape != nil {
+			e.OnEscape()
+		}
+	default:
+		e.Entry.TypedKey(key)
+	}
+}
+
+func (e *SearchEntry) navigate(delta int) {
+	if e.OnNavigate != nil {
+		e.OnNavigate(delta)
+	}
+}
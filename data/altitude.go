@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"math"
+)
+
+// minMeanPeriods is the number of complete periods a station needs for its
+// mean value to be computed.
+const minMeanPeriods = 10
+
+// AltitudePoint is the mean value of a variable at a station.
+type AltitudePoint struct {
+	Station *StationInfo
+	Value   float64
+	Periods int
+}
+
+// LinearFit is an ordinary least squares regression y = Slope*x + Intercept.
+type LinearFit struct {
+	Slope     float64
+	Intercept float64
+	R2        float64
+	N         int
+}
+
+func (f LinearFit) At(x float64) float64 {
+	return f.Slope*x + f.Intercept
+}
+
+// GetAltitudeProfile averages the variable over every complete period of the
+// given stations. Stations with fewer than minMeanPeriods periods and
+// aggregated stations are left out.
+func GetAltitudeProfile(ctx context.Context, db *sql.DB, stations []StationInfo, variable Variable, period Period) ([]AltitudePoint, error) {
+	ids := make([]string, 0, len(stations))
+	for _, s := range stations {
+		if !s.IsAggregate() {
+			ids = append(ids, s.NumPost)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, ErrNotEnoughData
+	}
+
+	series, err := queryPeriodSeries(ctx, db, ids, variable, period)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]AltitudePoint, 0, len(series))
+	for i := range stations {
+		points := series[stations[i].NumPost]
+		if len(points) < minMeanPeriods || stations[i].IsAggregate() {
+			continue
+		}
+		response = append(response, AltitudePoint{
+			Station: &stations[i],
+			Value:   MeanValue(points),
+			Periods: len(points),
+		})
+	}
+	return response, nil
+}
+
+// FitAltitude regresses the values of the profile on the altitude.
+func FitAltitude(profile []AltitudePoint) (*LinearFit, error) {
+	xs := make([]float64, 0, len(profile))
+	ys := make([]float64, 0, len(profile))
+	for _, p := range profile {
+		xs = append(xs, p.Station.Alti)
+		ys = append(ys, p.Value)
+	}
+	return FitLinear(xs, ys)
+}
+
+func FitLinear(xs, ys []float64) (*LinearFit, error) {
+	n := min(len(xs), len(ys))
+	if n < 3 {
+		return nil, ErrNotEnoughData
+	}
+
+	var meanX, meanY float64
+	for i := range n {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var sxx, sxy, syy float64
+	for i := range n {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return nil, ErrNotEnoughData
+	}
+
+	fit := &LinearFit{
+		Slope: sxy / sxx,
+		N:     n,
+	}
+	fit.Intercept = meanY - fit.Slope*meanX
+	if syy > 0 {
+		fit.R2 = math.Min(1, sxy*sxy/(sxx*syy))
+	} else {
+		fit.R2 = 1
+	}
+	return fit, nil
+}
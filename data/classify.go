@@ -0,0 +1,139 @@
+package data
+
+import (
+	"math"
+	"slices"
+)
+
+type ClassificationMethod int
+
+const (
+	Quantiles ClassificationMethod = iota
+	EqualIntervals
+	NaturalBreaks
+)
+
+var ClassificationMethods = []ClassificationMethod{Quantiles, EqualIntervals, NaturalBreaks}
+
+func (m ClassificationMethod) String() string {
+	switch m {
+	case EqualIntervals:
+		return "Intervalles égaux"
+	case NaturalBreaks:
+		return "Seuils naturels (Jenks)"
+	default:
+		return "Quantiles"
+	}
+}
+
+// maxJenksValues bounds the cost of the natural breaks optimisation, which is
+// quadratic in the number of values: larger samples are thinned evenly.
+const maxJenksValues = 2000
+
+// Classify splits the values into at most classes classes and returns the
+// class boundaries, from the minimum to the maximum value. NaN values are
+// ignored; there may be fewer classes than asked when values repeat.
+func Classify(values []float64, classes int, method ClassificationMethod) []float64 {
+	sorted := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 || classes < 1 {
+		return nil
+	}
+	slices.Sort(sorted)
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	if lo == hi {
+		return []float64{lo, hi}
+	}
+
+	var breaks []float64
+	switch method {
+	case EqualIntervals:
+		breaks = make([]float64, classes+1)
+		for i := range breaks {
+			breaks[i] = lo + (hi-lo)*float64(i)/float64(classes)
+		}
+	case NaturalBreaks:
+		breaks = jenksBreaks(sorted, classes)
+	default:
+		breaks = make([]float64, classes+1)
+		for i := range breaks {
+			breaks[i] = percentile(sorted, float64(i)/float64(classes))
+		}
+	}
+	breaks[0], breaks[len(breaks)-1] = lo, hi
+	return slices.Compact(breaks)
+}
+
+// ClassOf returns the index of the class of v given the class boundaries; the
+// upper boundary of a class belongs to it.
+func ClassOf(breaks []float64, v float64) int {
+	if len(breaks) < 2 {
+		return 0
+	}
+	i, _ := slices.BinarySearch(breaks[1:len(breaks)-1], v)
+	return i
+}
+
+// jenksBreaks runs the Fisher-Jenks optimisation, which minimises the sum of
+// the squared deviations from the class means, on sorted values.
+func jenksBreaks(sorted []float64, classes int) []float64 {
+	if len(sorted) > maxJenksValues {
+		sample := make([]float64, maxJenksValues)
+		for i := range sample {
+			sample[i] = sorted[i*(len(sorted)-1)/(maxJenksValues-1)]
+		}
+		sorted = sample
+	}
+	n := len(sorted)
+	classes = min(classes, n)
+
+	// prefix sums give the variance of any run of values in constant time
+	sum := make([]float64, n+1)
+	sumSquares := make([]float64, n+1)
+	for i, v := range sorted {
+		sum[i+1] = sum[i] + v
+		sumSquares[i+1] = sumSquares[i] + v*v
+	}
+	cost := func(from, to int) float64 {
+		count := float64(to - from)
+		s := sum[to] - sum[from]
+		return sumSquares[to] - sumSquares[from] - s*s/count
+	}
+
+	// best[k][i] is the lowest cost of splitting the first i values into k+1
+	// classes and start[k][i] the first value of the last of those classes
+	best := make([][]float64, classes)
+	start := make([][]int, classes)
+	for k := range classes {
+		best[k] = make([]float64, n+1)
+		start[k] = make([]int, n+1)
+	}
+	for i := 1; i <= n; i++ {
+		best[0][i] = cost(0, i)
+	}
+	for k := 1; k < classes; k++ {
+		for i := k + 1; i <= n; i++ {
+			best[k][i] = math.Inf(1)
+			for j := k; j < i; j++ {
+				if c := best[k-1][j] + cost(j, i); c < best[k][i] {
+					best[k][i] = c
+					start[k][i] = j
+				}
+			}
+		}
+	}
+
+	breaks := make([]float64, classes+1)
+	breaks[classes] = sorted[n-1]
+	end := n
+	for k := classes - 1; k > 0; k-- {
+		end = start[k][end]
+		breaks[k] = sorted[end-1]
+	}
+	breaks[0] = sorted[0]
+	return breaks
+}
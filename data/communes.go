@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// communesDir holds the communes of the imported departments, one JSON file
+// per department.
+const communesDir = "data/communes"
+
+// Communes holds the names of the communes by INSEE code. Corsican codes
+// start with 20, as in NUM_POSTE, so that one code may name a commune of both
+// Corsican departments.
+type Communes map[string][]string
+
+type geoCommune struct {
+	Name string `json:"nom"`
+	Code string `json:"code"`
+}
+
+// Of returns the names of the commune of a station.
+func (c Communes) Of(numPost string) []string {
+	if len(numPost) == 7 {
+		numPost = "0" + numPost
+	}
+	if len(numPost) < 5 {
+		return nil
+	}
+	return c[numPost[:5]]
+}
+
+// DownloadCommunes saves the communes of a department from the API of the
+// official geographic data, 20 standing for both Corsican departments.
+func DownloadCommunes(ctx context.Context, dpt string) error {
+	departments := []string{dpt}
+	if dpt == "20" {
+		departments = []string{"2A", "2B"}
+	}
+
+	var communes []geoCommune
+	for _, code := range departments {
+		url := fmt.Sprintf("https://geo.api.gouv.fr/departements/%s/communes?fields=nom,code&format=json", code)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		var page []geoCommune
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("can't download the communes of %s: %s", code, resp.Status)
+		}
+		if err != nil {
+			return err
+		}
+		communes = append(communes, page...)
+	}
+
+	b, err := json.Marshal(communes)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(communesDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(communesDir, dpt+".json"), b, 0o644)
+}
+
+// LoadCommunes reads the communes of every imported department. Departments
+// imported without their communes are left out.
+func LoadCommunes() (Communes, error) {
+	paths, err := filepath.Glob(filepath.Join(communesDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	response := make(Communes)
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var communes []geoCommune
+		if err := json.Unmarshal(b, &communes); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, c := range communes {
+			code := c.Code
+			if strings.HasPrefix(code, "2A") || strings.HasPrefix(code, "2B") {
+				code = "20" + code[2:]
+			}
+			response[code] = append(response[code], c.Name)
+		}
+	}
+	return response, nil
+}
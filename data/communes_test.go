@@ -0,0 +1,46 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadCommunes(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(communesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"01.json": `[{"nom":"Ambérieu-en-Bugey","code":"01004"}]`,
+		"20.json": `[{"nom":"Ajaccio","code":"2A004"},{"nom":"Aghione","code":"2B002"},{"nom":"Afa","code":"2A001"}]`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(communesDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	communes, err := LoadCommunes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		numPost string
+		want    []string
+	}{
+		{"01004001", []string{"Ambérieu-en-Bugey"}},
+		// the leading zero is lost in the CSV files
+		{"1004001", []string{"Ambérieu-en-Bugey"}},
+		{"20004002", []string{"Ajaccio"}},
+		{"20002001", []string{"Aghione"}},
+		{"75114001", nil},
+		{"123", nil},
+	}
+	for _, test := range tests {
+		if got := communes.Of(test.numPost); !slices.Equal(got, test.want) {
+			t.Errorf("Of(%q) = %v, want %v", test.numPost, got, test.want)
+		}
+	}
+}
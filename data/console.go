@@ -0,0 +1,107 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// MaxQueryRows caps the number of rows kept in memory by RunQuery, and so the
+// number of exported rows.
+const MaxQueryRows = 100000
+
+// QueryResult holds the rows of an ad-hoc query converted to text.
+type QueryResult struct {
+	Columns   []string
+	Rows      [][]string
+	Truncated bool
+	Duration  time.Duration
+}
+
+// RunQuery executes a free-form query. The query stops as soon as ctx is
+// cancelled.
+func RunQuery(ctx context.Context, db *sql.DB, query string) (*QueryResult, error) {
+	started := time.Now()
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &QueryResult{Columns: columns, Rows: make([][]string, 0, 1000)}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if len(result.Rows) == MaxQueryRows {
+			result.Truncated = true
+			break
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = formatValue(v)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result.Duration = time.Since(started)
+	return result, nil
+}
+
+// WriteQueryCSV writes the rows of the result to w, header included. It stops
+// as soon as ctx is cancelled and returns the number of written rows.
+func WriteQueryCSV(ctx context.Context, result *QueryResult, w io.Writer) (int, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(result.Columns); err != nil {
+		return 0, err
+	}
+	for i, row := range result.Rows {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := writer.Write(row); err != nil {
+			return i, err
+		}
+	}
+	writer.Flush()
+	return len(result.Rows), writer.Error()
+}
+
+func formatValue(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32)
+	case time.Time:
+		if value.Hour() == 0 && value.Minute() == 0 && value.Second() == 0 && value.Nanosecond() == 0 {
+			return value.Format(time.DateOnly)
+		}
+		return value.Format(time.DateTime)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,220 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// RainEnvelope compares the cumulative rainfall of a year with the
+// distribution of the cumulative rainfall of the complete years before it.
+// Every series is laid out on the calendar of the selected year, which starts
+// on Start (January 1st, or the first day of a hydrological year).
+type RainEnvelope struct {
+	Start     time.Time
+	PastYears int
+	Current   []SeriesPoint
+	Min       []SeriesPoint
+	Max       []SeriesPoint
+	Median    []SeriesPoint
+	// Deciles holds D1 to D9, Deciles[4] being the median.
+	Deciles [9][]SeriesPoint
+}
+
+// GetDailyRain returns the daily rainfall of a station, or the weighted daily
+// rainfall of its members for a department or region pseudo-station.
+func GetDailyRain(ctx context.Context, db *sql.DB, station *StationInfo) ([]SeriesPoint, error) {
+	if !station.IsAggregate() {
+		rain, err := GetStationRain(ctx, db, station.NumPost)
+		if err != nil {
+			return nil, err
+		}
+		return dailyRainSeries(rain), nil
+	}
+
+	series, err := queryDailyRain(ctx, db, station.MemberIds())
+	if err != nil {
+		return nil, err
+	}
+	return AggregateSeries(series, station.members()), nil
+}
+
+// queryDailyRain reads the daily rainfall of the given stations in a single
+// scan of the dataset.
+func queryDailyRain(ctx context.Context, db *sql.DB, ids []string) (map[string][]SeriesPoint, error) {
+	filter, args := stationFilter(ids)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT CAST(NUM_POSTE AS VARCHAR) AS ID, CAST(AAAAMMJJ AS VARCHAR), CAST(RR AS DOUBLE)
+		FROM %s
+		WHERE %s
+		ORDER BY ID, AAAAMMJJ ASC
+	`, parquetSource, filter), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make(map[string][]SeriesPoint, len(ids))
+
+	var (
+		id, date string
+		rr       sql.NullFloat64
+	)
+
+	for rows.Next() {
+		if err := rows.Scan(&id, &date, &rr); err != nil {
+			return nil, err
+		}
+		day, err := time.Parse("20060102", date)
+		if err != nil {
+			continue
+		}
+		response[id] = append(response[id], SeriesPoint{T: day, Value: nullToNaN(rr)})
+	}
+	return response, rows.Err()
+}
+
+func dailyRainSeries(rain []StationRain) []SeriesPoint {
+	response := make([]SeriesPoint, 0, len(rain))
+	for _, r := range rain {
+		day, err := time.Parse("20060102", r.AAAAMMJJ)
+		if err != nil {
+			continue
+		}
+		response = append(response, SeriesPoint{T: day, Value: r.RR})
+	}
+	slices.SortFunc(response, func(a, b SeriesPoint) int { return a.T.Compare(b.T) })
+	return response
+}
+
+// RainYears lists the start of the yearly periods having at least one rainfall
+// measurement.
+func RainYears(daily []SeriesPoint, period Period) []time.Time {
+	years := make([]time.Time, 0, 150)
+	for _, p := range daily {
+		if math.IsNaN(p.Value) {
+			continue
+		}
+		start, _ := period.Start(p.T)
+		if len(years) == 0 || !years[len(years)-1].Equal(start) {
+			years = append(years, start)
+		}
+	}
+	return years
+}
+
+// ComputeRainEnvelope builds the cumulative rainfall of the yearly period
+// starting on start and the min/max/decile envelope of the complete years
+// before it. The current year stops at its last measurement; missing days
+// count as dry.
+func ComputeRainEnvelope(daily []SeriesPoint, start time.Time, period Period) (*RainEnvelope, error) {
+	if !period.IsYearly() {
+		return nil, fmt.Errorf("cumulative rainfall needs a yearly period, got %s", period)
+	}
+	year := start.Year()
+	// days are stored on a 366-day calendar shifted to the period start
+	offset := calendarDay(start)
+	slot := func(t time.Time) int {
+		return (calendarDay(t) - offset + 366) % 366
+	}
+
+	type yearRain struct {
+		days     [366]float64
+		measured int
+		last     int
+	}
+	byYear := make(map[int]*yearRain)
+	for _, p := range daily {
+		periodStart, _ := period.Start(p.T)
+		if math.IsNaN(p.Value) || periodStart.Year() > year {
+			continue
+		}
+		y, ok := byYear[periodStart.Year()]
+		if !ok {
+			y = &yearRain{last: -1}
+			byYear[periodStart.Year()] = y
+		}
+		day := slot(p.T)
+		y.days[day] = p.Value
+		y.measured++
+		y.last = max(y.last, day)
+	}
+
+	current, ok := byYear[year]
+	if !ok {
+		return nil, ErrNotEnoughData
+	}
+
+	past := make([][366]float64, 0, len(byYear))
+	for y, rain := range byYear {
+		if y == year || float64(rain.measured) < float64(period.Days(time.Date(y, start.Month(), 1, 0, 0, 0, 0, time.UTC)))*minYearCoverage {
+			continue
+		}
+		var cumulative [366]float64
+		sum := 0.0
+		for day, v := range rain.days {
+			sum += v
+			cumulative[day] = sum
+		}
+		past = append(past, cumulative)
+	}
+
+	envelope := &RainEnvelope{Start: start, PastYears: len(past)}
+	values := make([]float64, len(past))
+	sum := 0.0
+
+	for day := range 366 {
+		date := time.Date(2000, time.January, 1+(day+offset)%366, 0, 0, 0, 0, time.UTC)
+		dateYear := year
+		if date.Month() < start.Month() {
+			dateYear++
+		}
+		if date.Month() == time.February && date.Day() == 29 && daysInYear(dateYear) != 366 {
+			continue
+		}
+		date = time.Date(dateYear, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+		sum += current.days[day]
+		if day <= current.last {
+			envelope.Current = append(envelope.Current, SeriesPoint{T: date, Value: sum})
+		}
+
+		if len(past) == 0 {
+			continue
+		}
+		for i, p := range past {
+			values[i] = p[day]
+		}
+		slices.Sort(values)
+		envelope.Min = append(envelope.Min, SeriesPoint{T: date, Value: values[0]})
+		envelope.Max = append(envelope.Max, SeriesPoint{T: date, Value: values[len(values)-1]})
+		for d := range envelope.Deciles {
+			envelope.Deciles[d] = append(envelope.Deciles[d], SeriesPoint{
+				T:     date,
+				Value: percentile(values, float64(d+1)/10),
+			})
+		}
+	}
+	envelope.Median = envelope.Deciles[4]
+	return envelope, nil
+}
+
+// Decile returns the decile (0 to 10) the value of the current year falls in on
+// its last measured day, or -1 when there is nothing to compare with.
+func (e *RainEnvelope) Decile() int {
+	if len(e.Current) == 0 || e.PastYears == 0 {
+		return -1
+	}
+	index := len(e.Current) - 1
+	value := e.Current[index].Value
+	decile := 0
+	for _, d := range e.Deciles {
+		if value >= d[index].Value {
+			decile++
+		}
+	}
+	return decile
+}
@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type DailyRecord struct {
+	Date time.Time
+	RR   float64
+	TN   float64
+	TX   float64
+}
+
+func GetDailyRecords(ctx context.Context, db *sql.DB, numPost string) ([]DailyRecord, error) {
+	stmt, err := db.PrepareContext(ctx, fmt.Sprintf(`
+		SELECT CAST(AAAAMMJJ AS VARCHAR), CAST(RR AS DOUBLE), CAST(TN AS DOUBLE), CAST(TX AS DOUBLE)
+		FROM %s
+		WHERE CAST(NUM_POSTE AS VARCHAR) = ?
+		ORDER BY AAAAMMJJ ASC
+	`, parquetSource))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, numPost)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make([]DailyRecord, 0, 25000)
+
+	var (
+		date       string
+		rr, tn, tx sql.NullFloat64
+	)
+
+	for rows.Next() {
+		if err := rows.Scan(&date, &rr, &tn, &tx); err != nil {
+			return nil, err
+		}
+		day, err := time.Parse("20060102", date)
+		if err != nil {
+			continue
+		}
+		response = append(response, DailyRecord{
+			Date: day,
+			RR:   nullToNaN(rr),
+			TN:   nullToNaN(tn),
+			TX:   nullToNaN(tx),
+		})
+	}
+	return response, rows.Err()
+}
+
+// forEachStationDaily streams the daily dataset of the given stations (all of
+// them when ids is empty) ordered by station so that only one station is held
+// in memory at a time.
+func forEachStationDaily(ctx context.Context, db *sql.DB, ids []string, fn func(numPost string, days []DailyRecord)) error {
+	filter, args := stationFilter(ids)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT CAST(NUM_POSTE AS VARCHAR) AS ID, CAST(AAAAMMJJ AS VARCHAR), CAST(RR AS DOUBLE), CAST(TN AS DOUBLE), CAST(TX AS DOUBLE)
+		FROM %s
+		WHERE %s
+		ORDER BY ID, AAAAMMJJ ASC
+	`, parquetSource, filter), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		numPost, current, date string
+		rr, tn, tx             sql.NullFloat64
+	)
+	days := make([]DailyRecord, 0, 25000)
+
+	for rows.Next() {
+		if err := rows.Scan(&numPost, &date, &rr, &tn, &tx); err != nil {
+			return err
+		}
+		if numPost != current {
+			if len(days) > 0 {
+				fn(current, days)
+			}
+			current = numPost
+			days = days[:0]
+		}
+		day, err := time.Parse("20060102", date)
+		if err != nil {
+			continue
+		}
+		days = append(days, DailyRecord{
+			Date: day,
+			RR:   nullToNaN(rr),
+			TN:   nullToNaN(tn),
+			TX:   nullToNaN(tx),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(days) > 0 {
+		fn(current, days)
+	}
+	return nil
+}
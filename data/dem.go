@@ -0,0 +1,231 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// maxDEMSamples bounds the number of elevations kept in memory, larger
+// models being read one sample out of every few.
+const maxDEMSamples = 1 << 24
+
+// EPSG codes of the coordinate systems of elevation models
+const (
+	EPSGWGS84       = 4326
+	EPSGWebMercator = 3857
+)
+
+// DEM is a digital elevation model: a grid of elevations in metres read from
+// a GeoTIFF file. The corner of the first sample is at (OriginX, OriginY) in
+// the coordinate system of the file, columns going eastward and rows
+// southward.
+type DEM struct {
+	Width, Height int
+	// Elevations are stored row by row, NaN where unknown
+	Elevations              []float32
+	OriginX, OriginY        float64
+	PixelWidth, PixelHeight float64
+	// EPSG is the code of the coordinate system, EPSGWGS84 for longitudes
+	// and latitudes
+	EPSG     int
+	Min, Max float64
+}
+
+func LoadDEM(path string) (*DEM, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	t, err := openTIFF(file)
+	if err != nil {
+		return nil, err
+	}
+	dem, err := readDEM(t)
+	if err != nil {
+		return nil, fmt.Errorf("%s : %w", path, err)
+	}
+	return dem, nil
+}
+
+func readDEM(t *tiffFile) (*DEM, error) {
+	l, err := t.layout()
+	if err != nil {
+		return nil, err
+	}
+	dem := &DEM{}
+	if err := dem.georeference(t); err != nil {
+		return nil, err
+	}
+
+	// one sample out of step in both directions
+	step := max(1, int(math.Ceil(math.Sqrt(float64(l.width)*float64(l.height)/maxDEMSamples))))
+	dem.Width, dem.Height = (l.width+step-1)/step, (l.height+step-1)/step
+	dem.PixelWidth *= float64(step)
+	dem.PixelHeight *= float64(step)
+	dem.Elevations = make([]float32, dem.Width*dem.Height)
+	for i := range dem.Elevations {
+		dem.Elevations[i] = float32(math.NaN())
+	}
+
+	noData := t.noData()
+	dem.Min, dem.Max = math.Inf(1), math.Inf(-1)
+	for down := range l.chunksDown() {
+		for across := range l.chunksAcross() {
+			samples, err := t.chunk(l, down*l.chunksAcross()+across)
+			if err != nil {
+				return nil, err
+			}
+			for i, v := range samples {
+				col := across*l.chunkWidth + i%l.chunkWidth
+				row := down*l.chunkHeight + i/l.chunkWidth
+				if col >= l.width || row >= l.height || col%step != 0 || row%step != 0 {
+					continue
+				}
+				// large negative values are the usual unknown elevations
+				if v == noData || math.IsNaN(v) || v < -1e4 {
+					continue
+				}
+				dem.Elevations[row/step*dem.Width+col/step] = float32(v)
+				dem.Min, dem.Max = math.Min(dem.Min, v), math.Max(dem.Max, v)
+			}
+		}
+	}
+	if math.IsInf(dem.Min, 0) {
+		return nil, fmt.Errorf("%w : aucune altitude", errGeoTIFFFormat)
+	}
+	return dem, nil
+}
+
+// georeference reads where the grid lies and in which coordinate system.
+func (d *DEM) georeference(t *tiffFile) error {
+	keys := t.geoKeys()
+	switch keys[geoKeyModelType] {
+	case 1:
+		d.EPSG = int(keys[geoKeyProjectedType])
+	case 2:
+		// the datums of the longitudes and latitudes are alike at map scale
+		d.EPSG = EPSGWGS84
+	default:
+		return fmt.Errorf("%w : système de coordonnées inconnu", errGeoTIFFFormat)
+	}
+
+	if transform := t.floats(tagModelTransform); len(transform) >= 8 {
+		if transform[1] != 0 || transform[4] != 0 {
+			return fmt.Errorf("%w : grille tournée non prise en charge", errGeoTIFFFormat)
+		}
+		d.PixelWidth, d.PixelHeight = transform[0], -transform[5]
+		d.OriginX, d.OriginY = transform[3], transform[7]
+	} else {
+		scale, tiepoint := t.floats(tagModelPixelScale), t.floats(tagModelTiepoint)
+		if len(scale) < 2 || len(tiepoint) < 6 {
+			return fmt.Errorf("%w : géoréférencement absent", errGeoTIFFFormat)
+		}
+		d.PixelWidth, d.PixelHeight = scale[0], scale[1]
+		d.OriginX = tiepoint[3] - tiepoint[0]*scale[0]
+		d.OriginY = tiepoint[4] + tiepoint[1]*scale[1]
+	}
+	if d.PixelWidth <= 0 || d.PixelHeight <= 0 {
+		return fmt.Errorf("%w : taille de pixel invalide", errGeoTIFFFormat)
+	}
+	// the tie point is then the centre of the first pixel
+	if keys[geoKeyRasterType] == 2 {
+		d.OriginX -= d.PixelWidth / 2
+		d.OriginY += d.PixelHeight / 2
+	}
+	return nil
+}
+
+// Pixel gives the position in samples of a point of the coordinate system of
+// the model, sample centres being at whole positions.
+func (d *DEM) Pixel(x, y float64) (col, row float64) {
+	return (x-d.OriginX)/d.PixelWidth - 0.5, (d.OriginY-y)/d.PixelHeight - 0.5
+}
+
+// At returns the elevation of a sample, NaN outside the model.
+func (d *DEM) At(col, row int) float64 {
+	if col < 0 || row < 0 || col >= d.Width || row >= d.Height {
+		return math.NaN()
+	}
+	return float64(d.Elevations[row*d.Width+col])
+}
+
+// ElevationAt interpolates the elevation at a position in samples, NaN where
+// it is unknown.
+func (d *DEM) ElevationAt(col, row float64) float64 {
+	return SampleGrid(d.Elevations, d.Width, d.Height, col, row)
+}
+
+// SampleGrid bilinearly interpolates a grid stored row by row at a position
+// in cells, the nearest cell being used next to unknown values.
+func SampleGrid(values []float32, width, height int, col, row float64) float64 {
+	if col < -0.5 || row < -0.5 || col > float64(width)-0.5 || row > float64(height)-0.5 {
+		return math.NaN()
+	}
+	c0, r0 := int(math.Floor(col)), int(math.Floor(row))
+	fc, fr := col-float64(c0), row-float64(r0)
+	at := func(c, r int) float64 {
+		c, r = max(0, min(width-1, c)), max(0, min(height-1, r))
+		return float64(values[r*width+c])
+	}
+	v00, v10, v01, v11 := at(c0, r0), at(c0+1, r0), at(c0, r0+1), at(c0+1, r0+1)
+	if math.IsNaN(v00 + v10 + v01 + v11) {
+		return at(int(math.Round(col)), int(math.Round(row)))
+	}
+	top := v00 + (v10-v00)*fc
+	bottom := v01 + (v11-v01)*fc
+	return top + (bottom-top)*fr
+}
+
+// cellSize is the ground size in metres of the samples of a row.
+func (d *DEM) cellSize(row int) (dx, dy float64) {
+	y := d.OriginY - (float64(row)+0.5)*d.PixelHeight
+	switch d.EPSG {
+	case EPSGWGS84:
+		return d.PixelWidth * 111320 * math.Cos(y*math.Pi/180), d.PixelHeight * 110574
+	case EPSGWebMercator:
+		// Web Mercator stretches distances by the inverse of the cosine
+		lat := 2*math.Atan(math.Exp(y/6378137)) - math.Pi/2
+		return d.PixelWidth * math.Cos(lat), d.PixelHeight * math.Cos(lat)
+	}
+	return d.PixelWidth, d.PixelHeight
+}
+
+// Hillshade computes the lighting of the relief by a sun at the azimuth and
+// the altitude in degrees, from 0 (in the shade) to 1, with Horn's slopes.
+// Elevations are multiplied by exaggeration.
+func (d *DEM) Hillshade(azimuth, altitude, exaggeration float64) []float32 {
+	zenith := (90 - altitude) * math.Pi / 180
+	// the azimuth counted counterclockwise from the east
+	sun := (450 - azimuth) * math.Pi / 180
+	shade := make([]float32, len(d.Elevations))
+	for row := range d.Height {
+		dx, dy := d.cellSize(row)
+		for col := range d.Width {
+			centre := d.At(col, row)
+			if math.IsNaN(centre) {
+				shade[row*d.Width+col] = float32(math.NaN())
+				continue
+			}
+			z := func(c, r int) float64 {
+				c, r = max(0, min(d.Width-1, c)), max(0, min(d.Height-1, r))
+				if v := d.At(c, r); !math.IsNaN(v) {
+					return v
+				}
+				return centre
+			}
+			a, b, c := z(col-1, row-1), z(col, row-1), z(col+1, row-1)
+			e, f := z(col-1, row), z(col+1, row)
+			g, h, i := z(col-1, row+1), z(col, row+1), z(col+1, row+1)
+			dzdx := ((c + 2*f + i) - (a + 2*e + g)) / (8 * dx) * exaggeration
+			// rows going southward, dzdy grows toward the south
+			dzdy := ((g + 2*h + i) - (a + 2*b + c)) / (8 * dy) * exaggeration
+			slope := math.Atan(math.Hypot(dzdx, dzdy))
+			aspect := math.Atan2(dzdy, -dzdx)
+			light := math.Cos(zenith)*math.Cos(slope) + math.Sin(zenith)*math.Sin(slope)*math.Cos(sun-aspect)
+			shade[row*d.Width+col] = float32(math.Max(0, light))
+		}
+	}
+	return shade
+}
@@ -0,0 +1,176 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLoadDEMGeoreference(t *testing.T) {
+	base := testTIFF{order: binary.LittleEndian, width: 4, height: 3, bits: 16, format: sampleSigned, compression: compressionNone}
+	tests := []struct {
+		name string
+		// change sets the georeferencing of the file
+		change                  func(*testTIFF)
+		epsg                    int
+		originX, originY        float64
+		pixelWidth, pixelHeight float64
+	}{
+		{"tiepoint", func(tt *testTIFF) {
+			tt.modelType, tt.rasterType, tt.epsg = 1, 1, 2154
+			tt.tiepoint, tt.scale = []float64{1, 2, 0, 700025, 6600050, 0}, []float64{25, 25, 0}
+		}, 2154, 700000, 6600100, 25, 25},
+		{"transform", func(tt *testTIFF) {
+			tt.modelType, tt.rasterType, tt.epsg = 1, 1, EPSGWebMercator
+			tt.transform = []float64{30, 0, 0, 250000, 0, -30, 0, 5700000, 0, 0, 0, 0, 0, 0, 0, 1}
+		}, EPSGWebMercator, 250000, 5700000, 30, 30},
+		{"geographic pixel is point", func(tt *testTIFF) {
+			tt.modelType, tt.rasterType = 2, 2
+			tt.tiepoint, tt.scale = []float64{0, 0, 0, 2, 47, 0}, []float64{0.5, 0.25, 0}
+		}, EPSGWGS84, 1.75, 47.125, 0.5, 0.25},
+		{"transform pixel is point", func(tt *testTIFF) {
+			tt.modelType, tt.rasterType, tt.epsg = 1, 2, 2154
+			tt.transform = []float64{10, 0, 0, 700005, 0, -10, 0, 6600005, 0, 0, 0, 0, 0, 0, 0, 1}
+		}, 2154, 700000, 6600010, 10, 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tt := base
+			test.change(&tt)
+			dem, err := LoadDEM(tt.write(t, testElevations(tt.width, tt.height, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dem.EPSG != test.epsg || dem.OriginX != test.originX || dem.OriginY != test.originY || dem.PixelWidth != test.pixelWidth || dem.PixelHeight != test.pixelHeight {
+				t.Errorf("EPSG %d, origin (%v, %v), pixel %v×%v, want EPSG %d, origin (%v, %v), pixel %v×%v",
+					dem.EPSG, dem.OriginX, dem.OriginY, dem.PixelWidth, dem.PixelHeight,
+					test.epsg, test.originX, test.originY, test.pixelWidth, test.pixelHeight)
+			}
+			// the centre of the last sample
+			col, row := dem.Pixel(dem.OriginX+3.5*dem.PixelWidth, dem.OriginY-2.5*dem.PixelHeight)
+			if math.Abs(col-3) > 1e-9 || math.Abs(row-2) > 1e-9 {
+				t.Errorf("Pixel() = %v, %v, want 3, 2", col, row)
+			}
+		})
+	}
+
+	t.Run("rotated", func(t *testing.T) {
+		tt := base
+		tt.modelType, tt.rasterType, tt.epsg = 1, 1, 2154
+		tt.transform = []float64{10, 1, 0, 700000, 0, -10, 0, 6600000, 0, 0, 0, 0, 0, 0, 0, 1}
+		if _, err := LoadDEM(tt.write(t, testElevations(tt.width, tt.height, 0))); !errors.Is(err, errGeoTIFFFormat) {
+			t.Errorf("error = %v, want %q", err, errGeoTIFFFormat)
+		}
+	})
+}
+
+func TestLoadDEMNoData(t *testing.T) {
+	tt := testTIFF{order: binary.LittleEndian, width: 5, height: 4, bits: 32, format: sampleFloat, compression: compressionDeflate,
+		modelType: 2, rasterType: 1, tiepoint: []float64{0, 0, 0, 2, 47, 0}, scale: []float64{0.5, 0.5, 0}, noData: "-32767"}
+	elevations := testElevations(tt.width, tt.height, 0)
+	elevations[0] = -32767
+	elevations[7] = math.NaN()
+	elevations[19] = -32767
+	dem, err := LoadDEM(tt.write(t, elevations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range elevations {
+		got := dem.At(i%tt.width, i/tt.width)
+		if i == 0 || i == 7 || i == 19 {
+			if !math.IsNaN(got) {
+				t.Errorf("At(%d, %d) = %v, want NaN", i%tt.width, i/tt.width, got)
+			}
+		} else if got != v {
+			t.Errorf("At(%d, %d) = %v, want %v", i%tt.width, i/tt.width, got, v)
+		}
+	}
+	if dem.Min != elevations[1] || dem.Max != elevations[18] {
+		t.Errorf("Min, Max = %v, %v, want %v, %v", dem.Min, dem.Max, elevations[1], elevations[18])
+	}
+
+	for i := range elevations {
+		elevations[i] = -32767
+	}
+	if _, err := LoadDEM(tt.write(t, elevations)); !errors.Is(err, errGeoTIFFFormat) {
+		t.Errorf("error without elevations = %v, want %q", err, errGeoTIFFFormat)
+	}
+}
+
+func TestLoadDEMDownsampling(t *testing.T) {
+	if testing.Short() {
+		t.Skip("large elevation model")
+	}
+	// one more row and column than maxDEMSamples allows, so that every other
+	// sample is kept
+	side := int(math.Sqrt(maxDEMSamples)) + 1
+	tt := testTIFF{order: binary.LittleEndian, width: side, height: side, bits: 8, format: sampleUnsigned, compression: compressionDeflate, rowsPerStrip: 512,
+		modelType: 1, rasterType: 1, epsg: 2154, tiepoint: []float64{0, 0, 0, 700000, 6600000, 0}, scale: []float64{5, 5, 0}}
+	elevations := make([]float64, side*side)
+	for i := range elevations {
+		elevations[i] = float64((i%side + 3*(i/side)) % 251)
+	}
+	dem, err := LoadDEM(tt.write(t, elevations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := (side + 1) / 2
+	if dem.Width != want || dem.Height != want || dem.PixelWidth != 10 || dem.PixelHeight != 10 {
+		t.Fatalf("size %d×%d, pixel %v×%v, want %d×%d, pixel 10×10", dem.Width, dem.Height, dem.PixelWidth, dem.PixelHeight, want, want)
+	}
+	for _, p := range [][2]int{{0, 0}, {1, 0}, {0, 1}, {want - 1, want - 1}, {1234, 567}} {
+		if got, v := dem.At(p[0], p[1]), elevations[2*p[1]*side+2*p[0]]; got != v {
+			t.Errorf("At(%d, %d) = %v, want %v", p[0], p[1], got, v)
+		}
+	}
+}
+
+// TestHillshadePlane compares the lighting of inclined planes with the
+// cosine of the angle between the normal of the plane and the sun.
+func TestHillshadePlane(t *testing.T) {
+	const azimuth, altitude = 315.0, 45.0
+	az, alt := azimuth*math.Pi/180, altitude*math.Pi/180
+	// toward the east, the north and the zenith
+	sun := [3]float64{math.Sin(az) * math.Cos(alt), math.Cos(az) * math.Cos(alt), math.Sin(alt)}
+
+	tests := []struct {
+		name string
+		// slopes of the plane toward the east and the north
+		east, north  float64
+		exaggeration float64
+	}{
+		{"flat", 0, 0, 1},
+		{"rising eastward", 0.1, 0, 1},
+		{"rising northward", 0, 0.2, 1},
+		{"facing the sun", 0.3, -0.3, 1},
+		{"facing away from the sun", -0.3, 0.3, 1},
+		{"exaggerated", 0.1, 0.05, 3},
+		{"in the shade", -2, 2, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dem := &DEM{Width: 6, Height: 5, OriginX: 700000, OriginY: 6600000, PixelWidth: 25, PixelHeight: 25, EPSG: 2154}
+			dem.Elevations = make([]float32, dem.Width*dem.Height)
+			for row := range dem.Height {
+				for col := range dem.Width {
+					x, y := float64(col)*dem.PixelWidth, -float64(row)*dem.PixelHeight
+					dem.Elevations[row*dem.Width+col] = float32(500 + test.east*x + test.north*y)
+				}
+			}
+			east, north := test.east*test.exaggeration, test.north*test.exaggeration
+			want := (-east*sun[0] - north*sun[1] + sun[2]) / math.Sqrt(east*east+north*north+1)
+			want = math.Max(0, want)
+
+			shade := dem.Hillshade(azimuth, altitude, test.exaggeration)
+			// the edges see half the slope
+			for row := 1; row < dem.Height-1; row++ {
+				for col := 1; col < dem.Width-1; col++ {
+					if got := float64(shade[row*dem.Width+col]); math.Abs(got-want) > 1e-4 {
+						t.Fatalf("lighting of (%d, %d) = %v, want %v", col, row, got, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package data
+
+import "fmt"
+
+// DepartmentsGeoJSONPath is the departments outline of
+// github.com/gregoiredavid/france-geojson.
+const DepartmentsGeoJSONPath = "./data/geo/departements-version-simplifiee.geojson"
+
+// DepartmentShape is the outline of a department. Polygons are lists of
+// rings, the first ring of a polygon being its exterior.
+type DepartmentShape struct {
+	Code     string
+	Name     string
+	Polygons [][][]Coordinate
+	Bounds   Bounds
+}
+
+func LoadDepartmentShapes(path string) ([]DepartmentShape, error) {
+	collection, err := LoadGeoJSON(path)
+	if err != nil {
+		return nil, err
+	}
+
+	shapes := make([]DepartmentShape, 0, len(collection.Features))
+	for _, feature := range collection.Features {
+		code, _ := feature.Property("code")
+		name, _ := feature.Property("nom")
+		shape := DepartmentShape{Code: code, Name: name}
+		if feature.Geometry != nil {
+			shape.Polygons = feature.Geometry.Polygons()
+		}
+		if len(shape.Polygons) == 0 {
+			return nil, fmt.Errorf("département %s : aucun polygone", shape.Code)
+		}
+		shape.Bounds = (&Geometry{Type: MultiPolygonGeometry, MultiPolygon: shape.Polygons}).Bounds()
+		shapes = append(shapes, shape)
+	}
+	return shapes, nil
+}
+
+// StationDepartment is the department code used by the station numbers and
+// the datasets: both Corsican departments are numbered 20.
+func (d *DepartmentShape) StationDepartment() string {
+	if d.Code == "2A" || d.Code == "2B" {
+		return "20"
+	}
+	return d.Code
+}
+
+// Contains tells whether the point lies inside the department (even-odd
+// rule, so that holes are excluded).
+func (d *DepartmentShape) Contains(lon, lat float64) bool {
+	if !d.Bounds.contains(lon, lat) {
+		return false
+	}
+	return (&Geometry{Type: MultiPolygonGeometry, MultiPolygon: d.Polygons}).Contains(lon, lat)
+}
+
+// DepartmentAt returns the department containing the point, if any.
+func DepartmentAt(shapes []DepartmentShape, lon, lat float64) (*DepartmentShape, bool) {
+	for i := range shapes {
+		if shapes[i].Contains(lon, lat) {
+			return &shapes[i], true
+		}
+	}
+	return nil, false
+}
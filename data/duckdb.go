@@ -1,14 +1,17 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	_ "github.com/duckdb/duckdb-go/v2"
 )
@@ -19,11 +22,12 @@ type StationInfo struct {
 	Lat        float64
 	Lon        float64
 	Alti       float64
+	Members    []StationMember
 }
 
 type RainByStation struct {
 	NumPost string
-	Year    string
+	Period  string
 	Rain    float64
 }
 
@@ -35,30 +39,49 @@ func InitDB() (*sql.DB, error) {
 	return db, nil
 }
 
-func DownloadParquetFile(dpt string) error {
-	parquetResources, err := fetchDataGouvDataset(dpt)
+func DownloadParquetFile(ctx context.Context, logger *slog.Logger, dpt string) error {
+	parquetResources, err := fetchDataGouvDataset(ctx, dpt)
 	if err != nil {
 		return err
 	}
 
 	for _, resource := range parquetResources {
-		out, err := os.Create(fmt.Sprintf("data/parquet/%s.parquet", resource.id))
-		if err != nil {
-			break
-		}
-		defer out.Close()
-		resp, err := http.Get(resource.parquetUrl)
-		if err != nil {
-			break
+		if err := downloadResource(ctx, resource); err != nil {
+			return err
 		}
-		_, err = io.Copy(out, resp.Body)
-		fmt.Printf("File %s downloaded\n", resource.id)
-
+		logger.Info("File downloaded", "resource", resource.id, "department", dpt)
 	}
 
 	return nil
 }
 
+func downloadResource(ctx context.Context, resource WeatherResource) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resource.parquetUrl, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("can't download %s: %s", resource.parquetUrl, resp.Status)
+	}
+
+	path := fmt.Sprintf("data/parquet/%s.parquet", resource.id)
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		os.Remove(path)
+		return err
+	}
+	return out.Close()
+}
+
 type StationRain struct {
 	NUM_POSTE string
 	NOM_USUEL string
@@ -66,22 +89,26 @@ type StationRain struct {
 	RR        float64
 }
 
-func GetRainByStation(db *sql.DB, numPost string) ([]RainByStation, error) {
-	stmt, err := db.Prepare(`
-		SELECT NUM_POSTE, substr(CAST(AAAAMMJJ AS VARCHAR), 1, 4) as YEAR, sum(CAST(RR AS DOUBLE)) as RAIN
-		FROM read_parquet('data/parquet/*.parquet')
-		WHERE CAST(NUM_POSTE AS VARCHAR) = ?
-		GROUP BY NUM_POSTE, YEAR
-		HAVING count(1) > 365 * 0.95
-		ORDER BY YEAR ASC
-	`)
+func GetRainByStation(ctx context.Context, db *sql.DB, numPost string, period Period) ([]RainByStation, error) {
+	key, periodFilter := period.sqlKey("D")
+	stmt, err := db.PrepareContext(ctx, fmt.Sprintf(`
+		SELECT NUM_POSTE, P, RAIN
+		FROM (
+			SELECT NUM_POSTE, %s as P, sum(CAST(RR AS DOUBLE)) as RAIN, count(RR) as N
+			FROM (SELECT *, %s AS D FROM read_parquet('data/parquet/*.parquet') WHERE CAST(NUM_POSTE AS VARCHAR) = ?)
+			WHERE %s
+			GROUP BY NUM_POSTE, P
+		)
+		WHERE N >= %s * %f
+		ORDER BY P ASC
+	`, key, parquetDate, periodFilter, period.sqlDays("P"), minYearCoverage))
 
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(numPost)
+	rows, err := stmt.QueryContext(ctx, numPost)
 
 	if err != nil {
 		return nil, err
@@ -91,35 +118,34 @@ func GetRainByStation(db *sql.DB, numPost string) ([]RainByStation, error) {
 	response := make([]RainByStation, 0, 1000)
 
 	var (
-		numPoste, year string
-		rain           float64
+		numPoste string
+		start    time.Time
+		rain     float64
 	)
 
-	for {
-		if rows.Next() {
-			rows.Scan(&numPoste, &year, &rain)
-			response = append(response, RainByStation{
-				NumPost: numPoste,
-				Year:    year,
-				Rain:    rain,
-			})
-		} else {
-			break
+	for rows.Next() {
+		if err := rows.Scan(&numPoste, &start, &rain); err != nil {
+			return nil, err
 		}
+		response = append(response, RainByStation{
+			NumPost: numPoste,
+			Period:  period.Label(start),
+			Rain:    rain,
+		})
 	}
-	return response, nil
+	return response, rows.Err()
 }
 
-func GetStationRain(db *sql.DB, station string) []StationRain {
-	stmt, err := db.Prepare("SELECT NUM_POSTE, NOM_USUEL, AAAAMMJJ, RR FROM read_parquet('data/parquet/*.parquet') WHERE CAST(NUM_POSTE AS VARCHAR) LIKE ?")
+func GetStationRain(ctx context.Context, db *sql.DB, station string) ([]StationRain, error) {
+	stmt, err := db.PrepareContext(ctx, "SELECT CAST(NUM_POSTE AS VARCHAR), NOM_USUEL, CAST(AAAAMMJJ AS VARCHAR), CAST(RR AS DOUBLE) FROM read_parquet('data/parquet/*.parquet') WHERE CAST(NUM_POSTE AS VARCHAR) LIKE ?")
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer stmt.Close()
-	rows, err := stmt.Query(station + "%")
+	rows, err := stmt.QueryContext(ctx, station+"%")
 
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	defer rows.Close()
@@ -128,33 +154,31 @@ func GetStationRain(db *sql.DB, station string) []StationRain {
 
 	var (
 		numPoste, nomUsuelle, date string
-		rr                         float64
+		rr                         sql.NullFloat64
 	)
 
-	for {
-		if rows.Next() {
-			rows.Scan(&numPoste, &nomUsuelle, &date, &rr)
-			response = append(response, StationRain{
-				NUM_POSTE: numPoste,
-				NOM_USUEL: nomUsuelle,
-				AAAAMMJJ:  date,
-				RR:        rr,
-			})
-		} else {
-			break
+	for rows.Next() {
+		if err := rows.Scan(&numPoste, &nomUsuelle, &date, &rr); err != nil {
+			return nil, err
 		}
+		response = append(response, StationRain{
+			NUM_POSTE: numPoste,
+			NOM_USUEL: nomUsuelle,
+			AAAAMMJJ:  date,
+			RR:        nullToNaN(rr),
+		})
 	}
-	return response
+	return response, rows.Err()
 }
 
-func GetStations(db *sql.DB) ([]StationInfo, error) {
-	stmt, err := db.Prepare("SELECT DISTINCT NUM_POSTE, NOM_USUEL, LAT, LON, ALTI FROM read_parquet('data/parquet/*.parquet')")
+func GetStations(ctx context.Context, db *sql.DB) ([]StationInfo, error) {
+	stmt, err := db.PrepareContext(ctx, "SELECT DISTINCT NUM_POSTE, NOM_USUEL, LAT, LON, ALTI FROM read_parquet('data/parquet/*.parquet')")
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query()
+	rows, err := stmt.QueryContext(ctx)
 
 	if err != nil {
 		return nil, err
@@ -167,55 +191,20 @@ func GetStations(db *sql.DB) ([]StationInfo, error) {
 
 	response := make([]StationInfo, 0, 1000)
 
-	for {
-		if rows.Next() {
-			rows.Scan(&numPoste, &nomUsuel, &lat, &long, &alti)
-			response = append(response, StationInfo{
-				NumPost:    numPoste,
-				CommonName: nomUsuel,
-				Lat:        lat,
-				Lon:        long,
-				Alti:       alti,
-			})
-		} else {
-			break
+	for rows.Next() {
+		if err := rows.Scan(&numPoste, &nomUsuel, &lat, &long, &alti); err != nil {
+			return nil, err
 		}
+		response = append(response, StationInfo{
+			NumPost:    numPoste,
+			CommonName: nomUsuel,
+			Lat:        lat,
+			Lon:        long,
+			Alti:       alti,
+		})
 	}
 
-	return response, nil
-}
-
-func GetClosestStation(db *sql.DB, lat, long float64) (*StationInfo, error) {
-	stmt, err := db.Prepare(`
-		SELECT NUM_POSTE, NOM_USUEL, LON, LAT, ALTI, ((LAT - ?) * 111)*((LAT - ?)*111) + ((LON - ?)*111*COS((LON + ?) / 2))*((LON - ?)*111*COS((LON + ?) / 2)) as D
-		FROM read_parquet('data/parquet/*.parquet') 
-		WHERE D < 10*10
-		ORDER BY D LIMIT 1
-	`)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer stmt.Close()
-
-	var numPoste, nomUsuel string
-	var latPoste, longPoste, alti, d float64
-
-	rows := stmt.QueryRow(lat, lat, long, long, long, long)
-	err = rows.Scan(&numPoste, &nomUsuel, &latPoste, &longPoste, &alti, &d)
-	if err != nil {
-		return nil, err
-	}
-
-	return &StationInfo{
-		NumPost:    numPoste,
-		CommonName: nomUsuel,
-		Lat:        latPoste,
-		Lon:        longPoste,
-		Alti:       alti,
-	}, nil
-
+	return response, rows.Err()
 }
 
 type DataGouvFile struct {
@@ -266,11 +255,11 @@ type WeatherResource struct {
 	parquetUrl, id string
 }
 
-func fetchDataGouvDataset(dpt string) ([]WeatherResource, error) {
+func fetchDataGouvDataset(ctx context.Context, dpt string) ([]WeatherResource, error) {
 	rainDatasetId := "6569b51ae64326786e4e8e1a"
 	url := fmt.Sprintf("https://www.data.gouv.fr/api/1/datasets/%s/", rainDatasetId)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -283,7 +272,9 @@ func fetchDataGouvDataset(dpt string) ([]WeatherResource, error) {
 
 	defer resp.Body.Close()
 	var dataset DataGouvDataset
-	json.NewDecoder(resp.Body).Decode(&dataset)
+	if err := json.NewDecoder(resp.Body).Decode(&dataset); err != nil {
+		return nil, err
+	}
 
 	if len(dpt) == 1 {
 		dpt = "0" + dpt
@@ -0,0 +1,529 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"slices"
+	"time"
+)
+
+type ClimateIndex string
+
+// The 27 core indices defined by the ETCCDI
+// (Expert Team on Climate Change Detection and Indices).
+const (
+	IndexFD      ClimateIndex = "FD"
+	IndexSU      ClimateIndex = "SU"
+	IndexID      ClimateIndex = "ID"
+	IndexTR      ClimateIndex = "TR"
+	IndexGSL     ClimateIndex = "GSL"
+	IndexTXx     ClimateIndex = "TXx"
+	IndexTNx     ClimateIndex = "TNx"
+	IndexTXn     ClimateIndex = "TXn"
+	IndexTNn     ClimateIndex = "TNn"
+	IndexTN10p   ClimateIndex = "TN10p"
+	IndexTX10p   ClimateIndex = "TX10p"
+	IndexTN90p   ClimateIndex = "TN90p"
+	IndexTX90p   ClimateIndex = "TX90p"
+	IndexWSDI    ClimateIndex = "WSDI"
+	IndexCSDI    ClimateIndex = "CSDI"
+	IndexDTR     ClimateIndex = "DTR"
+	IndexRx1day  ClimateIndex = "Rx1day"
+	IndexRx5day  ClimateIndex = "Rx5day"
+	IndexSDII    ClimateIndex = "SDII"
+	IndexR10mm   ClimateIndex = "R10mm"
+	IndexR20mm   ClimateIndex = "R20mm"
+	IndexR25mm   ClimateIndex = "R25mm"
+	IndexCDD     ClimateIndex = "CDD"
+	IndexCWD     ClimateIndex = "CWD"
+	IndexR95pTOT ClimateIndex = "R95pTOT"
+	IndexR99pTOT ClimateIndex = "R99pTOT"
+	IndexPRCPTOT ClimateIndex = "PRCPTOT"
+)
+
+type ClimateIndexInfo struct {
+	Index ClimateIndex
+	Label string
+	Unit  string
+}
+
+var ClimateIndices = []ClimateIndexInfo{
+	{IndexFD, "Jours de gel (TN < 0°C)", "jours"},
+	{IndexSU, "Jours d'été (TX > 25°C)", "jours"},
+	{IndexID, "Jours sans dégel (TX < 0°C)", "jours"},
+	{IndexTR, "Nuits tropicales (TN > 20°C)", "jours"},
+	{IndexGSL, "Durée de la saison de croissance", "jours"},
+	{IndexTXx, "TX maximale", "°C"},
+	{IndexTNx, "TN maximale", "°C"},
+	{IndexTXn, "TX minimale", "°C"},
+	{IndexTNn, "TN minimale", "°C"},
+	{IndexTN10p, "Nuits froides (TN < 10e centile)", "%"},
+	{IndexTX10p, "Journées froides (TX < 10e centile)", "%"},
+	{IndexTN90p, "Nuits chaudes (TN > 90e centile)", "%"},
+	{IndexTX90p, "Journées chaudes (TX > 90e centile)", "%"},
+	{IndexWSDI, "Durée des vagues de chaleur", "jours"},
+	{IndexCSDI, "Durée des vagues de froid", "jours"},
+	{IndexDTR, "Amplitude thermique diurne", "°C"},
+	{IndexRx1day, "Pluie maximale en 1 jour", "mm"},
+	{IndexRx5day, "Pluie maximale en 5 jours", "mm"},
+	{IndexSDII, "Intensité moyenne des jours de pluie", "mm/jour"},
+	{IndexR10mm, "Jours de pluie ≥ 10 mm", "jours"},
+	{IndexR20mm, "Jours de pluie ≥ 20 mm", "jours"},
+	{IndexR25mm, "Jours de pluie ≥ 25 mm", "jours"},
+	{IndexCDD, "Jours secs consécutifs", "jours"},
+	{IndexCWD, "Jours humides consécutifs", "jours"},
+	{IndexR95pTOT, "Cumul des jours très pluvieux (> 95e centile)", "mm"},
+	{IndexR99pTOT, "Cumul des jours extrêmement pluvieux (> 99e centile)", "mm"},
+	{IndexPRCPTOT, "Cumul des jours de pluie", "mm"},
+}
+
+// PeriodIndices holds the indices of the period starting on Start.
+type PeriodIndices struct {
+	Start  time.Time
+	Values map[ClimateIndex]float64
+}
+
+const (
+	wetDayThreshold  = 1.0
+	spellMinDuration = 6
+	baseStartYear    = 1961
+	baseEndYear      = 1990
+	minBaseYears     = 20
+	minYearCoverage  = 0.95
+)
+
+type indexThresholds struct {
+	tn10, tn90, tx10, tx90 [366]float64
+	r95, r99               float64
+}
+
+func GetClimateIndices(ctx context.Context, db *sql.DB, station *StationInfo, period Period) ([]PeriodIndices, error) {
+	if !station.IsAggregate() {
+		days, err := GetDailyRecords(ctx, db, station.NumPost)
+		if err != nil {
+			return nil, err
+		}
+		return ComputeClimateIndices(days, period), nil
+	}
+
+	// indices of a pseudo-station are the weighted mean of its members indices
+	series := make(map[ClimateIndex]map[string][]SeriesPoint, len(ClimateIndices))
+	for _, info := range ClimateIndices {
+		series[info.Index] = make(map[string][]SeriesPoint, len(station.Members))
+	}
+	err := forEachStationDaily(ctx, db, station.MemberIds(), func(numPost string, days []DailyRecord) {
+		indices := ComputeClimateIndices(days, period)
+		for _, info := range ClimateIndices {
+			series[info.Index][numPost] = ClimateIndexSeries(indices, info.Index)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byStart := make(map[int64]*PeriodIndices)
+	starts := make([]int64, 0, 100)
+	for _, info := range ClimateIndices {
+		for _, p := range AggregateSeries(series[info.Index], station.Members) {
+			indices, ok := byStart[p.T.Unix()]
+			if !ok {
+				indices = &PeriodIndices{Start: p.T, Values: make(map[ClimateIndex]float64, len(ClimateIndices))}
+				byStart[p.T.Unix()] = indices
+				starts = append(starts, p.T.Unix())
+			}
+			indices.Values[info.Index] = p.Value
+		}
+	}
+	slices.Sort(starts)
+
+	response := make([]PeriodIndices, 0, len(starts))
+	for _, start := range starts {
+		for _, info := range ClimateIndices {
+			if _, ok := byStart[start].Values[info.Index]; !ok {
+				byStart[start].Values[info.Index] = math.NaN()
+			}
+		}
+		response = append(response, *byStart[start])
+	}
+	return response, nil
+}
+
+// GetClimateIndexByStation returns, for every station, the mean of the
+// values of the given index over the periods.
+func GetClimateIndexByStation(ctx context.Context, db *sql.DB, index ClimateIndex, period Period) (map[string]float64, error) {
+	response := make(map[string]float64)
+	err := forEachStationDaily(ctx, db, nil, func(numPost string, days []DailyRecord) {
+		mean := MeanValue(ClimateIndexSeries(ComputeClimateIndices(days, period), index))
+		if !math.IsNaN(mean) {
+			response[numPost] = mean
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func ClimateIndexSeries(indices []PeriodIndices, index ClimateIndex) []SeriesPoint {
+	series := make([]SeriesPoint, 0, len(indices))
+	for _, p := range indices {
+		if math.IsNaN(p.Values[index]) {
+			continue
+		}
+		series = append(series, SeriesPoint{T: p.Start, Value: p.Values[index]})
+	}
+	return series
+}
+
+// ComputeClimateIndices computes the indices of every period of the daily
+// records, which must be sorted by date. Percentile thresholds always come
+// from the whole base period.
+func ComputeClimateIndices(days []DailyRecord, period Period) []PeriodIndices {
+	if len(days) == 0 {
+		return nil
+	}
+	thresholds := computeThresholds(days)
+
+	response := make([]PeriodIndices, 0, 100)
+	var current time.Time
+	start := -1
+	for i, d := range days {
+		key, ok := period.Start(d.Date)
+		if start >= 0 && (!ok || !key.Equal(current)) {
+			response = append(response, computePeriodIndices(days[start:i], current, period, thresholds))
+			start = -1
+		}
+		if ok && start < 0 {
+			current, start = key, i
+		}
+	}
+	if start >= 0 {
+		response = append(response, computePeriodIndices(days[start:], current, period, thresholds))
+	}
+	return response
+}
+
+func computePeriodIndices(days []DailyRecord, start time.Time, period Period, t *indexThresholds) PeriodIndices {
+	values := make(map[ClimateIndex]float64, len(ClimateIndices))
+	for _, info := range ClimateIndices {
+		values[info.Index] = math.NaN()
+	}
+	result := PeriodIndices{Start: start, Values: values}
+
+	expected := float64(period.Days(start)) * minYearCoverage
+
+	var nRR, nTN, nTX, nDTR int
+	for _, d := range days {
+		if !math.IsNaN(d.RR) {
+			nRR++
+		}
+		if !math.IsNaN(d.TN) {
+			nTN++
+		}
+		if !math.IsNaN(d.TX) {
+			nTX++
+		}
+		if !math.IsNaN(d.TN) && !math.IsNaN(d.TX) {
+			nDTR++
+		}
+	}
+
+	if float64(nTN) >= expected {
+		var fd, tr, tn10, tn90 float64
+		tnx, tnn := -math.MaxFloat64, math.MaxFloat64
+		for _, d := range days {
+			if math.IsNaN(d.TN) {
+				continue
+			}
+			cday := calendarDay(d.Date)
+			if d.TN < 0 {
+				fd++
+			}
+			if d.TN > 20 {
+				tr++
+			}
+			if d.TN < t.tn10[cday] {
+				tn10++
+			}
+			if d.TN > t.tn90[cday] {
+				tn90++
+			}
+			tnx = math.Max(tnx, d.TN)
+			tnn = math.Min(tnn, d.TN)
+		}
+		values[IndexFD] = fd
+		values[IndexTR] = tr
+		values[IndexTNx] = tnx
+		values[IndexTNn] = tnn
+		values[IndexTN10p] = 100 * tn10 / float64(nTN)
+		values[IndexTN90p] = 100 * tn90 / float64(nTN)
+		values[IndexCSDI] = spellDays(days, func(d DailyRecord) bool {
+			return d.TN < t.tn10[calendarDay(d.Date)]
+		})
+	}
+
+	if float64(nTX) >= expected {
+		var su, id, tx10, tx90 float64
+		txx, txn := -math.MaxFloat64, math.MaxFloat64
+		for _, d := range days {
+			if math.IsNaN(d.TX) {
+				continue
+			}
+			cday := calendarDay(d.Date)
+			if d.TX > 25 {
+				su++
+			}
+			if d.TX < 0 {
+				id++
+			}
+			if d.TX < t.tx10[cday] {
+				tx10++
+			}
+			if d.TX > t.tx90[cday] {
+				tx90++
+			}
+			txx = math.Max(txx, d.TX)
+			txn = math.Min(txn, d.TX)
+		}
+		values[IndexSU] = su
+		values[IndexID] = id
+		values[IndexTXx] = txx
+		values[IndexTXn] = txn
+		values[IndexTX10p] = 100 * tx10 / float64(nTX)
+		values[IndexTX90p] = 100 * tx90 / float64(nTX)
+		values[IndexWSDI] = spellDays(days, func(d DailyRecord) bool {
+			return d.TX > t.tx90[calendarDay(d.Date)]
+		})
+	}
+
+	if float64(nDTR) >= expected {
+		sum := 0.0
+		for _, d := range days {
+			if !math.IsNaN(d.TN) && !math.IsNaN(d.TX) {
+				sum += d.TX - d.TN
+			}
+		}
+		values[IndexDTR] = sum / float64(nDTR)
+		// the growing season is only defined over a calendar year
+		if period.Kind == CalendarYear {
+			values[IndexGSL] = growingSeasonLength(days)
+		}
+	}
+
+	if float64(nRR) >= expected {
+		var r10, r20, r25, wetDays, wetSum, r95, r99 float64
+		rx1 := 0.0
+		for _, d := range days {
+			if math.IsNaN(d.RR) {
+				continue
+			}
+			rx1 = math.Max(rx1, d.RR)
+			if d.RR >= 10 {
+				r10++
+			}
+			if d.RR >= 20 {
+				r20++
+			}
+			if d.RR >= 25 {
+				r25++
+			}
+			if d.RR >= wetDayThreshold {
+				wetDays++
+				wetSum += d.RR
+				if d.RR > t.r95 {
+					r95 += d.RR
+				}
+				if d.RR > t.r99 {
+					r99 += d.RR
+				}
+			}
+		}
+		values[IndexRx1day] = rx1
+		values[IndexRx5day] = maxRunningSum(days, 5)
+		values[IndexR10mm] = r10
+		values[IndexR20mm] = r20
+		values[IndexR25mm] = r25
+		values[IndexPRCPTOT] = wetSum
+		values[IndexR95pTOT] = r95
+		values[IndexR99pTOT] = r99
+		if wetDays > 0 {
+			values[IndexSDII] = wetSum / wetDays
+		} else {
+			values[IndexSDII] = 0
+		}
+		values[IndexCDD] = longestRun(days, func(d DailyRecord) bool { return d.RR < wetDayThreshold })
+		values[IndexCWD] = longestRun(days, func(d DailyRecord) bool { return d.RR >= wetDayThreshold })
+	}
+
+	return result
+}
+
+// computeThresholds derives the calendar-day percentiles (5-day window) and
+// the wet-day percentiles from the 1961-1990 base period, or from the whole
+// record when the base period is too short.
+func computeThresholds(days []DailyRecord) *indexThresholds {
+	inBase := func(d DailyRecord) bool {
+		return d.Date.Year() >= baseStartYear && d.Date.Year() <= baseEndYear
+	}
+	baseYears := make(map[int]bool)
+	for _, d := range days {
+		if inBase(d) {
+			baseYears[d.Date.Year()] = true
+		}
+	}
+	if len(baseYears) < minBaseYears {
+		inBase = func(d DailyRecord) bool { return true }
+	}
+
+	var tnWindow, txWindow [366][]float64
+	wet := make([]float64, 0, 5000)
+	for _, d := range days {
+		if !inBase(d) {
+			continue
+		}
+		cday := calendarDay(d.Date)
+		for offset := -2; offset <= 2; offset++ {
+			w := (cday + offset + 366) % 366
+			if !math.IsNaN(d.TN) {
+				tnWindow[w] = append(tnWindow[w], d.TN)
+			}
+			if !math.IsNaN(d.TX) {
+				txWindow[w] = append(txWindow[w], d.TX)
+			}
+		}
+		if !math.IsNaN(d.RR) && d.RR >= wetDayThreshold {
+			wet = append(wet, d.RR)
+		}
+	}
+
+	t := &indexThresholds{}
+	for i := range 366 {
+		slices.Sort(tnWindow[i])
+		slices.Sort(txWindow[i])
+		t.tn10[i] = percentile(tnWindow[i], 0.1)
+		t.tn90[i] = percentile(tnWindow[i], 0.9)
+		t.tx10[i] = percentile(txWindow[i], 0.1)
+		t.tx90[i] = percentile(txWindow[i], 0.9)
+	}
+	slices.Sort(wet)
+	t.r95 = percentile(wet, 0.95)
+	t.r99 = percentile(wet, 0.99)
+	return t
+}
+
+// growingSeasonLength counts the days between the first span of 6 days with
+// TG > 5°C and the first span of 6 days with TG < 5°C after July 1st.
+func growingSeasonLength(days []DailyRecord) float64 {
+	start, end := -1, len(days)
+	run := 0
+	for i, d := range days {
+		tg := (d.TN + d.TX) / 2
+		if start < 0 {
+			if tg > 5 {
+				run++
+				if run == spellMinDuration {
+					start = i - spellMinDuration + 1
+					run = 0
+				}
+			} else {
+				run = 0
+			}
+			continue
+		}
+		if d.Date.Month() < time.July {
+			continue
+		}
+		if tg < 5 {
+			run++
+			if run == spellMinDuration {
+				end = i - spellMinDuration + 1
+				break
+			}
+		} else {
+			run = 0
+		}
+	}
+	if start < 0 {
+		return 0
+	}
+	return float64(end - start)
+}
+
+// spellDays counts the days belonging to runs of at least 6 consecutive days
+// matching the predicate.
+func spellDays(days []DailyRecord, match func(d DailyRecord) bool) float64 {
+	total, run := 0, 0
+	for _, d := range days {
+		if match(d) {
+			run++
+			continue
+		}
+		if run >= spellMinDuration {
+			total += run
+		}
+		run = 0
+	}
+	if run >= spellMinDuration {
+		total += run
+	}
+	return float64(total)
+}
+
+func longestRun(days []DailyRecord, match func(d DailyRecord) bool) float64 {
+	longest, run := 0, 0
+	for _, d := range days {
+		if !math.IsNaN(d.RR) && match(d) {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return float64(longest)
+}
+
+// maxRunningSum returns the largest rain over window consecutive days, the
+// windows missing a day being left out.
+func maxRunningSum(days []DailyRecord, window int) float64 {
+	best := 0.0
+	for i := 0; i+window <= len(days); i++ {
+		if !days[i+window-1].Date.Equal(days[i].Date.AddDate(0, 0, window-1)) {
+			continue
+		}
+		sum := 0.0
+		valid := true
+		for _, d := range days[i : i+window] {
+			if math.IsNaN(d.RR) {
+				valid = false
+				break
+			}
+			sum += d.RR
+		}
+		if valid {
+			best = math.Max(best, sum)
+		}
+	}
+	return best
+}
+
+// percentile expects sorted values and interpolates linearly between ranks.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// calendarDay maps a date onto a 366-day calendar so that February 29th
+// keeps its own slot.
+func calendarDay(t time.Time) int {
+	return time.Date(2000, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).YearDay() - 1
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testYear builds the days of 2001: a frosty January whose first five days
+// don't thaw, ten tropical nights in July, twenty summer days in August and
+// ten cold days in November, the diurnal range staying 6°C. It rains from
+// March 10th to 14th and from June 1st to 3rd.
+func testYear() []DailyRecord {
+	rain := map[string]float64{
+		"0310": 5, "0311": 10, "0312": 20, "0313": 25, "0314": 2,
+		"0601": 30, "0602": 1, "0603": 1,
+	}
+	var days []DailyRecord
+	for t := time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC); t.Year() == 2001; t = t.AddDate(0, 0, 1) {
+		tn := 8.0
+		switch {
+		case t.Month() == time.January && t.Day() <= 5:
+			tn = -8
+		case t.Month() == time.January:
+			tn = -3
+		case t.Month() == time.July && t.Day() <= 10:
+			tn = 22
+		case t.Month() == time.August && t.Day() <= 20:
+			tn = 20
+		case t.Month() == time.November && t.Day() <= 10:
+			tn = -1
+		}
+		days = append(days, DailyRecord{Date: t, RR: rain[t.Format("0102")], TN: tn, TX: tn + 6})
+	}
+	return days
+}
+
+func TestClimateIndices(t *testing.T) {
+	indices := ComputeClimateIndices(testYear(), CalendarYearPeriod)
+	if len(indices) != 1 || !indices[0].Start.Equal(time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ComputeClimateIndices() = %+v, want the year 2001", indices)
+	}
+	tests := []struct {
+		index ClimateIndex
+		want  float64
+	}{
+		{IndexFD, 41},
+		{IndexSU, 30},
+		{IndexID, 5},
+		{IndexTR, 10},
+		// from February 1st to November 1st
+		{IndexGSL, 273},
+		{IndexTXx, 28},
+		{IndexTNx, 22},
+		{IndexTXn, -2},
+		{IndexTNn, -8},
+		{IndexDTR, 6},
+		{IndexRx1day, 30},
+		{IndexRx5day, 62},
+		{IndexSDII, 11.75},
+		{IndexR10mm, 4},
+		{IndexR20mm, 3},
+		{IndexR25mm, 2},
+		// from June 4th to December 31st
+		{IndexCDD, 211},
+		{IndexCWD, 5},
+		{IndexPRCPTOT, 94},
+	}
+	for _, test := range tests {
+		if got := indices[0].Values[test.index]; math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", test.index, got, test.want)
+		}
+	}
+}
+
+func TestClimateIndicesMissingDays(t *testing.T) {
+	tests := []struct {
+		name string
+		// missing are the days left out of testYear, as MMDD
+		missing []string
+		index   ClimateIndex
+		want    float64
+	}{
+		// no five days in a row hold the March rain
+		{"Rx5day across a gap", []string{"0312"}, IndexRx5day, 32},
+		{"Rx5day next to a gap", []string{"0316"}, IndexRx5day, 62},
+		{"too many missing days", []string{"0101", "0102", "0103", "0104", "0105", "0106", "0107", "0108", "0109", "0110",
+			"0111", "0112", "0113", "0114", "0115", "0116", "0117", "0118", "0119"}, IndexRx1day, math.NaN()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var days []DailyRecord
+			for _, d := range testYear() {
+				kept := true
+				for _, m := range test.missing {
+					kept = kept && d.Date.Format("0102") != m
+				}
+				if kept {
+					days = append(days, d)
+				}
+			}
+			got := ComputeClimateIndices(days, CalendarYearPeriod)[0].Values[test.index]
+			if got != test.want && !(math.IsNaN(got) && math.IsNaN(test.want)) {
+				t.Errorf("%s = %v, want %v", test.index, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		p, want float64
+	}{
+		{0, 1},
+		{0.1, 1.4},
+		{0.5, 3},
+		{0.9, 4.6},
+		{1, 5},
+	}
+	for _, test := range tests {
+		if got := percentile(sorted, test.p); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("percentile(%v) = %v, want %v", test.p, got, test.want)
+		}
+	}
+	if got := percentile(nil, 0.5); !math.IsNaN(got) {
+		t.Errorf("percentile of no value = %v, want NaN", got)
+	}
+}
@@ -1,20 +1,14 @@
 package data
 
-import (
-	"math"
-)
-
 type Coordinate []float64
 
-type FranceGeoJSON struct {
-	Geometry struct {
-		Coordinates [][][]Coordinate `json:"coordinates"`
-	} `json:"geometry"`
-}
+// FranceGeoJSONPath is the metropolitan France outline of
+// github.com/gregoiredavid/france-geojson.
+const FranceGeoJSONPath = "./data/geo/metropole-version-simplifiee.geojson"
 
 type GeoData struct {
-	FranceGeoJSON
-	Bounds *Bounds
+	Outline *FeatureCollection
+	Bounds  *Bounds
 }
 
 type Bounds struct {
@@ -22,35 +16,23 @@ type Bounds struct {
 }
 
 func (g *GeoData) ComputeBounds() *Bounds {
-	bounds := &Bounds{
-		MinLong: math.MaxFloat64,
-		MaxLong: -math.MaxFloat64,
-		MinLat:  math.MaxFloat64,
-		MaxLat:  -math.MaxFloat64,
+	bounds := emptyBounds()
+	if g.Outline != nil {
+		bounds = g.Outline.Bounds()
 	}
+	return &bounds
+}
 
-	for i := range g.Geometry.Coordinates {
-		for j := range g.Geometry.Coordinates[i] {
-			for k := range g.Geometry.Coordinates[i][j] {
-				coordinate := g.Geometry.Coordinates[i][j][k]
-
-				currentLong := coordinate[0]
-				currentLat := coordinate[1]
-
-				if currentLong > bounds.MaxLong {
-					bounds.MaxLong = currentLong
-				} else if currentLong < bounds.MinLong {
-					bounds.MinLong = currentLong
-				}
+// HasOutline tells whether an outline was loaded, points being kept whatever
+// their position otherwise.
+func (g *GeoData) HasOutline() bool {
+	return g.Outline != nil && len(g.Outline.Features) > 0
+}
 
-				if currentLat > bounds.MaxLat {
-					bounds.MaxLat = currentLat
-				} else if currentLat < bounds.MinLat {
-					bounds.MinLat = currentLat
-				}
-			}
-		}
+// Contains tells whether the point lies inside the outline (even-odd rule).
+func (g *GeoData) Contains(lon, lat float64) bool {
+	if g.Outline == nil {
+		return false
 	}
-
-	return bounds
+	return g.Outline.Contains(lon, lat)
 }
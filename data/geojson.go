@@ -0,0 +1,366 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// GeometryType is one of the geometry types of RFC 7946.
+type GeometryType string
+
+const (
+	PointGeometry              GeometryType = "Point"
+	MultiPointGeometry         GeometryType = "MultiPoint"
+	LineStringGeometry         GeometryType = "LineString"
+	MultiLineStringGeometry    GeometryType = "MultiLineString"
+	PolygonGeometry            GeometryType = "Polygon"
+	MultiPolygonGeometry       GeometryType = "MultiPolygon"
+	GeometryCollectionGeometry GeometryType = "GeometryCollection"
+)
+
+// Geometry holds the coordinates of a GeoJSON geometry. Only the field
+// matching Type is filled; every geometry is also available as lines, rings
+// or points through the helpers below.
+type Geometry struct {
+	Type            GeometryType
+	Point           Coordinate
+	MultiPoint      []Coordinate
+	LineString      []Coordinate
+	MultiLineString [][]Coordinate
+	Polygon         [][]Coordinate
+	MultiPolygon    [][][]Coordinate
+	Geometries      []Geometry
+}
+
+type Feature struct {
+	ID         any
+	Properties map[string]any
+	Geometry   *Geometry
+}
+
+type FeatureCollection struct {
+	Features []Feature
+}
+
+var errGeoJSONType = errors.New("type GeoJSON inconnu")
+
+type rawGeoJSON struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []json.RawMessage `json:"geometries"`
+	Geometry    json.RawMessage   `json:"geometry"`
+	Properties  map[string]any    `json:"properties"`
+	ID          any               `json:"id"`
+	Features    []json.RawMessage `json:"features"`
+}
+
+// LoadGeoJSON reads a FeatureCollection, a Feature or a bare geometry; the
+// two latter are wrapped into a collection.
+func LoadGeoJSON(path string) (*FeatureCollection, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGeoJSON(file)
+}
+
+func ParseGeoJSON(content []byte) (*FeatureCollection, error) {
+	raw := rawGeoJSON{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+	switch raw.Type {
+	case "FeatureCollection":
+		collection := &FeatureCollection{Features: make([]Feature, 0, len(raw.Features))}
+		for i, f := range raw.Features {
+			rawFeature := rawGeoJSON{}
+			if err := json.Unmarshal(f, &rawFeature); err != nil {
+				return nil, fmt.Errorf("objet %d : %w", i, err)
+			}
+			feature, err := parseFeature(rawFeature)
+			if err != nil {
+				return nil, fmt.Errorf("objet %d : %w", i, err)
+			}
+			collection.Features = append(collection.Features, feature)
+		}
+		return collection, nil
+	case "Feature":
+		feature, err := parseFeature(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &FeatureCollection{Features: []Feature{feature}}, nil
+	default:
+		geometry, err := parseGeometry(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &FeatureCollection{Features: []Feature{{Geometry: geometry}}}, nil
+	}
+}
+
+func parseFeature(raw rawGeoJSON) (Feature, error) {
+	if raw.Type != "Feature" {
+		return Feature{}, fmt.Errorf("%w : %s", errGeoJSONType, raw.Type)
+	}
+	feature := Feature{ID: raw.ID, Properties: raw.Properties}
+	// a feature without geometry is valid and has a null geometry
+	if len(raw.Geometry) == 0 || string(raw.Geometry) == "null" {
+		return feature, nil
+	}
+	rawGeometry := rawGeoJSON{}
+	if err := json.Unmarshal(raw.Geometry, &rawGeometry); err != nil {
+		return Feature{}, err
+	}
+	geometry, err := parseGeometry(rawGeometry)
+	if err != nil {
+		return Feature{}, err
+	}
+	feature.Geometry = geometry
+	return feature, nil
+}
+
+func parseGeometry(raw rawGeoJSON) (*Geometry, error) {
+	g := &Geometry{Type: GeometryType(raw.Type)}
+	var target any
+	switch g.Type {
+	case PointGeometry:
+		target = &g.Point
+	case MultiPointGeometry:
+		target = &g.MultiPoint
+	case LineStringGeometry:
+		target = &g.LineString
+	case MultiLineStringGeometry:
+		target = &g.MultiLineString
+	case PolygonGeometry:
+		target = &g.Polygon
+	case MultiPolygonGeometry:
+		target = &g.MultiPolygon
+	case GeometryCollectionGeometry:
+		for _, r := range raw.Geometries {
+			rawChild := rawGeoJSON{}
+			if err := json.Unmarshal(r, &rawChild); err != nil {
+				return nil, err
+			}
+			child, err := parseGeometry(rawChild)
+			if err != nil {
+				return nil, err
+			}
+			g.Geometries = append(g.Geometries, *child)
+		}
+		return g, nil
+	default:
+		return nil, fmt.Errorf("%w : %s", errGeoJSONType, raw.Type)
+	}
+	if err := json.Unmarshal(raw.Coordinates, target); err != nil {
+		return nil, fmt.Errorf("géométrie %s : %w", g.Type, err)
+	}
+	if err := g.validate(); err != nil {
+		return nil, fmt.Errorf("géométrie %s : %w", g.Type, err)
+	}
+	return g, nil
+}
+
+// validate checks that every position has a longitude and a latitude, so
+// that the geometry can be indexed without further checks.
+func (g *Geometry) validate() error {
+	positions := g.Points()
+	for _, line := range g.Lines() {
+		positions = append(positions, line...)
+	}
+	for _, polygon := range g.Polygons() {
+		for _, ring := range polygon {
+			positions = append(positions, ring...)
+		}
+	}
+	for i, c := range positions {
+		if len(c) < 2 {
+			return fmt.Errorf("position %d : %d coordonnée(s) au lieu d'au moins 2", i, len(c))
+		}
+		if math.IsNaN(c[0]) || math.IsInf(c[0], 0) || math.IsNaN(c[1]) || math.IsInf(c[1], 0) {
+			return fmt.Errorf("position %d : coordonnées non finies", i)
+		}
+	}
+	return nil
+}
+
+// Points returns the points of Point and MultiPoint geometries.
+func (g *Geometry) Points() []Coordinate {
+	switch g.Type {
+	case PointGeometry:
+		return []Coordinate{g.Point}
+	case MultiPointGeometry:
+		return g.MultiPoint
+	case GeometryCollectionGeometry:
+		points := make([]Coordinate, 0)
+		for i := range g.Geometries {
+			points = append(points, g.Geometries[i].Points()...)
+		}
+		return points
+	}
+	return nil
+}
+
+// Lines returns the lines of LineString and MultiLineString geometries.
+func (g *Geometry) Lines() [][]Coordinate {
+	switch g.Type {
+	case LineStringGeometry:
+		return [][]Coordinate{g.LineString}
+	case MultiLineStringGeometry:
+		return g.MultiLineString
+	case GeometryCollectionGeometry:
+		lines := make([][]Coordinate, 0)
+		for i := range g.Geometries {
+			lines = append(lines, g.Geometries[i].Lines()...)
+		}
+		return lines
+	}
+	return nil
+}
+
+// Polygons returns the polygons of Polygon and MultiPolygon geometries, each
+// polygon being its exterior ring followed by its holes.
+func (g *Geometry) Polygons() [][][]Coordinate {
+	switch g.Type {
+	case PolygonGeometry:
+		return [][][]Coordinate{g.Polygon}
+	case MultiPolygonGeometry:
+		return g.MultiPolygon
+	case GeometryCollectionGeometry:
+		polygons := make([][][]Coordinate, 0)
+		for i := range g.Geometries {
+			polygons = append(polygons, g.Geometries[i].Polygons()...)
+		}
+		return polygons
+	}
+	return nil
+}
+
+// Contains tells whether the point lies inside the polygons of the geometry
+// (even-odd rule, so that holes are excluded).
+func (g *Geometry) Contains(lon, lat float64) bool {
+	inside := false
+	for _, polygon := range g.Polygons() {
+		for _, ring := range polygon {
+			for k, prev := 0, len(ring)-1; k < len(ring); prev, k = k, k+1 {
+				xi, yi := ring[k][0], ring[k][1]
+				xj, yj := ring[prev][0], ring[prev][1]
+				if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+					inside = !inside
+				}
+			}
+		}
+	}
+	return inside
+}
+
+func emptyBounds() Bounds {
+	return Bounds{
+		MinLong: math.MaxFloat64,
+		MaxLong: -math.MaxFloat64,
+		MinLat:  math.MaxFloat64,
+		MaxLat:  -math.MaxFloat64,
+	}
+}
+
+func (b *Bounds) extend(c Coordinate) {
+	if len(c) < 2 {
+		return
+	}
+	b.MinLong = math.Min(b.MinLong, c[0])
+	b.MaxLong = math.Max(b.MaxLong, c[0])
+	b.MinLat = math.Min(b.MinLat, c[1])
+	b.MaxLat = math.Max(b.MaxLat, c[1])
+}
+
+// IsEmpty tells whether the bounds contain no point at all.
+func (b Bounds) IsEmpty() bool {
+	return b.MinLong > b.MaxLong || b.MinLat > b.MaxLat
+}
+
+func (b Bounds) contains(lon, lat float64) bool {
+	return lon >= b.MinLong && lon <= b.MaxLong && lat >= b.MinLat && lat <= b.MaxLat
+}
+
+func (g *Geometry) extendBounds(b *Bounds) {
+	for _, p := range g.Points() {
+		b.extend(p)
+	}
+	for _, line := range g.Lines() {
+		for _, c := range line {
+			b.extend(c)
+		}
+	}
+	for _, polygon := range g.Polygons() {
+		// holes lie inside the exterior ring
+		if len(polygon) > 0 {
+			for _, c := range polygon[0] {
+				b.extend(c)
+			}
+		}
+	}
+}
+
+func (g *Geometry) Bounds() Bounds {
+	bounds := emptyBounds()
+	g.extendBounds(&bounds)
+	return bounds
+}
+
+func (c *FeatureCollection) Bounds() Bounds {
+	bounds := emptyBounds()
+	for _, f := range c.Features {
+		if f.Geometry != nil {
+			f.Geometry.extendBounds(&bounds)
+		}
+	}
+	return bounds
+}
+
+// Property returns the first property of the feature found among the keys,
+// formatted as text.
+func (f *Feature) Property(keys ...string) (string, bool) {
+	for _, key := range keys {
+		if v, ok := f.Properties[key]; ok && v != nil {
+			if number, ok := v.(float64); ok && number == math.Trunc(number) {
+				return fmt.Sprintf("%.0f", number), true
+			}
+			return fmt.Sprint(v), true
+		}
+	}
+	return "", false
+}
+
+// Name guesses the name of the feature from the usual property names.
+func (f *Feature) Name() string {
+	if name, ok := f.Property("nom", "name", "NOM", "Name", "NAME", "libelle", "LIBELLE", "label"); ok {
+		return name
+	}
+	if code, ok := f.Property("code", "CODE", "id"); ok {
+		return code
+	}
+	if f.ID != nil {
+		return fmt.Sprint(f.ID)
+	}
+	return ""
+}
+
+// Contains tells whether the point lies inside one of the polygons of the
+// collection.
+func (c *FeatureCollection) Contains(lon, lat float64) bool {
+	_, ok := c.FeatureAt(lon, lat)
+	return ok
+}
+
+// FeatureAt returns the first feature whose polygons contain the point.
+func (c *FeatureCollection) FeatureAt(lon, lat float64) (*Feature, bool) {
+	for i := range c.Features {
+		if g := c.Features[i].Geometry; g != nil && g.Contains(lon, lat) {
+			return &c.Features[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,395 @@
+package data
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/tiff/lzw"
+)
+
+var errGeoTIFFFormat = errors.New("fichier GeoTIFF invalide")
+
+// TIFF tags
+const (
+	tagImageWidth       = 256
+	tagImageLength      = 257
+	tagBitsPerSample    = 258
+	tagCompression      = 259
+	tagStripOffsets     = 273
+	tagSamplesPerPixel  = 277
+	tagRowsPerStrip     = 278
+	tagStripByteCounts  = 279
+	tagPlanarConfig     = 284
+	tagPredictor        = 317
+	tagTileWidth        = 322
+	tagTileLength       = 323
+	tagTileOffsets      = 324
+	tagTileByteCounts   = 325
+	tagSampleFormat     = 339
+	tagModelPixelScale  = 33550
+	tagModelTiepoint    = 33922
+	tagModelTransform   = 34264
+	tagGeoKeyDirectory  = 34735
+	tagGDALNoData       = 42113
+	geoKeyModelType     = 1024
+	geoKeyRasterType    = 1025
+	geoKeyProjectedType = 3072
+)
+
+const (
+	compressionNone     = 1
+	compressionLZW      = 5
+	compressionDeflate  = 8
+	compressionPackBits = 32773
+	compressionZlib     = 32946
+
+	sampleUnsigned = 1
+	sampleSigned   = 2
+	sampleFloat    = 3
+
+	predictorHorizontal = 2
+	predictorFloat      = 3
+)
+
+// tiffFile reads the first image of a TIFF file with a single sample per
+// pixel, enough for the elevation grids of digital elevation models.
+type tiffFile struct {
+	file  *os.File
+	order binary.ByteOrder
+	tags  map[uint16]tiffTag
+}
+
+type tiffTag struct {
+	kind  uint16
+	count uint32
+	data  []byte
+}
+
+// sizes of the TIFF field types, by type
+var tiffTypeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+func openTIFF(file *os.File) (*tiffFile, error) {
+	header := make([]byte, 8)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, errGeoTIFFFormat
+	}
+	t := &tiffFile{file: file, tags: make(map[uint16]tiffTag)}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errGeoTIFFFormat
+	}
+	switch t.order.Uint16(header[2:]) {
+	case 42:
+	case 43:
+		return nil, fmt.Errorf("%w : les fichiers BigTIFF ne sont pas pris en charge", errGeoTIFFFormat)
+	default:
+		return nil, errGeoTIFFFormat
+	}
+
+	offset := int64(t.order.Uint32(header[4:]))
+	count := make([]byte, 2)
+	if _, err := file.ReadAt(count, offset); err != nil {
+		return nil, errGeoTIFFFormat
+	}
+	entries := make([]byte, 12*int(t.order.Uint16(count)))
+	if _, err := file.ReadAt(entries, offset+2); err != nil {
+		return nil, errGeoTIFFFormat
+	}
+	for e := 0; e < len(entries); e += 12 {
+		entry := entries[e : e+12]
+		tag := tiffTag{kind: t.order.Uint16(entry[2:]), count: t.order.Uint32(entry[4:])}
+		if int(tag.kind) >= len(tiffTypeSizes) {
+			continue
+		}
+		size := int64(tiffTypeSizes[tag.kind]) * int64(tag.count)
+		if size <= 4 {
+			tag.data = entry[8 : 8+size]
+		} else {
+			if size > 1<<28 {
+				return nil, errGeoTIFFFormat
+			}
+			tag.data = make([]byte, size)
+			if _, err := file.ReadAt(tag.data, int64(t.order.Uint32(entry[8:]))); err != nil {
+				return nil, errGeoTIFFFormat
+			}
+		}
+		t.tags[t.order.Uint16(entry)] = tag
+	}
+	return t, nil
+}
+
+// uints returns the values of an integer tag.
+func (t *tiffFile) uints(id uint16) []uint64 {
+	tag, ok := t.tags[id]
+	if !ok {
+		return nil
+	}
+	values := make([]uint64, tag.count)
+	for i := range values {
+		switch tag.kind {
+		case 1, 7:
+			values[i] = uint64(tag.data[i])
+		case 3:
+			values[i] = uint64(t.order.Uint16(tag.data[2*i:]))
+		case 4:
+			values[i] = uint64(t.order.Uint32(tag.data[4*i:]))
+		default:
+			return nil
+		}
+	}
+	return values
+}
+
+func (t *tiffFile) uint(id uint16, defaultValue uint64) uint64 {
+	if values := t.uints(id); len(values) > 0 {
+		return values[0]
+	}
+	return defaultValue
+}
+
+// floats returns the values of a DOUBLE tag.
+func (t *tiffFile) floats(id uint16) []float64 {
+	tag, ok := t.tags[id]
+	if !ok || tag.kind != 12 {
+		return nil
+	}
+	values := make([]float64, tag.count)
+	for i := range values {
+		values[i] = math.Float64frombits(t.order.Uint64(tag.data[8*i:]))
+	}
+	return values
+}
+
+func (t *tiffFile) ascii(id uint16) string {
+	tag, ok := t.tags[id]
+	if !ok || tag.kind != 2 {
+		return ""
+	}
+	return strings.TrimRight(string(tag.data), "\x00")
+}
+
+// geoKeys returns the short values of the GeoTIFF keys.
+func (t *tiffFile) geoKeys() map[uint16]uint16 {
+	keys := make(map[uint16]uint16)
+	directory := t.uints(tagGeoKeyDirectory)
+	for i := 4; i+3 < len(directory); i += 4 {
+		// keys stored in another tag are not short values
+		if directory[i+1] == 0 {
+			keys[uint16(directory[i])] = uint16(directory[i+3])
+		}
+	}
+	return keys
+}
+
+// tiffLayout describes how the samples are stored: in strips, or in tiles
+// when tiled, of chunkWidth × chunkHeight samples.
+type tiffLayout struct {
+	width, height           int
+	chunkWidth, chunkHeight int
+	offsets, byteCounts     []uint64
+	compression             uint64
+	predictor               uint64
+	bytesPerSample          int
+	sampleFormat            uint64
+}
+
+func (t *tiffFile) layout() (tiffLayout, error) {
+	l := tiffLayout{
+		width:          int(t.uint(tagImageWidth, 0)),
+		height:         int(t.uint(tagImageLength, 0)),
+		compression:    t.uint(tagCompression, compressionNone),
+		predictor:      t.uint(tagPredictor, 1),
+		bytesPerSample: int(t.uint(tagBitsPerSample, 1)) / 8,
+		sampleFormat:   t.uint(tagSampleFormat, sampleUnsigned),
+	}
+	if l.width <= 0 || l.height <= 0 {
+		return l, errGeoTIFFFormat
+	}
+	if t.uint(tagSamplesPerPixel, 1) != 1 && t.uint(tagPlanarConfig, 1) != 2 {
+		return l, fmt.Errorf("%w : une seule bande d'altitude est attendue", errGeoTIFFFormat)
+	}
+	switch {
+	case l.sampleFormat == sampleFloat && (l.bytesPerSample == 4 || l.bytesPerSample == 8):
+	case l.sampleFormat != sampleFloat && (l.bytesPerSample == 1 || l.bytesPerSample == 2 || l.bytesPerSample == 4):
+	default:
+		return l, fmt.Errorf("%w : échantillons de %d octets non pris en charge", errGeoTIFFFormat, l.bytesPerSample)
+	}
+
+	if _, tiled := t.tags[tagTileWidth]; tiled {
+		l.chunkWidth = int(t.uint(tagTileWidth, 0))
+		l.chunkHeight = int(t.uint(tagTileLength, 0))
+		l.offsets, l.byteCounts = t.uints(tagTileOffsets), t.uints(tagTileByteCounts)
+	} else {
+		l.chunkWidth = l.width
+		l.chunkHeight = min(l.height, int(t.uint(tagRowsPerStrip, uint64(l.height))))
+		l.offsets, l.byteCounts = t.uints(tagStripOffsets), t.uints(tagStripByteCounts)
+	}
+	chunksAcross := (l.width + l.chunkWidth - 1) / max(1, l.chunkWidth)
+	chunksDown := (l.height + l.chunkHeight - 1) / max(1, l.chunkHeight)
+	// with separate planes, the first plane holds the elevations
+	if l.chunkWidth <= 0 || l.chunkHeight <= 0 || len(l.offsets) < chunksAcross*chunksDown || len(l.byteCounts) < len(l.offsets) {
+		return l, errGeoTIFFFormat
+	}
+	return l, nil
+}
+
+func (l tiffLayout) chunksAcross() int {
+	return (l.width + l.chunkWidth - 1) / l.chunkWidth
+}
+
+func (l tiffLayout) chunksDown() int {
+	return (l.height + l.chunkHeight - 1) / l.chunkHeight
+}
+
+// chunk reads the samples of the i-th strip or tile, row by row.
+func (t *tiffFile) chunk(l tiffLayout, i int) ([]float64, error) {
+	if l.byteCounts[i] > 1<<30 {
+		return nil, errGeoTIFFFormat
+	}
+	compressed := make([]byte, l.byteCounts[i])
+	if _, err := t.file.ReadAt(compressed, int64(l.offsets[i])); err != nil {
+		return nil, fmt.Errorf("%w : %w", errGeoTIFFFormat, err)
+	}
+	var raw []byte
+	var err error
+	switch l.compression {
+	case compressionNone:
+		raw = compressed
+	case compressionLZW:
+		raw, err = io.ReadAll(lzw.NewReader(bytes.NewReader(compressed), lzw.MSB, 8))
+	case compressionDeflate, compressionZlib:
+		var r io.ReadCloser
+		if r, err = zlib.NewReader(bytes.NewReader(compressed)); err == nil {
+			raw, err = io.ReadAll(r)
+			r.Close()
+		}
+	case compressionPackBits:
+		raw, err = unpackBits(compressed)
+	default:
+		return nil, fmt.Errorf("%w : compression %d non prise en charge", errGeoTIFFFormat, l.compression)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w : %w", errGeoTIFFFormat, err)
+	}
+
+	rowSize := l.chunkWidth * l.bytesPerSample
+	rows := len(raw) / rowSize
+	order := t.order
+	for r := range rows {
+		row := raw[r*rowSize : (r+1)*rowSize]
+		switch l.predictor {
+		case predictorHorizontal:
+			undoHorizontalPredictor(row, l.bytesPerSample, order)
+		case predictorFloat:
+			undoFloatPredictor(row, l.bytesPerSample)
+			// the bytes of the samples are then from the most significant
+			order = binary.BigEndian
+		}
+	}
+
+	samples := make([]float64, rows*l.chunkWidth)
+	for s := range samples {
+		samples[s] = l.sample(raw[s*l.bytesPerSample:], order)
+	}
+	return samples, nil
+}
+
+func (l tiffLayout) sample(b []byte, order binary.ByteOrder) float64 {
+	switch l.bytesPerSample {
+	case 1:
+		if l.sampleFormat == sampleSigned {
+			return float64(int8(b[0]))
+		}
+		return float64(b[0])
+	case 2:
+		if l.sampleFormat == sampleSigned {
+			return float64(int16(order.Uint16(b)))
+		}
+		return float64(order.Uint16(b))
+	case 4:
+		switch l.sampleFormat {
+		case sampleFloat:
+			return float64(math.Float32frombits(order.Uint32(b)))
+		case sampleSigned:
+			return float64(int32(order.Uint32(b)))
+		}
+		return float64(order.Uint32(b))
+	}
+	return math.Float64frombits(order.Uint64(b))
+}
+
+// undoHorizontalPredictor turns the differences between neighbouring samples
+// of a row back into samples.
+func undoHorizontalPredictor(row []byte, size int, order binary.ByteOrder) {
+	for i := size; i+size <= len(row); i += size {
+		switch size {
+		case 1:
+			row[i] += row[i-1]
+		case 2:
+			order.PutUint16(row[i:], order.Uint16(row[i:])+order.Uint16(row[i-2:]))
+		case 4:
+			order.PutUint32(row[i:], order.Uint32(row[i:])+order.Uint32(row[i-4:]))
+		case 8:
+			order.PutUint64(row[i:], order.Uint64(row[i:])+order.Uint64(row[i-8:]))
+		}
+	}
+}
+
+// undoFloatPredictor undoes the floating point predictor, which stores the
+// differences between the bytes of a row, the most significant bytes of all
+// the samples first.
+func undoFloatPredictor(row []byte, size int) {
+	for i := 1; i < len(row); i++ {
+		row[i] += row[i-1]
+	}
+	count := len(row) / size
+	planes := bytes.Clone(row)
+	for s := range count {
+		for b := range size {
+			row[s*size+b] = planes[b*count+s]
+		}
+	}
+}
+
+func unpackBits(b []byte) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(b); {
+		n := int(int8(b[i]))
+		i++
+		switch {
+		case n >= 0:
+			if i+n+1 > len(b) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			out = append(out, b[i:i+n+1]...)
+			i += n + 1
+		case n > -128:
+			if i >= len(b) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			out = append(out, bytes.Repeat(b[i:i+1], 1-n)...)
+			i++
+		}
+	}
+	return out, nil
+}
+
+// noData returns the value marking unknown samples, NaN when there is none.
+func (t *tiffFile) noData() float64 {
+	if v, err := strconv.ParseFloat(strings.TrimSpace(t.ascii(tagGDALNoData)), 64); err == nil {
+		return v
+	}
+	return math.NaN()
+}
@@ -0,0 +1,349 @@
+package data
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testTIFF writes a single band GeoTIFF file for the tests.
+type testTIFF struct {
+	order interface {
+		binary.ByteOrder
+		binary.AppendByteOrder
+	}
+	width, height int
+	bits          int
+	format        uint16
+	compression   uint16
+	predictor     uint16
+	// tile is the size of the tiles, the file being made of strips of
+	// rowsPerStrip rows when it is 0
+	tile         int
+	rowsPerStrip int
+
+	modelType, rasterType, epsg uint16
+	transform                   []float64
+	tiepoint, scale             []float64
+	noData                      string
+}
+
+type tiffEntry struct {
+	tag, kind uint16
+	count     int
+	data      []byte
+}
+
+func (tt testTIFF) putSample(b []byte, v float64) {
+	switch {
+	case tt.format == sampleFloat && tt.bits == 32:
+		tt.order.PutUint32(b, math.Float32bits(float32(v)))
+	case tt.format == sampleFloat:
+		tt.order.PutUint64(b, math.Float64bits(v))
+	case tt.bits == 8:
+		b[0] = byte(int64(v))
+	case tt.bits == 16:
+		tt.order.PutUint16(b, uint16(int64(v)))
+	default:
+		tt.order.PutUint32(b, uint32(int64(v)))
+	}
+}
+
+// predict applies the predictor to a row of samples.
+func (tt testTIFF) predict(row []byte) {
+	size := tt.bits / 8
+	switch tt.predictor {
+	case predictorHorizontal:
+		for i := len(row) - size; i >= size; i -= size {
+			switch size {
+			case 1:
+				row[i] -= row[i-1]
+			case 2:
+				tt.order.PutUint16(row[i:], tt.order.Uint16(row[i:])-tt.order.Uint16(row[i-2:]))
+			case 4:
+				tt.order.PutUint32(row[i:], tt.order.Uint32(row[i:])-tt.order.Uint32(row[i-4:]))
+			}
+		}
+	case predictorFloat:
+		// the bytes of the samples from the most significant, by plane
+		count := len(row) / size
+		samples := bytes.Clone(row)
+		for s := range count {
+			for b := range size {
+				k := b
+				if tt.order == binary.LittleEndian {
+					k = size - 1 - b
+				}
+				row[b*count+s] = samples[s*size+k]
+			}
+		}
+		for i := len(row) - 1; i > 0; i-- {
+			row[i] -= row[i-1]
+		}
+	}
+}
+
+func (tt testTIFF) compress(raw []byte) []byte {
+	switch tt.compression {
+	case compressionLZW:
+		return lzwLiterals(raw)
+	case compressionDeflate:
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(raw)
+		w.Close()
+		return buf.Bytes()
+	case compressionPackBits:
+		var out []byte
+		for len(raw) > 0 {
+			n := min(len(raw), 128)
+			out = append(out, byte(n-1))
+			out = append(out, raw[:n]...)
+			raw = raw[n:]
+		}
+		return out
+	}
+	return raw
+}
+
+// lzwLiterals encodes the bytes as TIFF LZW codes of 9 bits, clearing the
+// table often enough that the codes never widen.
+func lzwLiterals(b []byte) []byte {
+	var out []byte
+	var bits uint32
+	var n int
+	emit := func(code uint32) {
+		bits = bits<<9 | code
+		n += 9
+		for n >= 8 {
+			out = append(out, byte(bits>>(n-8)))
+			n -= 8
+		}
+	}
+	for i, c := range b {
+		if i%200 == 0 {
+			emit(256)
+		}
+		emit(uint32(c))
+	}
+	emit(257)
+	if n > 0 {
+		out = append(out, byte(bits<<(8-n)))
+	}
+	return out
+}
+
+// write writes the elevations, given row by row, to a file.
+func (tt testTIFF) write(t *testing.T, elevations []float64) string {
+	t.Helper()
+	size := tt.bits / 8
+	chunkWidth, chunkHeight := tt.width, tt.rowsPerStrip
+	if tt.tile > 0 {
+		chunkWidth, chunkHeight = tt.tile, tt.tile
+	}
+	if chunkHeight == 0 {
+		chunkHeight = tt.height
+	}
+
+	out := make([]byte, 8)
+	if tt.order == binary.BigEndian {
+		copy(out, "MM")
+	} else {
+		copy(out, "II")
+	}
+	tt.order.PutUint16(out[2:], 42)
+
+	var offsets, counts []byte
+	for top := 0; top < tt.height; top += chunkHeight {
+		for left := 0; left < tt.width; left += chunkWidth {
+			rows := chunkHeight
+			if tt.tile == 0 {
+				rows = min(chunkHeight, tt.height-top)
+			}
+			raw := make([]byte, rows*chunkWidth*size)
+			for r := range rows {
+				row := raw[r*chunkWidth*size : (r+1)*chunkWidth*size]
+				for c := range chunkWidth {
+					if top+r < tt.height && left+c < tt.width {
+						tt.putSample(row[c*size:], elevations[(top+r)*tt.width+left+c])
+					}
+				}
+				tt.predict(row)
+			}
+			chunk := tt.compress(raw)
+			offsets = tt.order.AppendUint32(offsets, uint32(len(out)))
+			counts = tt.order.AppendUint32(counts, uint32(len(chunk)))
+			out = append(out, chunk...)
+		}
+	}
+
+	shorts := func(values ...uint16) []byte {
+		var b []byte
+		for _, v := range values {
+			b = tt.order.AppendUint16(b, v)
+		}
+		return b
+	}
+	long := func(v int) []byte {
+		return tt.order.AppendUint32(nil, uint32(v))
+	}
+	doubles := func(values []float64) []byte {
+		var b []byte
+		for _, v := range values {
+			b = tt.order.AppendUint64(b, math.Float64bits(v))
+		}
+		return b
+	}
+	entries := []tiffEntry{
+		{tagImageWidth, 4, 1, long(tt.width)},
+		{tagImageLength, 4, 1, long(tt.height)},
+		{tagBitsPerSample, 3, 1, shorts(uint16(tt.bits))},
+		{tagCompression, 3, 1, shorts(tt.compression)},
+		{tagSamplesPerPixel, 3, 1, shorts(1)},
+		{tagSampleFormat, 3, 1, shorts(tt.format)},
+		{tagGeoKeyDirectory, 3, 16, shorts(1, 1, 0, 3,
+			geoKeyModelType, 0, 1, tt.modelType,
+			geoKeyRasterType, 0, 1, tt.rasterType,
+			geoKeyProjectedType, 0, 1, tt.epsg)},
+	}
+	if tt.predictor != 0 {
+		entries = append(entries, tiffEntry{tagPredictor, 3, 1, shorts(tt.predictor)})
+	}
+	if tt.tile > 0 {
+		entries = append(entries,
+			tiffEntry{tagTileWidth, 4, 1, long(tt.tile)},
+			tiffEntry{tagTileLength, 4, 1, long(tt.tile)},
+			tiffEntry{tagTileOffsets, 4, len(offsets) / 4, offsets},
+			tiffEntry{tagTileByteCounts, 4, len(counts) / 4, counts})
+	} else {
+		entries = append(entries,
+			tiffEntry{tagRowsPerStrip, 4, 1, long(chunkHeight)},
+			tiffEntry{tagStripOffsets, 4, len(offsets) / 4, offsets},
+			tiffEntry{tagStripByteCounts, 4, len(counts) / 4, counts})
+	}
+	if tt.transform != nil {
+		entries = append(entries, tiffEntry{tagModelTransform, 12, len(tt.transform), doubles(tt.transform)})
+	}
+	if tt.tiepoint != nil {
+		entries = append(entries,
+			tiffEntry{tagModelTiepoint, 12, len(tt.tiepoint), doubles(tt.tiepoint)},
+			tiffEntry{tagModelPixelScale, 12, len(tt.scale), doubles(tt.scale)})
+	}
+	if tt.noData != "" {
+		entries = append(entries, tiffEntry{tagGDALNoData, 2, len(tt.noData) + 1, append([]byte(tt.noData), 0)})
+	}
+	slices.SortFunc(entries, func(a, b tiffEntry) int { return int(a.tag) - int(b.tag) })
+
+	// values longer than 4 bytes are stored before the directory
+	values := make([]uint32, len(entries))
+	for i, e := range entries {
+		if len(e.data) > 4 {
+			values[i] = uint32(len(out))
+			out = append(out, e.data...)
+		}
+	}
+	tt.order.PutUint32(out[4:], uint32(len(out)))
+	out = tt.order.AppendUint16(out, uint16(len(entries)))
+	for i, e := range entries {
+		out = tt.order.AppendUint16(out, e.tag)
+		out = tt.order.AppendUint16(out, e.kind)
+		out = tt.order.AppendUint32(out, uint32(e.count))
+		if len(e.data) > 4 {
+			out = tt.order.AppendUint32(out, values[i])
+		} else {
+			out = append(out, e.data...)
+			out = append(out, make([]byte, 4-len(e.data))...)
+		}
+	}
+	out = append(out, 0, 0, 0, 0)
+
+	path := filepath.Join(t.TempDir(), "dem.tif")
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testElevations is a grid whose elevations all differ, with a fractional
+// part for floating point samples.
+func testElevations(width, height int, fraction float64) []float64 {
+	elevations := make([]float64, width*height)
+	for i := range elevations {
+		col, row := i%width, i/width
+		elevations[i] = 100 + 3*float64(col) + 7*float64(row) + fraction
+	}
+	return elevations
+}
+
+func TestLoadDEMSamples(t *testing.T) {
+	tests := []struct {
+		name     string
+		tiff     testTIFF
+		fraction float64
+	}{
+		{"uncompressed strips", testTIFF{order: binary.LittleEndian, bits: 16, format: sampleSigned, compression: compressionNone, rowsPerStrip: 3}, 0},
+		{"lzw big-endian predictor 2", testTIFF{order: binary.BigEndian, bits: 16, format: sampleUnsigned, compression: compressionLZW, predictor: predictorHorizontal, tile: 16}, 0},
+		{"lzw 32 bits predictor 2", testTIFF{order: binary.LittleEndian, bits: 32, format: sampleSigned, compression: compressionLZW, predictor: predictorHorizontal, rowsPerStrip: 2}, 0},
+		{"deflate predictor 2", testTIFF{order: binary.LittleEndian, bits: 16, format: sampleSigned, compression: compressionDeflate, predictor: predictorHorizontal, tile: 16}, 0},
+		{"deflate float predictor 3", testTIFF{order: binary.LittleEndian, bits: 32, format: sampleFloat, compression: compressionDeflate, predictor: predictorFloat, tile: 16}, 0.25},
+		{"deflate big-endian float predictor 3", testTIFF{order: binary.BigEndian, bits: 32, format: sampleFloat, compression: compressionDeflate, predictor: predictorFloat, rowsPerStrip: 4}, 0.5},
+		{"lzw double predictor 3", testTIFF{order: binary.LittleEndian, bits: 64, format: sampleFloat, compression: compressionLZW, predictor: predictorFloat, rowsPerStrip: 5}, 0.75},
+		{"packbits", testTIFF{order: binary.BigEndian, bits: 16, format: sampleSigned, compression: compressionPackBits, rowsPerStrip: 7}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tt := test.tiff
+			tt.width, tt.height = 37, 21
+			tt.modelType, tt.rasterType = 2, 1
+			tt.tiepoint, tt.scale = []float64{0, 0, 0, -5, 49, 0}, []float64{0.5, 0.25, 0}
+			want := testElevations(tt.width, tt.height, test.fraction)
+			dem, err := LoadDEM(tt.write(t, want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dem.Width != tt.width || dem.Height != tt.height {
+				t.Fatalf("size %d×%d, want %d×%d", dem.Width, dem.Height, tt.width, tt.height)
+			}
+			for row := range dem.Height {
+				for col := range dem.Width {
+					if got := dem.At(col, row); got != want[row*tt.width+col] {
+						t.Fatalf("At(%d, %d) = %v, want %v", col, row, got, want[row*tt.width+col])
+					}
+				}
+			}
+			if dem.Min != want[0] || dem.Max != want[len(want)-1] {
+				t.Errorf("Min, Max = %v, %v, want %v, %v", dem.Min, dem.Max, want[0], want[len(want)-1])
+			}
+		})
+	}
+}
+
+func TestOpenTIFFInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{"empty", nil},
+		{"byte order", []byte("XX\x2a\x00\x08\x00\x00\x00")},
+		{"magic", []byte("II\x2b\x01\x08\x00\x00\x00")},
+		{"bigtiff", []byte("MM\x00\x2b\x00\x08\x00\x00")},
+		{"directory", []byte("II\x2a\x00\xff\x00\x00\x00")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dem.tif")
+			if err := os.WriteFile(path, test.header, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadDEM(path); !errors.Is(err, errGeoTIFFFormat) {
+				t.Errorf("error = %v, want %q", err, errGeoTIFFFormat)
+			}
+		})
+	}
+}
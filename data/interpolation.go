@@ -0,0 +1,441 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math"
+	"slices"
+)
+
+type InterpolationMethod int
+
+const (
+	InverseDistanceWeighting InterpolationMethod = iota
+	OrdinaryKriging
+)
+
+func (m InterpolationMethod) String() string {
+	switch m {
+	case OrdinaryKriging:
+		return "Krigeage ordinaire"
+	default:
+		return "Inverse de la distance (IDW)"
+	}
+}
+
+type VariogramModel int
+
+const (
+	SphericalVariogram VariogramModel = iota
+	ExponentialVariogram
+	GaussianVariogram
+)
+
+func (v VariogramModel) String() string {
+	switch v {
+	case ExponentialVariogram:
+		return "Exponentiel"
+	case GaussianVariogram:
+		return "Gaussien"
+	default:
+		return "Sphérique"
+	}
+}
+
+type InterpolationOptions struct {
+	Method InterpolationMethod
+	// Power is the IDW distance exponent.
+	Power float64
+	// RadiusKm limits the neighbours used for a cell, 0 meaning no limit.
+	RadiusKm      float64
+	MaxNeighbours int
+	Variogram     VariogramModel
+	// Resolution is the number of cells along the longest side of the grid.
+	Resolution int
+}
+
+func DefaultInterpolationOptions() InterpolationOptions {
+	return InterpolationOptions{
+		Method:        InverseDistanceWeighting,
+		Power:         2,
+		RadiusKm:      50,
+		MaxNeighbours: 12,
+		Variogram:     SphericalVariogram,
+		Resolution:    150,
+	}
+}
+
+type SamplePoint struct {
+	Lon, Lat, Value float64
+}
+
+type VariogramFit struct {
+	Model  VariogramModel
+	Nugget float64
+	Sill   float64
+	Range  float64
+}
+
+func (v VariogramFit) gamma(h float64) float64 {
+	if h == 0 {
+		return 0
+	}
+	partial := v.Sill - v.Nugget
+	r := h / v.Range
+	switch v.Model {
+	case ExponentialVariogram:
+		return v.Nugget + partial*(1-math.Exp(-3*r))
+	case GaussianVariogram:
+		return v.Nugget + partial*(1-math.Exp(-3*r*r))
+	default:
+		if r >= 1 {
+			return v.Sill
+		}
+		return v.Nugget + partial*(1.5*r-0.5*r*r*r)
+	}
+}
+
+// Grid holds interpolated values row by row, from north to south. Cells
+// outside of the mask or without neighbours are NaN.
+type Grid struct {
+	Bounds    Bounds
+	Cols      int
+	Rows      int
+	Values    []float64
+	Variogram *VariogramFit
+}
+
+func (g *Grid) At(col, row int) float64 {
+	return g.Values[row*g.Cols+col]
+}
+
+func (g *Grid) CellSize() (lon, lat float64) {
+	return (g.Bounds.MaxLong - g.Bounds.MinLong) / float64(g.Cols),
+		(g.Bounds.MaxLat - g.Bounds.MinLat) / float64(g.Rows)
+}
+
+func (g *Grid) ValueAt(lon, lat float64) float64 {
+	cellLon, cellLat := g.CellSize()
+	col := int((lon - g.Bounds.MinLong) / cellLon)
+	row := int((g.Bounds.MaxLat - lat) / cellLat)
+	if col < 0 || row < 0 || col >= g.Cols || row >= g.Rows {
+		return math.NaN()
+	}
+	return g.At(col, row)
+}
+
+var (
+	ErrNoSamples = errors.New("no sample to interpolate")
+	// ErrColocatedSamples is returned when the samples are all at the same
+	// place, so that their variogram can't be computed.
+	ErrColocatedSamples = errors.New("les points sont tous au même endroit, le variogramme ne peut pas être calculé")
+)
+
+// planar positions in km, accurate enough at the scale of France
+type plane struct {
+	kmPerLon, kmPerLat float64
+}
+
+func newPlane(bounds Bounds) plane {
+	midLat := (bounds.MinLat + bounds.MaxLat) / 2
+	return plane{
+		kmPerLon: 111.32 * math.Cos(midLat*math.Pi/180),
+		kmPerLat: 110.57,
+	}
+}
+
+func (p plane) xy(lon, lat float64) (x, y float64) {
+	return lon * p.kmPerLon, lat * p.kmPerLat
+}
+
+type neighbour struct {
+	index    int
+	distance float64
+}
+
+// Interpolate turns sample points into a regular grid covering bounds. When
+// geoData is given, cells outside of the outline are left empty. The
+// computation stops as soon as ctx is cancelled.
+func Interpolate(ctx context.Context, samples []SamplePoint, bounds Bounds, geoData *GeoData, opts InterpolationOptions) (*Grid, error) {
+	if len(samples) == 0 {
+		return nil, ErrNoSamples
+	}
+	if opts.Resolution <= 0 {
+		opts.Resolution = DefaultInterpolationOptions().Resolution
+	}
+	if opts.MaxNeighbours <= 0 {
+		opts.MaxNeighbours = len(samples)
+	}
+
+	p := newPlane(bounds)
+	xs := make([]float64, len(samples))
+	ys := make([]float64, len(samples))
+	for i, s := range samples {
+		xs[i], ys[i] = p.xy(s.Lon, s.Lat)
+	}
+
+	width := (bounds.MaxLong - bounds.MinLong) * p.kmPerLon
+	height := (bounds.MaxLat - bounds.MinLat) * p.kmPerLat
+	grid := &Grid{Bounds: bounds}
+	if width >= height {
+		grid.Cols = opts.Resolution
+		grid.Rows = max(1, int(float64(opts.Resolution)*height/width))
+	} else {
+		grid.Rows = opts.Resolution
+		grid.Cols = max(1, int(float64(opts.Resolution)*width/height))
+	}
+	grid.Values = make([]float64, grid.Cols*grid.Rows)
+
+	var estimate func(neighbours []neighbour) float64
+	switch opts.Method {
+	case OrdinaryKriging:
+		fit, err := FitVariogram(samples, opts.Variogram)
+		if err != nil {
+			return nil, err
+		}
+		grid.Variogram = &fit
+		estimate = func(neighbours []neighbour) float64 {
+			return krige(fit, xs, ys, samples, neighbours)
+		}
+	default:
+		estimate = func(neighbours []neighbour) float64 {
+			return idw(samples, neighbours, opts.Power)
+		}
+	}
+
+	clip := geoData != nil && geoData.HasOutline()
+	cellLon, cellLat := grid.CellSize()
+	neighbours := make([]neighbour, 0, len(samples))
+
+	for row := range grid.Rows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lat := bounds.MaxLat - (float64(row)+0.5)*cellLat
+		for col := range grid.Cols {
+			lon := bounds.MinLong + (float64(col)+0.5)*cellLon
+			index := row*grid.Cols + col
+			grid.Values[index] = math.NaN()
+			if clip && !geoData.Contains(lon, lat) {
+				continue
+			}
+
+			x, y := p.xy(lon, lat)
+			neighbours = neighbours[:0]
+			for i := range samples {
+				d := math.Hypot(xs[i]-x, ys[i]-y)
+				if opts.RadiusKm > 0 && d > opts.RadiusKm {
+					continue
+				}
+				neighbours = append(neighbours, neighbour{index: i, distance: d})
+			}
+			if len(neighbours) == 0 {
+				continue
+			}
+			if len(neighbours) > opts.MaxNeighbours {
+				slices.SortFunc(neighbours, func(a, b neighbour) int {
+					return cmp.Compare(a.distance, b.distance)
+				})
+				neighbours = neighbours[:opts.MaxNeighbours]
+			}
+			grid.Values[index] = estimate(neighbours)
+		}
+	}
+	return grid, nil
+}
+
+func idw(samples []SamplePoint, neighbours []neighbour, power float64) float64 {
+	var sum, weights float64
+	for _, n := range neighbours {
+		if n.distance < 1e-9 {
+			return samples[n.index].Value
+		}
+		w := 1 / math.Pow(n.distance, power)
+		sum += w * samples[n.index].Value
+		weights += w
+	}
+	return sum / weights
+}
+
+// krige solves the ordinary kriging system written with semivariances:
+// sum(w_j * gamma(d_ij)) + mu = gamma(d_i0) and sum(w_j) = 1.
+func krige(fit VariogramFit, xs, ys []float64, samples []SamplePoint, neighbours []neighbour) float64 {
+	n := len(neighbours)
+	if n == 1 {
+		return samples[neighbours[0].index].Value
+	}
+	size := n + 1
+	matrix := make([][]float64, size)
+	for i := range matrix {
+		matrix[i] = make([]float64, size+1)
+	}
+	for i, a := range neighbours {
+		for j, b := range neighbours {
+			matrix[i][j] = fit.gamma(math.Hypot(xs[a.index]-xs[b.index], ys[a.index]-ys[b.index]))
+		}
+		matrix[i][n] = 1
+		matrix[i][size] = fit.gamma(a.distance)
+		matrix[n][i] = 1
+	}
+	matrix[n][size] = 1
+
+	weights, ok := solveLinearSystem(matrix)
+	if !ok {
+		return idw(samples, neighbours, 2)
+	}
+	value := 0.0
+	for i, nb := range neighbours {
+		value += weights[i] * samples[nb.index].Value
+	}
+	return value
+}
+
+// solveLinearSystem runs a Gaussian elimination with partial pivoting on an
+// augmented matrix.
+func solveLinearSystem(m [][]float64) ([]float64, bool) {
+	n := len(m)
+	for col := range n {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := col + 1; row < n; row++ {
+			factor := m[row][col] / m[col][col]
+			for k := col; k <= n; k++ {
+				m[row][k] -= factor * m[col][k]
+			}
+		}
+	}
+
+	solution := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := m[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= m[row][k] * solution[k]
+		}
+		solution[row] = sum / m[row][row]
+	}
+	return solution, true
+}
+
+const variogramLags = 15
+
+// FitVariogram computes the empirical semivariogram of the samples and fits
+// the model by weighted least squares: for each candidate range the nugget
+// and partial sill are linear parameters.
+func FitVariogram(samples []SamplePoint, model VariogramModel) (VariogramFit, error) {
+	bounds := Bounds{MinLong: math.MaxFloat64, MaxLong: -math.MaxFloat64, MinLat: math.MaxFloat64, MaxLat: -math.MaxFloat64}
+	for _, s := range samples {
+		bounds.MinLong = math.Min(bounds.MinLong, s.Lon)
+		bounds.MaxLong = math.Max(bounds.MaxLong, s.Lon)
+		bounds.MinLat = math.Min(bounds.MinLat, s.Lat)
+		bounds.MaxLat = math.Max(bounds.MaxLat, s.Lat)
+	}
+	p := newPlane(bounds)
+
+	maxLag := 0.0
+	type pair struct{ distance, semivariance float64 }
+	pairs := make([]pair, 0, len(samples)*len(samples)/2)
+	for i := range samples {
+		xi, yi := p.xy(samples[i].Lon, samples[i].Lat)
+		for j := i + 1; j < len(samples); j++ {
+			xj, yj := p.xy(samples[j].Lon, samples[j].Lat)
+			d := math.Hypot(xi-xj, yi-yj)
+			diff := samples[i].Value - samples[j].Value
+			pairs = append(pairs, pair{d, 0.5 * diff * diff})
+			maxLag = math.Max(maxLag, d)
+		}
+	}
+
+	variance := 0.0
+	mean := 0.0
+	for _, s := range samples {
+		mean += s.Value
+	}
+	mean /= float64(len(samples))
+	for _, s := range samples {
+		variance += (s.Value - mean) * (s.Value - mean)
+	}
+	variance /= float64(len(samples))
+
+	fallback := VariogramFit{Model: model, Nugget: 0, Sill: math.Max(variance, 1e-9), Range: math.Max(maxLag/2, 1)}
+	if len(pairs) < variogramLags {
+		return fallback, nil
+	}
+
+	maxLag /= 2
+	lagWidth := maxLag / variogramLags
+	if !(lagWidth > 0) {
+		return VariogramFit{}, ErrColocatedSamples
+	}
+	var lagDistance, lagGamma, lagCount [variogramLags]float64
+	for _, pr := range pairs {
+		// the pairs farther apart than half the largest distance are left out
+		lag := int(pr.distance / lagWidth)
+		if lag < 0 || lag >= variogramLags {
+			continue
+		}
+		lagDistance[lag] += pr.distance
+		lagGamma[lag] += pr.semivariance
+		lagCount[lag]++
+	}
+
+	best := fallback
+	bestError := math.MaxFloat64
+	for step := 1; step <= 40; step++ {
+		candidate := VariogramFit{Model: model, Range: maxLag * float64(step) / 20}
+
+		// least squares on gamma = nugget + partial * f(h)
+		var sw, sf, sff, sg, sfg float64
+		for lag := range variogramLags {
+			if lagCount[lag] == 0 {
+				continue
+			}
+			h := lagDistance[lag] / lagCount[lag]
+			g := lagGamma[lag] / lagCount[lag]
+			f := VariogramFit{Model: model, Sill: 1, Range: candidate.Range}.gamma(h)
+			w := lagCount[lag]
+			sw += w
+			sf += w * f
+			sff += w * f * f
+			sg += w * g
+			sfg += w * f * g
+		}
+		det := sw*sff - sf*sf
+		if det == 0 {
+			continue
+		}
+		nugget := (sg*sff - sf*sfg) / det
+		partial := (sw*sfg - sf*sg) / det
+		if nugget < 0 {
+			nugget = 0
+			partial = sfg / sff
+		}
+		if partial <= 0 {
+			continue
+		}
+		candidate.Nugget = nugget
+		candidate.Sill = nugget + partial
+
+		sse := 0.0
+		for lag := range variogramLags {
+			if lagCount[lag] == 0 {
+				continue
+			}
+			residual := lagGamma[lag]/lagCount[lag] - candidate.gamma(lagDistance[lag]/lagCount[lag])
+			sse += lagCount[lag] * residual * residual
+		}
+		if sse < bestError {
+			bestError = sse
+			best = candidate
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestVariogramGamma(t *testing.T) {
+	tests := []struct {
+		model VariogramModel
+		h     float64
+		want  float64
+	}{
+		// nugget 1, sill 3 and range 10 for every model
+		{SphericalVariogram, 0, 0},
+		{SphericalVariogram, 5, 1 + 2*(0.75-0.0625)},
+		{SphericalVariogram, 10, 3},
+		{SphericalVariogram, 25, 3},
+		{ExponentialVariogram, 0, 0},
+		{ExponentialVariogram, 5, 1 + 2*(1-math.Exp(-1.5))},
+		{ExponentialVariogram, 10, 1 + 2*(1-math.Exp(-3))},
+		{GaussianVariogram, 5, 1 + 2*(1-math.Exp(-0.75))},
+		{GaussianVariogram, 10, 1 + 2*(1-math.Exp(-3))},
+	}
+	for _, test := range tests {
+		fit := VariogramFit{Model: test.model, Nugget: 1, Sill: 3, Range: 10}
+		if got := fit.gamma(test.h); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("%s gamma(%v) = %v, want %v", test.model, test.h, got, test.want)
+		}
+	}
+}
+
+func TestSolveLinearSystem(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix [][]float64
+		want   []float64
+		ok     bool
+	}{
+		{"two equations", [][]float64{{2, 1, 5}, {1, 3, 10}}, []float64{1, 3}, true},
+		// the first pivot is zero and the rows are swapped
+		{"pivoting", [][]float64{{0, 1, 1, 4}, {1, 0, 1, 3}, {1, 1, 0, 5}}, []float64{2, 3, 1}, true},
+		{"singular", [][]float64{{1, 2, 3}, {2, 4, 6}}, nil, false},
+	}
+	for _, test := range tests {
+		got, ok := solveLinearSystem(test.matrix)
+		if ok != test.ok || len(got) != len(test.want) {
+			t.Errorf("%s : solveLinearSystem() = %v, %v, want %v, %v", test.name, got, ok, test.want, test.ok)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-test.want[i]) > 1e-12 {
+				t.Errorf("%s : solveLinearSystem() = %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestIDW(t *testing.T) {
+	samples := []SamplePoint{{Value: 10}, {Value: 20}, {Value: 40}}
+	tests := []struct {
+		name       string
+		neighbours []neighbour
+		power      float64
+		want       float64
+	}{
+		// weights 1 and 1/4
+		{"power 2", []neighbour{{0, 1}, {1, 2}}, 2, 12},
+		// weights 1 and 1/2
+		{"power 1", []neighbour{{0, 1}, {1, 2}}, 1, 40.0 / 3},
+		{"equidistant", []neighbour{{0, 5}, {1, 5}, {2, 5}}, 2, 70.0 / 3},
+		{"on a sample", []neighbour{{0, 3}, {2, 0}, {1, 1}}, 2, 40},
+	}
+	for _, test := range tests {
+		if got := idw(samples, test.neighbours, test.power); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("%s : idw() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestKrige(t *testing.T) {
+	xs := []float64{0, 10, 0, 10}
+	ys := []float64{0, 0, 10, 10}
+	fit := VariogramFit{Model: SphericalVariogram, Sill: 4, Range: 30}
+	neighboursOf := func(x, y float64) []neighbour {
+		neighbours := make([]neighbour, len(xs))
+		for i := range xs {
+			neighbours[i] = neighbour{index: i, distance: math.Hypot(xs[i]-x, ys[i]-y)}
+		}
+		return neighbours
+	}
+
+	tests := []struct {
+		name   string
+		values []float64
+		x, y   float64
+		want   float64
+	}{
+		// without nugget, kriging is exact at the samples
+		{"on a sample", []float64{1, 2, 3, 4}, 10, 0, 2},
+		{"on another sample", []float64{1, 2, 3, 4}, 0, 10, 3},
+		// the weights sum to one
+		{"constant field", []float64{7, 7, 7, 7}, 3, 8, 7},
+		// by symmetry the weights are equal at the centre
+		{"centre", []float64{1, 2, 3, 6}, 5, 5, 3},
+	}
+	for _, test := range tests {
+		samples := make([]SamplePoint, len(test.values))
+		for i, v := range test.values {
+			samples[i].Value = v
+		}
+		if got := krige(fit, xs, ys, samples, neighboursOf(test.x, test.y)); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s : krige() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFitVariogram(t *testing.T) {
+	colocated := make([]SamplePoint, 6)
+	for i := range colocated {
+		colocated[i] = SamplePoint{Lon: 2.35, Lat: 48.85, Value: float64(i)}
+	}
+	if _, err := FitVariogram(colocated, SphericalVariogram); !errors.Is(err, ErrColocatedSamples) {
+		t.Errorf("FitVariogram() of colocated samples: error %v, want %q", err, ErrColocatedSamples)
+	}
+
+	// too few pairs for the lags: the sill is the variance and the range
+	// half the largest distance, 1° of longitude being 111.32 km at the equator
+	few := []SamplePoint{{Lon: 0, Lat: 0, Value: 1}, {Lon: 1, Lat: 0, Value: 2}, {Lon: 2, Lat: 0, Value: 3}}
+	fit, err := FitVariogram(few, ExponentialVariogram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (VariogramFit{Model: ExponentialVariogram, Sill: 2.0 / 3, Range: 111.32}); fit.Model != want.Model || fit.Nugget != 0 ||
+		math.Abs(fit.Sill-want.Sill) > 1e-12 || math.Abs(fit.Range-want.Range) > 1e-9 {
+		t.Errorf("FitVariogram() = %+v, want %+v", fit, want)
+	}
+
+	// a linear field along a line of stations
+	var line []SamplePoint
+	for i := range 20 {
+		line = append(line, SamplePoint{Lon: 2 + 0.05*float64(i), Lat: 47, Value: float64(i)})
+	}
+	fit, err = FitVariogram(line, GaussianVariogram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fit.Model != GaussianVariogram || fit.Sill <= fit.Nugget || fit.Nugget < 0 || fit.Range <= 0 {
+		t.Errorf("FitVariogram() = %+v, want a positive partial sill and range", fit)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	samples := []SamplePoint{{Lon: 2, Lat: 46, Value: 10}, {Lon: 3, Lat: 46, Value: 20}}
+	bounds := Bounds{MinLong: 1.5, MaxLong: 3.5, MinLat: 45.5, MaxLat: 46.5}
+	opts := DefaultInterpolationOptions()
+	opts.Resolution = 21
+	opts.RadiusKm = 0
+
+	grid, err := Interpolate(context.Background(), samples, bounds, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid.Cols != 21 || grid.Rows != 15 {
+		t.Errorf("grid of %dx%d cells, want 21x15", grid.Cols, grid.Rows)
+	}
+	// the middle cell lies halfway between the samples, the others next to them
+	tests := []struct {
+		lon, lat, want float64
+	}{
+		{2.5, 46, 15},
+		{2, 46, 10},
+		{3, 46, 20},
+	}
+	for _, test := range tests {
+		if got := grid.ValueAt(test.lon, test.lat); math.Abs(got-test.want) > 0.01 {
+			t.Errorf("ValueAt(%v, %v) = %v, want %v", test.lon, test.lat, got, test.want)
+		}
+	}
+
+	opts.RadiusKm = 10
+	grid, err = Interpolate(context.Background(), samples, bounds, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := grid.ValueAt(2.5, 46); !math.IsNaN(got) {
+		t.Errorf("ValueAt() out of the radius = %v, want NaN", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Interpolate(ctx, samples, bounds, nil, opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("Interpolate() cancelled: error %v, want %v", err, context.Canceled)
+	}
+	if _, err := Interpolate(context.Background(), nil, bounds, nil, opts); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("Interpolate() without samples: error %v, want %v", err, ErrNoSamples)
+	}
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mbtiles reads a tile set stored in a SQLite file following the MBTiles
+// specification, either in a tiles table or in the map and images tables of
+// deduplicated tile sets. Rows are counted from the south of the world.
+type mbtiles struct {
+	db   *sqliteFile
+	info TileSetInfo
+	// tiles finds the tile rows, or the map rows giving the image id of the
+	// tiles when images is set
+	tiles  *sqliteLookup
+	images *sqliteLookup
+	// tileID is the column of the image ids in the map table, data the
+	// column of the tile images
+	tileID int
+	data   int
+}
+
+var _ TileSource = (*mbtiles)(nil)
+
+func openMBTiles(path string) (*mbtiles, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &mbtiles{db: db}
+	if err := m.init(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s : %w", path, err)
+	}
+	return m, nil
+}
+
+func (m *mbtiles) init() error {
+	schema, err := m.db.schema()
+	if err != nil {
+		return err
+	}
+	if err := m.readMetadata(schema); err != nil {
+		return err
+	}
+
+	key := []string{"zoom_level", "tile_column", "tile_row"}
+	var table sqliteObject
+	if m.tiles, err = m.db.newLookup(schema, "tiles", key...); err == nil {
+		table = m.tiles.table
+	} else {
+		// tiles is then a view joining map and images
+		if m.tiles, err = m.db.newLookup(schema, "map", key...); err != nil {
+			return err
+		}
+		if m.images, err = m.db.newLookup(schema, "images", "tile_id"); err != nil {
+			return err
+		}
+		table = m.images.table
+		if m.tileID = indexFold(sqliteColumns(m.tiles.table.sql), "tile_id"); m.tileID < 0 {
+			return fmt.Errorf("%w : pas de colonne tile_id", errSQLiteFormat)
+		}
+	}
+	m.data = indexFold(sqliteColumns(table.sql), "tile_data")
+	if m.data < 0 {
+		return fmt.Errorf("%w : pas de colonne tile_data", errSQLiteFormat)
+	}
+	return nil
+}
+
+func (m *mbtiles) readMetadata(schema []sqliteObject) error {
+	m.info = TileSetInfo{Format: "png", MinZoom: 0, MaxZoom: MaxTileZoom}
+	metadata, err := m.db.newLookup(schema, "metadata", "name")
+	if err != nil {
+		// the metadata are optional
+		return nil
+	}
+	values := map[string]string{}
+	columns := sqliteColumns(metadata.table.sql)
+	name, value := indexFold(columns, "name"), indexFold(columns, "value")
+	if name < 0 || value < 0 {
+		return nil
+	}
+	err = m.db.scan(metadata.table.root, true, func(_ int64, record []any) error {
+		if max(name, value) < len(record) {
+			n, _ := record[name].(string)
+			v, _ := record[value].(string)
+			values[n] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if values["format"] == "pbf" {
+		return errVectorTiles
+	}
+	if values["format"] != "" {
+		m.info.Format = values["format"]
+	}
+	m.info.Name = values["name"]
+	m.info.Attribution = values["attribution"]
+	if z, err := strconv.Atoi(strings.TrimSpace(values["minzoom"])); err == nil {
+		m.info.MinZoom = z
+	}
+	if z, err := strconv.Atoi(strings.TrimSpace(values["maxzoom"])); err == nil {
+		m.info.MaxZoom = z
+	}
+	return nil
+}
+
+func (m *mbtiles) Info() TileSetInfo {
+	return m.info
+}
+
+func (m *mbtiles) Tile(z, x, y int) ([]byte, error) {
+	row := (1 << z) - 1 - y
+	record, err := m.tiles.find(int64(z), int64(x), int64(row))
+	if err != nil || record == nil {
+		return nil, err
+	}
+	if m.images != nil {
+		if m.tileID >= len(record) {
+			return nil, nil
+		}
+		if record, err = m.images.find(record[m.tileID]); err != nil || record == nil {
+			return nil, err
+		}
+	}
+	if m.data >= len(record) {
+		return nil, nil
+	}
+	switch tile := record[m.data].(type) {
+	case []byte:
+		return tile, nil
+	case string:
+		return []byte(tile), nil
+	}
+	return nil, nil
+}
+
+func (m *mbtiles) Close() error {
+	return m.db.Close()
+}
@@ -0,0 +1,176 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/mbtiles.py writes the MBTiles files of the tests.
+
+func overflowTile() []byte {
+	b := make([]byte, 3000)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	return b
+}
+
+func TestMBTilesPlain(t *testing.T) {
+	source, err := OpenTileSource(filepath.Join("testdata", "plain.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	info := source.Info()
+	if info.Name != "plain.mbtiles" || info.Format != "png" || info.MinZoom != 0 || info.MaxZoom != 4 || info.Attribution != "© test" {
+		t.Errorf("Info() = %+v", info)
+	}
+	for z := range 5 {
+		for x := range 1 << z {
+			for y := range 1 << z {
+				want := fmt.Appendf(nil, "%d/%d/%d", z, x, y)
+				if z == 2 && x == 1 && y == 1 {
+					want = overflowTile()
+				}
+				got, err := source.Tile(z, x, y)
+				if err != nil {
+					t.Fatalf("Tile(%d, %d, %d) : %v", z, x, y, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("Tile(%d, %d, %d) = %.20q, want %.20q", z, x, y, got, want)
+				}
+			}
+		}
+	}
+	if got, err := source.Tile(5, 0, 0); got != nil || err != nil {
+		t.Errorf("Tile(5, 0, 0) = %q, %v, want no tile", got, err)
+	}
+}
+
+// TestMBTilesMultiLevel checks that the fixture does test the descent through
+// interior pages.
+func TestMBTilesMultiLevel(t *testing.T) {
+	db, err := openSQLite(filepath.Join("testdata", "plain.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	schema, err := db.schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]byte{"tiles": interiorTablePage, "tile_index": interiorIndexPage}
+	var tiles uint32
+	for _, o := range schema {
+		if o.name == "tiles" {
+			tiles = o.root
+		}
+		want, ok := kinds[o.name]
+		if !ok {
+			continue
+		}
+		delete(kinds, o.name)
+		p, err := db.btreePage(o.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.kind != want {
+			t.Errorf("root of %s is a page of type %d, want %d", o.name, p.kind, want)
+		}
+	}
+	if len(kinds) > 0 {
+		t.Errorf("missing from the schema: %v", kinds)
+	}
+
+	count := 0
+	err = db.scan(tiles, true, func(int64, []any) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 341 {
+		t.Errorf("scan of the tiles = %d rows, %v, want 341", count, err)
+	}
+}
+
+func TestMBTilesDeduplicated(t *testing.T) {
+	source, err := OpenTileSource(filepath.Join("testdata", "dedup.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	m := source.(*mbtiles)
+	if m.images == nil || m.images.index != 0 {
+		t.Fatal("the images are not looked up without index")
+	}
+	for z := range 3 {
+		for x := range 1 << z {
+			for y := range 1 << z {
+				want := fmt.Appendf(nil, "%d/%d/%d", z, x, y)
+				if x%2 == 0 {
+					want = []byte("sea")
+				}
+				got, err := source.Tile(z, x, y)
+				if err != nil || !bytes.Equal(got, want) {
+					t.Errorf("Tile(%d, %d, %d) = %q, %v, want %q", z, x, y, got, err, want)
+				}
+			}
+		}
+	}
+	if got, err := source.Tile(3, 0, 0); got != nil || err != nil {
+		t.Errorf("Tile(3, 0, 0) = %q, %v, want no tile", got, err)
+	}
+}
+
+func TestMBTilesCorrupted(t *testing.T) {
+	plain, err := os.ReadFile(filepath.Join("testdata", "plain.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		damage func([]byte) []byte
+	}{
+		{"magic", func(b []byte) []byte { return append([]byte("SQLite format 2\x00"), b[16:]...) }},
+		{"page size", func(b []byte) []byte { b[16], b[17] = 0x03, 0xe8; return b }},
+		{"empty", func([]byte) []byte { return nil }},
+		{"page type", func(b []byte) []byte { b[100] = 0x42; return b }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "damaged.mbtiles")
+			if err := os.WriteFile(path, test.damage(bytes.Clone(plain)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			source, err := OpenTileSource(path)
+			if err == nil {
+				source.Close()
+				t.Fatal("no error")
+			}
+			if !errors.Is(err, errSQLiteFormat) {
+				t.Errorf("error %q is not %q", err, errSQLiteFormat)
+			}
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "truncated.mbtiles")
+		// the schema is on the first page, the tiles are lost
+		if err := os.WriteFile(path, plain[:1024], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		source, err := OpenTileSource(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer source.Close()
+		if _, err := source.Tile(4, 3, 3); !errors.Is(err, errSQLiteFormat) {
+			t.Errorf("Tile() error = %v, want %q", err, errSQLiteFormat)
+		}
+	})
+}
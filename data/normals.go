@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// The reference period of the current climate normals.
+const (
+	NormalsFirstYear = 1991
+	NormalsLastYear  = 2020
+)
+
+// minNormalsPeriods is the number of complete periods of the reference period
+// a station needs for its normal to be computed.
+const minNormalsPeriods = 20
+
+// GetMeanByStation averages the variable over the complete periods of every
+// station having at least minMeanPeriods of them.
+func GetMeanByStation(ctx context.Context, db *sql.DB, variable Variable, period Period) (map[string]float64, error) {
+	series, err := queryPeriodSeries(ctx, db, nil, variable, period)
+	if err != nil {
+		return nil, err
+	}
+	response := make(map[string]float64, len(series))
+	for numPost, points := range series {
+		if len(points) >= minMeanPeriods {
+			response[numPost] = MeanValue(points)
+		}
+	}
+	return response, nil
+}
+
+// GetAnomalyByStation returns, for every station, the difference between the
+// value of the latest complete period of the dataset and its normal over
+// 1991-2020. Stations without a value for that period are left out so that
+// all the anomalies describe the same period.
+func GetAnomalyByStation(ctx context.Context, db *sql.DB, variable Variable, period Period) (map[string]float64, error) {
+	series, err := queryPeriodSeries(ctx, db, nil, variable, period)
+	if err != nil {
+		return nil, err
+	}
+	var latest time.Time
+	for _, points := range series {
+		if len(points) > 0 && points[len(points)-1].T.After(latest) {
+			latest = points[len(points)-1].T
+		}
+	}
+	response := make(map[string]float64, len(series))
+	for numPost, points := range series {
+		if len(points) == 0 || !points[len(points)-1].T.Equal(latest) {
+			continue
+		}
+		if anomaly, ok := ComputeAnomaly(points, period); ok {
+			response[numPost] = anomaly
+		}
+	}
+	return response, nil
+}
+
+// ComputeAnomaly returns the difference between the last point of the series
+// and the 1991-2020 normal of the same calendar month for monthly periods, or
+// of all the periods otherwise. It reports false when the reference period
+// has too few values for that normal.
+func ComputeAnomaly(points []SeriesPoint, period Period) (float64, bool) {
+	if len(points) == 0 {
+		return 0, false
+	}
+	last := points[len(points)-1]
+	first, final := NormalsFirstYear, NormalsLastYear
+	if period.Kind == Decade {
+		// the decades mostly within the reference period, the 1990s to the
+		// 2010s
+		first, final = NormalsFirstYear-NormalsFirstYear%10, NormalsLastYear-10
+	}
+	normals := make([]SeriesPoint, 0, NormalsLastYear-NormalsFirstYear+1)
+	for _, p := range points {
+		if p.T.Year() < first || p.T.Year() > final {
+			continue
+		}
+		if period.Kind == Monthly && p.T.Month() != last.T.Month() {
+			continue
+		}
+		normals = append(normals, p)
+	}
+	if len(normals) < minNormals(period) {
+		return 0, false
+	}
+	return last.Value - MeanValue(normals), true
+}
+
+// minNormals is the number of values of the reference period a normal needs:
+// minNormalsPeriods out of the 30 years, in proportion for longer periods.
+func minNormals(period Period) int {
+	reference := NormalsLastYear - NormalsFirstYear + 1
+	possible := reference / max(1, period.Months()/12)
+	// rounded up
+	return (minNormalsPeriods*possible + reference - 1) / reference
+}
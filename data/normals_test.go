@@ -0,0 +1,139 @@
+package data
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// seasonalSeries has one point per period from 1981 to 2024, following a
+// seasonal cycle and then shifted by offset on its last point.
+func seasonalSeries(period Period, offset float64) []SeriesPoint {
+	var points []SeriesPoint
+	for t := time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC); t.Year() < 2025; t = t.AddDate(0, period.Months(), 0) {
+		start, ok := period.Start(t)
+		if !ok || (len(points) > 0 && !start.After(points[len(points)-1].T)) {
+			continue
+		}
+		value := 50 + 30*math.Cos(2*math.Pi*float64(start.Month()-1)/12)
+		points = append(points, SeriesPoint{T: start, Value: value})
+	}
+	points[len(points)-1].Value += offset
+	return points
+}
+
+func TestComputeAnomaly(t *testing.T) {
+	periods := []Period{CalendarYearPeriod, {Kind: Summer}, MonthlyPeriod, {Kind: Decade}}
+	for _, period := range periods {
+		for _, offset := range []float64{0, 12.5, -3} {
+			points := seasonalSeries(period, offset)
+			got, ok := ComputeAnomaly(points, period)
+			if !ok || math.Abs(got-offset) > 1e-9 {
+				t.Errorf("%s, offset %v : ComputeAnomaly() = %v, %v, want %v", period, offset, got, ok, offset)
+			}
+		}
+	}
+}
+
+func TestComputeAnomalyMonthlyNormals(t *testing.T) {
+	// the series ends in turn with each month of the last year, every month
+	// being compared with the normal of the same calendar month only
+	points := seasonalSeries(MonthlyPeriod, 0)
+	for i := range 12 {
+		series := points[:len(points)-i]
+		got, ok := ComputeAnomaly(series, MonthlyPeriod)
+		if !ok || math.Abs(got) > 1e-9 {
+			t.Errorf("anomaly of %s = %v, %v, want 0", series[len(series)-1].T.Format("01/2006"), got, ok)
+		}
+	}
+}
+
+func TestComputeAnomalyTooFewNormals(t *testing.T) {
+	tests := []struct {
+		period Period
+		// the first year kept in the series
+		from int
+		ok   bool
+	}{
+		{CalendarYearPeriod, 2000, true},
+		{CalendarYearPeriod, 2002, false},
+		{MonthlyPeriod, 2001, true},
+		{MonthlyPeriod, 2002, false},
+		{Period{Kind: Decade}, 2000, true},
+		{Period{Kind: Decade}, 2010, false},
+	}
+	for _, test := range tests {
+		var points []SeriesPoint
+		for _, p := range seasonalSeries(test.period, 0) {
+			if p.T.Year() >= test.from {
+				points = append(points, p)
+			}
+		}
+		if _, ok := ComputeAnomaly(points, test.period); ok != test.ok {
+			t.Errorf("%s from %d : ComputeAnomaly() ok = %v, want %v", test.period, test.from, ok, test.ok)
+		}
+	}
+}
+
+func TestMinNormals(t *testing.T) {
+	tests := []struct {
+		period Period
+		want   int
+	}{
+		{CalendarYearPeriod, 20},
+		{MonthlyPeriod, 20},
+		{Period{Kind: Winter}, 20},
+		// 2 decades out of 3
+		{Period{Kind: Decade}, 2},
+	}
+	for _, test := range tests {
+		if got := minNormals(test.period); got != test.want {
+			t.Errorf("minNormals(%s) = %d, want %d", test.period, got, test.want)
+		}
+	}
+}
+
+func TestComputeAnomalyReference(t *testing.T) {
+	at := func(year int, month time.Month, value float64) SeriesPoint {
+		return SeriesPoint{T: time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), Value: value}
+	}
+	// 0 to 29 over 1991-2020, whose mean is 14.5, around values left out
+	yearly := []SeriesPoint{at(1985, time.January, 1000)}
+	for year := NormalsFirstYear; year <= NormalsLastYear; year++ {
+		yearly = append(yearly, at(year, time.January, float64(year-NormalsFirstYear)))
+	}
+	// Januaries of 2 and Julies of 20
+	var monthly []SeriesPoint
+	for year := NormalsFirstYear; year <= NormalsLastYear; year++ {
+		monthly = append(monthly, at(year, time.January, 2), at(year, time.July, 20))
+	}
+	// every case appends its own last point
+	yearly, monthly = slices.Clip(yearly), slices.Clip(monthly)
+
+	tests := []struct {
+		name   string
+		period Period
+		points []SeriesPoint
+		want   float64
+	}{
+		{"year", CalendarYearPeriod, append(yearly, at(2023, time.January, 20)), 5.5},
+		{"year below the normal", CalendarYearPeriod, append(yearly, at(2024, time.January, 4)), -10.5},
+		// the last year of the reference period is compared with itself too
+		{"last reference year", CalendarYearPeriod, yearly, 14.5},
+		{"January", MonthlyPeriod, append(monthly, at(2024, time.January, 5)), 3},
+		{"July", MonthlyPeriod, append(monthly, at(2024, time.July, 5)), -15},
+		// the 1990s, 2000s and 2010s
+		{"decade", Period{Kind: Decade}, []SeriesPoint{at(1980, time.January, 100), at(1990, time.January, 10),
+			at(2000, time.January, 12), at(2010, time.January, 14), at(2020, time.January, 15)}, 3},
+	}
+	for _, test := range tests {
+		if got, ok := ComputeAnomaly(test.points, test.period); !ok || math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s : ComputeAnomaly() = %v, %v, want %v", test.name, got, ok, test.want)
+		}
+	}
+
+	if _, ok := ComputeAnomaly(nil, CalendarYearPeriod); ok {
+		t.Error("ComputeAnomaly() of no point is ok")
+	}
+}
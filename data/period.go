@@ -0,0 +1,184 @@
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+type PeriodKind int
+
+const (
+	CalendarYear PeriodKind = iota
+	HydrologicalYear
+	Winter
+	Spring
+	Summer
+	Autumn
+	Monthly
+	Decade
+)
+
+// DefaultHydrologicalStart is the first month of the hydrological year used in
+// France (September to August).
+const DefaultHydrologicalStart = time.September
+
+// Period describes how daily values are grouped before being aggregated.
+// StartMonth is only used by hydrological years.
+type Period struct {
+	Kind       PeriodKind
+	StartMonth time.Month
+}
+
+var (
+	CalendarYearPeriod = Period{Kind: CalendarYear}
+	MonthlyPeriod      = Period{Kind: Monthly}
+)
+
+// Periods lists the available periods, hydrological years starting on the
+// given month.
+func Periods(hydrologicalStart time.Month) []Period {
+	return []Period{
+		CalendarYearPeriod,
+		{Kind: HydrologicalYear, StartMonth: hydrologicalStart},
+		{Kind: Winter},
+		{Kind: Spring},
+		{Kind: Summer},
+		{Kind: Autumn},
+		MonthlyPeriod,
+		{Kind: Decade},
+	}
+}
+
+var monthAbbreviations = []string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."}
+
+func (p Period) String() string {
+	switch p.Kind {
+	case HydrologicalYear:
+		return fmt.Sprintf("Année hydrologique (%s - %s)",
+			monthAbbreviations[p.firstMonth()-1], monthAbbreviations[(int(p.firstMonth())+10)%12])
+	case Winter:
+		return "Hiver (DJF)"
+	case Spring:
+		return "Printemps (MAM)"
+	case Summer:
+		return "Été (JJA)"
+	case Autumn:
+		return "Automne (SON)"
+	case Monthly:
+		return "Mois"
+	case Decade:
+		return "Décennie"
+	default:
+		return "Année civile"
+	}
+}
+
+// IsYearly reports whether the period spans a whole year, which is what
+// year-based computations (growing season, cumulative rainfall) expect.
+func (p Period) IsYearly() bool {
+	return p.Kind == CalendarYear || p.Kind == HydrologicalYear
+}
+
+func (p Period) firstMonth() time.Month {
+	switch p.Kind {
+	case HydrologicalYear:
+		if p.StartMonth < time.January || p.StartMonth > time.December {
+			return DefaultHydrologicalStart
+		}
+		return p.StartMonth
+	case Winter:
+		return time.December
+	case Spring:
+		return time.March
+	case Summer:
+		return time.June
+	case Autumn:
+		return time.September
+	default:
+		return time.January
+	}
+}
+
+// Months is the length of the period in months.
+func (p Period) Months() int {
+	switch p.Kind {
+	case Winter, Spring, Summer, Autumn:
+		return 3
+	case Monthly:
+		return 1
+	case Decade:
+		return 120
+	default:
+		return 12
+	}
+}
+
+// Start returns the first day of the period containing t, and false when t
+// falls outside of a seasonal period.
+func (p Period) Start(t time.Time) (time.Time, bool) {
+	switch p.Kind {
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), true
+	case Decade:
+		return time.Date(t.Year()/10*10, time.January, 1, 0, 0, 0, 0, time.UTC), true
+	}
+	first := p.firstMonth()
+	if (int(t.Month())-int(first)+12)%12 >= p.Months() {
+		return time.Time{}, false
+	}
+	year := t.Year()
+	if t.Month() < first {
+		year--
+	}
+	return time.Date(year, first, 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// End returns the first day after the period starting on start.
+func (p Period) End(start time.Time) time.Time {
+	return start.AddDate(0, p.Months(), 0)
+}
+
+func (p Period) Days(start time.Time) int {
+	return int(p.End(start).Sub(start).Hours() / 24)
+}
+
+// Label formats the period starting on start for tables and axes.
+func (p Period) Label(start time.Time) string {
+	switch p.Kind {
+	case Monthly:
+		return start.Format("01/2006")
+	case Decade:
+		return fmt.Sprintf("%d-%d", start.Year(), start.Year()+9)
+	case CalendarYear:
+		return fmt.Sprint(start.Year())
+	}
+	end := p.End(start).AddDate(0, 0, -1)
+	if end.Year() == start.Year() {
+		return fmt.Sprint(start.Year())
+	}
+	return fmt.Sprintf("%d-%d", start.Year(), end.Year())
+}
+
+// sqlKey returns the SQL expression of the period start of the date column
+// and the filter restricting rows to the period months.
+func (p Period) sqlKey(date string) (key, filter string) {
+	switch p.Kind {
+	case Monthly:
+		return fmt.Sprintf("make_date(year(%[1]s), month(%[1]s), 1)", date), "TRUE"
+	case Decade:
+		return fmt.Sprintf("make_date(year(%s) // 10 * 10, 1, 1)", date), "TRUE"
+	}
+	first := int(p.firstMonth())
+	key = fmt.Sprintf("make_date(year(%[1]s) - CASE WHEN month(%[1]s) < %[2]d THEN 1 ELSE 0 END, %[2]d, 1)", date, first)
+	filter = fmt.Sprintf("(month(%s) - %d + 12) %% 12 < %d", date, first, p.Months())
+	return key, filter
+}
+
+// sqlDays returns the SQL expression of the number of days of the period
+// starting on key.
+func (p Period) sqlDays(key string) string {
+	return fmt.Sprintf("date_diff('day', %[1]s, CAST(%[1]s + INTERVAL %[2]d MONTH AS DATE))", key, p.Months())
+}
+
+// parquetDate is the SQL expression of the observation date.
+const parquetDate = "CAST(strptime(CAST(AAAAMMJJ AS VARCHAR), '%Y%m%d') AS DATE)"
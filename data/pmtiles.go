@@ -0,0 +1,278 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+var errPMTilesFormat = errors.New("fichier PMTiles invalide")
+
+const pmtilesHeaderSize = 127
+
+// compressions and tile types of the PMTiles header
+const (
+	pmtilesNoCompression   = 1
+	pmtilesGzipCompression = 2
+
+	pmtilesMVT  = 1
+	pmtilesPNG  = 2
+	pmtilesJPEG = 3
+	pmtilesWebP = 4
+)
+
+// pmtiles reads a tile set stored in a PMTiles version 3 file: tiles are
+// numbered along a Hilbert curve in each zoom level, and found through a
+// root directory and leaf directories of runs of tiles.
+type pmtiles struct {
+	file *os.File
+	info TileSetInfo
+
+	internalCompression byte
+	tileCompression     byte
+	leafOffset          uint64
+	dataOffset          uint64
+	root                []pmtilesEntry
+
+	mu sync.Mutex
+	// leaves holds the leaf directories read so far, by offset
+	leaves map[uint64][]pmtilesEntry
+}
+
+// pmtilesEntry gives the location of runLength tiles sharing the same
+// content, starting with tileID; entries of zero length runs point to a leaf
+// directory.
+type pmtilesEntry struct {
+	tileID    uint64
+	offset    uint64
+	length    uint32
+	runLength uint32
+}
+
+var _ TileSource = (*pmtiles)(nil)
+
+func openPMTiles(path string) (*pmtiles, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &pmtiles{file: file, leaves: make(map[uint64][]pmtilesEntry)}
+	if err := p.init(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s : %w", path, err)
+	}
+	return p, nil
+}
+
+func (p *pmtiles) init() error {
+	header := make([]byte, pmtilesHeaderSize)
+	if _, err := p.file.ReadAt(header, 0); err != nil {
+		return errPMTilesFormat
+	}
+	if string(header[:7]) != "PMTiles" || header[7] != 3 {
+		return fmt.Errorf("%w : seule la version 3 est prise en charge", errPMTilesFormat)
+	}
+	le := binary.LittleEndian
+	rootOffset, rootLength := le.Uint64(header[8:]), le.Uint64(header[16:])
+	metadataOffset, metadataLength := le.Uint64(header[24:]), le.Uint64(header[32:])
+	p.leafOffset = le.Uint64(header[40:])
+	p.dataOffset = le.Uint64(header[56:])
+	p.internalCompression = header[97]
+	p.tileCompression = header[98]
+
+	switch header[99] {
+	case pmtilesMVT:
+		return errVectorTiles
+	case pmtilesPNG:
+		p.info.Format = "png"
+	case pmtilesJPEG:
+		p.info.Format = "jpg"
+	case pmtilesWebP:
+		p.info.Format = "webp"
+	default:
+		return fmt.Errorf("%w : type de tuile %d non pris en charge", errPMTilesFormat, header[99])
+	}
+	p.info.MinZoom, p.info.MaxZoom = int(header[100]), int(header[101])
+
+	root, err := p.read(rootOffset, rootLength, p.internalCompression)
+	if err != nil {
+		return err
+	}
+	if p.root, err = decodePMTilesDirectory(root); err != nil {
+		return err
+	}
+
+	// the metadata are optional
+	if metadataLength > 0 {
+		if metadata, err := p.read(metadataOffset, metadataLength, p.internalCompression); err == nil {
+			var values struct {
+				Name        string `json:"name"`
+				Attribution string `json:"attribution"`
+			}
+			if json.Unmarshal(metadata, &values) == nil {
+				p.info.Name = values.Name
+				p.info.Attribution = values.Attribution
+			}
+		}
+	}
+	return nil
+}
+
+// read reads and decompresses a part of the file.
+func (p *pmtiles) read(offset, length uint64, compression byte) ([]byte, error) {
+	b := make([]byte, length)
+	if _, err := p.file.ReadAt(b, int64(offset)); err != nil {
+		return nil, fmt.Errorf("%w : %w", errPMTilesFormat, err)
+	}
+	switch compression {
+	case 0, pmtilesNoCompression:
+		return b, nil
+	case pmtilesGzipCompression:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("%w : compression %d non prise en charge", errPMTilesFormat, compression)
+}
+
+func (p *pmtiles) Info() TileSetInfo {
+	return p.info
+}
+
+func (p *pmtiles) Tile(z, x, y int) ([]byte, error) {
+	if z < 0 || z > MaxTileZoom || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		return nil, nil
+	}
+	id := pmtilesTileID(z, x, y)
+	entries := p.root
+	// a tile is at most three directories deep
+	for range 4 {
+		entry, ok := findPMTilesEntry(entries, id)
+		if !ok {
+			return nil, nil
+		}
+		if entry.runLength > 0 {
+			return p.read(p.dataOffset+entry.offset, uint64(entry.length), p.tileCompression)
+		}
+		var err error
+		if entries, err = p.leaf(entry); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errPMTilesFormat
+}
+
+func (p *pmtiles) leaf(entry pmtilesEntry) ([]pmtilesEntry, error) {
+	p.mu.Lock()
+	entries, ok := p.leaves[entry.offset]
+	p.mu.Unlock()
+	if ok {
+		return entries, nil
+	}
+	b, err := p.read(p.leafOffset+entry.offset, uint64(entry.length), p.internalCompression)
+	if err != nil {
+		return nil, err
+	}
+	if entries, err = decodePMTilesDirectory(b); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.leaves[entry.offset] = entries
+	p.mu.Unlock()
+	return entries, nil
+}
+
+func (p *pmtiles) Close() error {
+	return p.file.Close()
+}
+
+// decodePMTilesDirectory decodes a directory: the number of entries, then
+// their tile ids as deltas, run lengths, lengths and offsets, an offset of 0
+// meaning the tile data follow those of the previous entry.
+func decodePMTilesDirectory(b []byte) ([]pmtilesEntry, error) {
+	r := bytes.NewReader(b)
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > uint64(len(b)) {
+		return nil, errPMTilesFormat
+	}
+	entries := make([]pmtilesEntry, count)
+	next := func() uint64 {
+		v, e := binary.ReadUvarint(r)
+		if e != nil {
+			err = e
+		}
+		return v
+	}
+	var id uint64
+	for i := range entries {
+		id += next()
+		entries[i].tileID = id
+	}
+	for i := range entries {
+		entries[i].runLength = uint32(next())
+	}
+	for i := range entries {
+		entries[i].length = uint32(next())
+	}
+	for i := range entries {
+		offset := next()
+		if offset == 0 && i > 0 {
+			entries[i].offset = entries[i-1].offset + uint64(entries[i-1].length)
+		} else {
+			entries[i].offset = offset - 1
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w : %w", errPMTilesFormat, err)
+	}
+	return entries, nil
+}
+
+// findPMTilesEntry returns the entry holding the tile, or the leaf directory
+// where to look for it.
+func findPMTilesEntry(entries []pmtilesEntry, id uint64) (pmtilesEntry, bool) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].tileID > id }) - 1
+	if i < 0 {
+		return pmtilesEntry{}, false
+	}
+	entry := entries[i]
+	if entry.runLength == 0 || id-entry.tileID < uint64(entry.runLength) {
+		return entry, true
+	}
+	return pmtilesEntry{}, false
+}
+
+// pmtilesTileID numbers the tiles of the zoom levels one after the other,
+// along a Hilbert curve within each level.
+func pmtilesTileID(z, x, y int) uint64 {
+	id := (uint64(1)<<(2*z) - 1) / 3
+	n := 1 << z
+	for s := n / 2; s > 0; s /= 2 {
+		rx, ry := 0, 0
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		id += uint64(s) * uint64(s) * uint64((3*rx)^ry)
+		// rotation of the quadrant
+		if ry == 0 {
+			if rx == 1 {
+				x, y = n-1-x, n-1-y
+			}
+			x, y = y, x
+		}
+	}
+	return id
+}
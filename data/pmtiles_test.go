@@ -0,0 +1,216 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPMTilesTileID(t *testing.T) {
+	tests := []struct {
+		z, x, y int
+		want    uint64
+	}{
+		{0, 0, 0, 0},
+		{1, 0, 0, 1},
+		{1, 0, 1, 2},
+		{1, 1, 1, 3},
+		{1, 1, 0, 4},
+		{3, 0, 0, 21},
+		{12, 3423, 1763, 19078479},
+		{20, 0, 0, 366503875925},
+	}
+	for _, test := range tests {
+		if got := pmtilesTileID(test.z, test.x, test.y); got != test.want {
+			t.Errorf("pmtilesTileID(%d, %d, %d) = %d, want %d", test.z, test.x, test.y, got, test.want)
+		}
+	}
+
+	// every level is numbered without gaps after the previous ones
+	for z := range 5 {
+		first := pmtilesTileID(z, 0, 0)
+		seen := make(map[uint64]bool)
+		for x := range 1 << z {
+			for y := range 1 << z {
+				id := pmtilesTileID(z, x, y)
+				if id < first || id >= first+1<<(2*z) || seen[id] {
+					t.Fatalf("pmtilesTileID(%d, %d, %d) = %d, out of the level or repeated", z, x, y, id)
+				}
+				seen[id] = true
+			}
+		}
+	}
+}
+
+// pmtilesWriter lays a PMTiles file out for the tests.
+type pmtilesWriter struct {
+	compression byte
+	tileType    byte
+	leaves      []byte
+	data        []byte
+}
+
+func (w *pmtilesWriter) compress(b []byte) []byte {
+	if w.compression != pmtilesGzipCompression {
+		return b
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(b)
+	gz.Close()
+	return buf.Bytes()
+}
+
+// directory encodes the entries, the offsets of contiguous entries being
+// left implicit.
+func (w *pmtilesWriter) directory(entries []pmtilesEntry) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(entries)))
+	var last uint64
+	for _, e := range entries {
+		b = binary.AppendUvarint(b, e.tileID-last)
+		last = e.tileID
+	}
+	for _, e := range entries {
+		b = binary.AppendUvarint(b, uint64(e.runLength))
+	}
+	for _, e := range entries {
+		b = binary.AppendUvarint(b, uint64(e.length))
+	}
+	for i, e := range entries {
+		if i > 0 && e.offset == entries[i-1].offset+uint64(entries[i-1].length) {
+			b = binary.AppendUvarint(b, 0)
+		} else {
+			b = binary.AppendUvarint(b, e.offset+1)
+		}
+	}
+	return w.compress(b)
+}
+
+func (w *pmtilesWriter) tile(id uint64, runLength uint32, content []byte) pmtilesEntry {
+	e := pmtilesEntry{tileID: id, offset: uint64(len(w.data)), length: uint32(len(content)), runLength: runLength}
+	w.data = append(w.data, content...)
+	return e
+}
+
+func (w *pmtilesWriter) leaf(entries []pmtilesEntry) pmtilesEntry {
+	b := w.directory(entries)
+	e := pmtilesEntry{tileID: entries[0].tileID, offset: uint64(len(w.leaves)), length: uint32(len(b))}
+	w.leaves = append(w.leaves, b...)
+	return e
+}
+
+func (w *pmtilesWriter) write(t *testing.T, root []pmtilesEntry, metadata string) string {
+	rootDir := w.directory(root)
+	meta := w.compress([]byte(metadata))
+	header := make([]byte, pmtilesHeaderSize)
+	copy(header, "PMTiles")
+	header[7] = 3
+	le := binary.LittleEndian
+	// the root directory, the metadata, the leaf directories and the tiles
+	// follow the header, each with its offset and length in the header
+	offset := uint64(pmtilesHeaderSize)
+	sections := [][]byte{rootDir, meta, w.leaves, w.data}
+	for i, part := range sections {
+		le.PutUint64(header[8+16*i:], offset)
+		le.PutUint64(header[16+16*i:], uint64(len(part)))
+		offset += uint64(len(part))
+	}
+	header[97] = w.compression
+	header[98] = pmtilesNoCompression
+	header[99] = w.tileType
+	header[100], header[101] = 0, 3
+
+	path := filepath.Join(t.TempDir(), "tiles.pmtiles")
+	if err := os.WriteFile(path, bytes.Join(append([][]byte{header}, sections...), nil), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPMTiles(t *testing.T) {
+	for _, compression := range []byte{pmtilesNoCompression, pmtilesGzipCompression} {
+		t.Run(fmt.Sprint("compression ", compression), func(t *testing.T) {
+			w := &pmtilesWriter{compression: compression, tileType: pmtilesPNG}
+			// levels 0 to 2 are spread over two leaf directories, and level 3
+			// is one run of identical tiles
+			var tiles []pmtilesEntry
+			for id := range uint64(21) {
+				tiles = append(tiles, w.tile(id, 1, fmt.Append(nil, "tile ", id)))
+			}
+			root := []pmtilesEntry{
+				w.leaf(tiles[:10]),
+				w.leaf(tiles[10:]),
+				w.tile(21, 64, []byte("sea")),
+			}
+			path := w.write(t, root, `{"name":"Test","attribution":"© test"}`)
+
+			source, err := OpenTileSource(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer source.Close()
+
+			info := source.Info()
+			if info.Name != "Test" || info.Attribution != "© test" || info.Format != "png" || info.MinZoom != 0 || info.MaxZoom != 3 {
+				t.Errorf("Info() = %+v", info)
+			}
+			for z := range 4 {
+				for x := range 1 << z {
+					for y := range 1 << z {
+						want := []byte("sea")
+						if z < 3 {
+							want = fmt.Append(nil, "tile ", pmtilesTileID(z, x, y))
+						}
+						got, err := source.Tile(z, x, y)
+						if err != nil || !bytes.Equal(got, want) {
+							t.Fatalf("Tile(%d, %d, %d) = %q, %v, want %q", z, x, y, got, err, want)
+						}
+					}
+				}
+			}
+			for _, tile := range [][3]int{{4, 0, 0}, {2, 4, 0}, {1, -1, 0}} {
+				if got, err := source.Tile(tile[0], tile[1], tile[2]); got != nil || err != nil {
+					t.Errorf("Tile%v = %q, %v, want no tile", tile, got, err)
+				}
+			}
+			if leaves := len(source.(*pmtiles).leaves); leaves != 2 {
+				t.Errorf("%d leaf directories cached, want 2", leaves)
+			}
+		})
+	}
+}
+
+func TestPMTilesInvalid(t *testing.T) {
+	t.Run("vector tiles", func(t *testing.T) {
+		w := &pmtilesWriter{compression: pmtilesNoCompression, tileType: pmtilesMVT}
+		path := w.write(t, []pmtilesEntry{w.tile(0, 1, []byte("mvt"))}, "")
+		if _, err := OpenTileSource(path); !errors.Is(err, errVectorTiles) {
+			t.Errorf("error = %v, want %q", err, errVectorTiles)
+		}
+	})
+	t.Run("version", func(t *testing.T) {
+		w := &pmtilesWriter{compression: pmtilesNoCompression, tileType: pmtilesPNG}
+		path := w.write(t, []pmtilesEntry{w.tile(0, 1, []byte("png"))}, "")
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b[7] = 2
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenTileSource(path); !errors.Is(err, errPMTilesFormat) {
+			t.Errorf("error = %v, want %q", err, errPMTilesFormat)
+		}
+	})
+	t.Run("directory", func(t *testing.T) {
+		if _, err := decodePMTilesDirectory([]byte{5, 1}); !errors.Is(err, errPMTilesFormat) {
+			t.Errorf("error = %v, want %q", err, errPMTilesFormat)
+		}
+	})
+}
@@ -0,0 +1,284 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// minRecordYears is the number of previous years a calendar day needs for a
+// value to count as a broken record.
+const minRecordYears = 20
+
+const recordsTable = "daily_records"
+
+// recordsMu serialises the creation of the records table: concurrent
+// creations would conflict in the catalog.
+var recordsMu sync.Mutex
+
+type RecordVariable int
+
+const (
+	RecordRain RecordVariable = iota
+	RecordTX
+	RecordTN
+)
+
+var RecordVariables = []RecordVariable{RecordRain, RecordTX, RecordTN}
+
+func (v RecordVariable) String() string {
+	switch v {
+	case RecordTX:
+		return "Température maximale la plus haute"
+	case RecordTN:
+		return "Température minimale la plus basse"
+	default:
+		return "Pluie la plus forte"
+	}
+}
+
+func (v RecordVariable) Unit() string {
+	if v == RecordRain {
+		return "mm"
+	}
+	return "°C"
+}
+
+// DayRecord holds the all-time records of a station for a calendar day and
+// the year they occurred. Values are NaN when the day has no data.
+type DayRecord struct {
+	Month  time.Month
+	Day    int
+	RR     float64
+	RRYear int
+	TX     float64
+	TXYear int
+	TN     float64
+	TNYear int
+	Years  int
+}
+
+func (r DayRecord) Value(v RecordVariable) (float64, int) {
+	switch v {
+	case RecordTX:
+		return r.TX, r.TXYear
+	case RecordTN:
+		return r.TN, r.TNYear
+	default:
+		return r.RR, r.RRYear
+	}
+}
+
+// BrokenRecord is a value exceeding every previous value of the same
+// calendar day at a station.
+type BrokenRecord struct {
+	NumPost      string
+	Variable     RecordVariable
+	Value        float64
+	Previous     float64
+	PreviousYear int
+	Years        int
+}
+
+const recordsQuery = `
+	SELECT
+		ID,
+		D %% 10000 // 100 AS M,
+		D %% 100 AS J,
+		max(RR) AS RR, arg_max(D // 10000, RR) AS RR_YEAR,
+		max(TX) AS TX, arg_max(D // 10000, TX) AS TX_YEAR,
+		min(TN) AS TN, arg_min(D // 10000, TN) AS TN_YEAR,
+		count(DISTINCT D // 10000) AS YEARS
+	FROM (
+		SELECT CAST(NUM_POSTE AS VARCHAR) AS ID, CAST(AAAAMMJJ AS BIGINT) AS D,
+			CAST(RR AS DOUBLE) AS RR, CAST(TX AS DOUBLE) AS TX, CAST(TN AS DOUBLE) AS TN
+		FROM %s
+	)
+	GROUP BY ALL
+`
+
+// ResetDailyRecords drops the records table so that it is computed again,
+// with the new data, on the next request. It has to be called after an import.
+func ResetDailyRecords(ctx context.Context, db *sql.DB) error {
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+	_, err := db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", recordsTable))
+	return err
+}
+
+// ensureDailyRecords computes the records of every station and calendar day
+// into the daily_records table, unless it already exists.
+func ensureDailyRecords(ctx context.Context, db *sql.DB) error {
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+	_, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS "+recordsQuery, recordsTable, parquetSource))
+	return err
+}
+
+// GetStationRecords returns the records of every calendar day of a station,
+// from January 1st to December 31st.
+func GetStationRecords(ctx context.Context, db *sql.DB, numPost string) ([]DayRecord, error) {
+	if err := ensureDailyRecords(ctx, db); err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT M, J, RR, RR_YEAR, TX, TX_YEAR, TN, TN_YEAR, YEARS
+		FROM %s
+		WHERE ID = ?
+		ORDER BY M, J
+	`, recordsTable), numPost)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make([]DayRecord, 0, 366)
+
+	var (
+		month, day, years      int
+		rr, tx, tn             sql.NullFloat64
+		rrYear, txYear, tnYear sql.NullInt64
+	)
+
+	for rows.Next() {
+		if err := rows.Scan(&month, &day, &rr, &rrYear, &tx, &txYear, &tn, &tnYear, &years); err != nil {
+			return nil, err
+		}
+		response = append(response, DayRecord{
+			Month:  time.Month(month),
+			Day:    day,
+			RR:     nullToNaN(rr),
+			RRYear: int(rrYear.Int64),
+			TX:     nullToNaN(tx),
+			TXYear: int(txYear.Int64),
+			TN:     nullToNaN(tn),
+			TNYear: int(tnYear.Int64),
+			Years:  years,
+		})
+	}
+	return response, rows.Err()
+}
+
+// AbsoluteRecords returns, for each variable, the day holding the record of
+// the whole year.
+func AbsoluteRecords(records []DayRecord) map[RecordVariable]DayRecord {
+	response := make(map[RecordVariable]DayRecord)
+	for _, v := range RecordVariables {
+		for _, r := range records {
+			value, _ := r.Value(v)
+			if math.IsNaN(value) {
+				continue
+			}
+			best, ok := response[v]
+			bestValue, _ := best.Value(v)
+			if !ok || (v == RecordTN && value < bestValue) || (v != RecordTN && value > bestValue) {
+				response[v] = r
+			}
+		}
+	}
+	return response
+}
+
+// GetRecentRecordCountByStation counts, for every station, the daily records
+// still standing which were set during the last years of the dataset.
+func GetRecentRecordCountByStation(ctx context.Context, db *sql.DB, years int) (map[string]float64, error) {
+	if err := ensureDailyRecords(ctx, db); err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		WITH LAST AS (SELECT max(greatest(RR_YEAR, TX_YEAR, TN_YEAR)) - ? AS SINCE FROM %[1]s)
+		SELECT ID, count(*) FILTER (WHERE RR_YEAR > SINCE)
+			+ count(*) FILTER (WHERE TX_YEAR > SINCE)
+			+ count(*) FILTER (WHERE TN_YEAR > SINCE)
+		FROM %[1]s, LAST
+		GROUP BY ID
+	`, recordsTable), years)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make(map[string]float64)
+
+	var (
+		id    string
+		count int
+	)
+
+	for rows.Next() {
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		response[id] = float64(count)
+	}
+	return response, rows.Err()
+}
+
+// GetBrokenRecords lists the stations whose value of the given day exceeded
+// (or, for TN, went below) every value of the same calendar day in previous
+// years. Days with fewer than minRecordYears previous years are ignored.
+func GetBrokenRecords(ctx context.Context, db *sql.DB, date time.Time) ([]BrokenRecord, error) {
+	year := date.Year()
+	monthDay := int(date.Month())*100 + date.Day()
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT
+			ID,
+			max(RR) FILTER (WHERE Y = $1), max(RR) FILTER (WHERE Y < $1), arg_max(Y, RR) FILTER (WHERE Y < $1), count(RR) FILTER (WHERE Y < $1),
+			max(TX) FILTER (WHERE Y = $1), max(TX) FILTER (WHERE Y < $1), arg_max(Y, TX) FILTER (WHERE Y < $1), count(TX) FILTER (WHERE Y < $1),
+			min(TN) FILTER (WHERE Y = $1), min(TN) FILTER (WHERE Y < $1), arg_min(Y, TN) FILTER (WHERE Y < $1), count(TN) FILTER (WHERE Y < $1)
+		FROM (
+			SELECT CAST(NUM_POSTE AS VARCHAR) AS ID, CAST(AAAAMMJJ AS BIGINT) // 10000 AS Y,
+				CAST(RR AS DOUBLE) AS RR, CAST(TX AS DOUBLE) AS TX, CAST(TN AS DOUBLE) AS TN
+			FROM %s
+			WHERE CAST(AAAAMMJJ AS BIGINT) %% 10000 = $2 AND CAST(AAAAMMJJ AS BIGINT) // 10000 <= $1
+		)
+		GROUP BY ID
+		ORDER BY ID
+	`, parquetSource), year, monthDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make([]BrokenRecord, 0, 100)
+
+	var id string
+	values := make([]sql.NullFloat64, 2*len(RecordVariables))
+	years := make([]sql.NullInt64, len(RecordVariables))
+	counts := make([]int, len(RecordVariables))
+
+	for rows.Next() {
+		dest := []any{&id}
+		for i := range RecordVariables {
+			dest = append(dest, &values[2*i], &values[2*i+1], &years[i], &counts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, v := range RecordVariables {
+			value, previous := values[2*i], values[2*i+1]
+			if !value.Valid || !previous.Valid || counts[i] < minRecordYears {
+				continue
+			}
+			broken := value.Float64 > previous.Float64
+			if v == RecordTN {
+				broken = value.Float64 < previous.Float64
+			}
+			if !broken {
+				continue
+			}
+			response = append(response, BrokenRecord{
+				NumPost:      id,
+				Variable:     v,
+				Value:        value.Float64,
+				Previous:     previous.Float64,
+				PreviousYear: int(years[i].Int64),
+				Years:        counts[i],
+			})
+		}
+	}
+	return response, rows.Err()
+}
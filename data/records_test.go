@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testParquetDB writes the daily rows to data/parquet under a temporary
+// working directory, where parquetSource reads them, and opens a database.
+// Every column is stored as text, as in some of the published files.
+func testParquetDB(t *testing.T, rows [][]string) *sql.DB {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "data", "parquet"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	db, err := InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	values := make([]string, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, fmt.Sprintf("('%s')", strings.Join(row, "', '")))
+	}
+	_, err = db.Exec(fmt.Sprintf(`
+		COPY (
+			SELECT * FROM (VALUES %s) AS T(NUM_POSTE, NOM_USUEL, AAAAMMJJ, RR, TN, TX)
+		) TO 'data/parquet/test.parquet' (FORMAT parquet)
+	`, strings.Join(values, ", ")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// TestRecordsTextColumns compares the values as numbers even when the columns
+// are stored as text, where "10.5" sorts before "9.0".
+func TestRecordsTextColumns(t *testing.T) {
+	var rows [][]string
+	for year := 1990; year < 2020; year++ {
+		rr, tn, tx := "9.0", "11.0", "9.0"
+		if year == 2000 {
+			rr, tn, tx = "10.5", "8.5", "10.5"
+		}
+		rows = append(rows, []string{"1001", "TEST", fmt.Sprintf("%d0615", year), rr, tn, tx})
+	}
+	rows = append(rows, []string{"1001", "TEST", "20200615", "12.0", "7.0", "12.0"})
+	db := testParquetDB(t, rows)
+	ctx := context.Background()
+
+	broken, err := GetBrokenRecords(ctx, db, time.Date(2020, time.June, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[RecordVariable][2]float64{RecordRain: {12, 10.5}, RecordTX: {12, 10.5}, RecordTN: {7, 8.5}}
+	if len(broken) != len(want) {
+		t.Fatalf("GetBrokenRecords() = %+v, want 3 records", broken)
+	}
+	for _, r := range broken {
+		if w := want[r.Variable]; r.NumPost != "1001" || r.Value != w[0] || r.Previous != w[1] || r.PreviousYear != 2000 || r.Years != 30 {
+			t.Errorf("%s : %+v, want %v after %v in 2000", r.Variable, r, w[0], w[1])
+		}
+	}
+
+	records, err := GetStationRecords(ctx, db, "1001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("GetStationRecords() = %+v, want one day", records)
+	}
+	if r := records[0]; r.Month != time.June || r.Day != 15 || r.RR != 12 || r.RRYear != 2020 || r.TX != 12 || r.TXYear != 2020 || r.TN != 7 || r.TNYear != 2020 || r.Years != 31 {
+		t.Errorf("GetStationRecords() = %+v", r)
+	}
+}
@@ -0,0 +1,311 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+type Department struct {
+	Code string
+	Name string
+}
+
+type Region struct {
+	Code        string
+	Name        string
+	Departments []string
+}
+
+// Météo-France numbers its stations with the department code followed by
+// six digits; Corsica uses 20 for both 2A and 2B.
+var Departments = []Department{
+	{"01", "Ain"}, {"02", "Aisne"}, {"03", "Allier"}, {"04", "Alpes-de-Haute-Provence"},
+	{"05", "Hautes-Alpes"}, {"06", "Alpes-Maritimes"}, {"07", "Ardèche"}, {"08", "Ardennes"},
+	{"09", "Ariège"}, {"10", "Aube"}, {"11", "Aude"}, {"12", "Aveyron"},
+	{"13", "Bouches-du-Rhône"}, {"14", "Calvados"}, {"15", "Cantal"}, {"16", "Charente"},
+	{"17", "Charente-Maritime"}, {"18", "Cher"}, {"19", "Corrèze"}, {"20", "Corse"},
+	{"21", "Côte-d'Or"}, {"22", "Côtes-d'Armor"}, {"23", "Creuse"}, {"24", "Dordogne"},
+	{"25", "Doubs"}, {"26", "Drôme"}, {"27", "Eure"}, {"28", "Eure-et-Loir"},
+	{"29", "Finistère"}, {"30", "Gard"}, {"31", "Haute-Garonne"}, {"32", "Gers"},
+	{"33", "Gironde"}, {"34", "Hérault"}, {"35", "Ille-et-Vilaine"}, {"36", "Indre"},
+	{"37", "Indre-et-Loire"}, {"38", "Isère"}, {"39", "Jura"}, {"40", "Landes"},
+	{"41", "Loir-et-Cher"}, {"42", "Loire"}, {"43", "Haute-Loire"}, {"44", "Loire-Atlantique"},
+	{"45", "Loiret"}, {"46", "Lot"}, {"47", "Lot-et-Garonne"}, {"48", "Lozère"},
+	{"49", "Maine-et-Loire"}, {"50", "Manche"}, {"51", "Marne"}, {"52", "Haute-Marne"},
+	{"53", "Mayenne"}, {"54", "Meurthe-et-Moselle"}, {"55", "Meuse"}, {"56", "Morbihan"},
+	{"57", "Moselle"}, {"58", "Nièvre"}, {"59", "Nord"}, {"60", "Oise"},
+	{"61", "Orne"}, {"62", "Pas-de-Calais"}, {"63", "Puy-de-Dôme"}, {"64", "Pyrénées-Atlantiques"},
+	{"65", "Hautes-Pyrénées"}, {"66", "Pyrénées-Orientales"}, {"67", "Bas-Rhin"}, {"68", "Haut-Rhin"},
+	{"69", "Rhône"}, {"70", "Haute-Saône"}, {"71", "Saône-et-Loire"}, {"72", "Sarthe"},
+	{"73", "Savoie"}, {"74", "Haute-Savoie"}, {"75", "Paris"}, {"76", "Seine-Maritime"},
+	{"77", "Seine-et-Marne"}, {"78", "Yvelines"}, {"79", "Deux-Sèvres"}, {"80", "Somme"},
+	{"81", "Tarn"}, {"82", "Tarn-et-Garonne"}, {"83", "Var"}, {"84", "Vaucluse"},
+	{"85", "Vendée"}, {"86", "Vienne"}, {"87", "Haute-Vienne"}, {"88", "Vosges"},
+	{"89", "Yonne"}, {"90", "Territoire de Belfort"}, {"91", "Essonne"}, {"92", "Hauts-de-Seine"},
+	{"93", "Seine-Saint-Denis"}, {"94", "Val-de-Marne"}, {"95", "Val-d'Oise"},
+}
+
+var Regions = []Region{
+	{"84", "Auvergne-Rhône-Alpes", []string{"01", "03", "07", "15", "26", "38", "42", "43", "63", "69", "73", "74"}},
+	{"27", "Bourgogne-Franche-Comté", []string{"21", "25", "39", "58", "70", "71", "89", "90"}},
+	{"53", "Bretagne", []string{"22", "29", "35", "56"}},
+	{"24", "Centre-Val de Loire", []string{"18", "28", "36", "37", "41", "45"}},
+	{"94", "Corse", []string{"20"}},
+	{"44", "Grand Est", []string{"08", "10", "51", "52", "54", "55", "57", "67", "68", "88"}},
+	{"32", "Hauts-de-France", []string{"02", "59", "60", "62", "80"}},
+	{"11", "Île-de-France", []string{"75", "77", "78", "91", "92", "93", "94", "95"}},
+	{"28", "Normandie", []string{"14", "27", "50", "61", "76"}},
+	{"75", "Nouvelle-Aquitaine", []string{"16", "17", "19", "23", "24", "33", "40", "47", "64", "79", "86", "87"}},
+	{"76", "Occitanie", []string{"09", "11", "12", "30", "31", "32", "34", "46", "48", "65", "66", "81", "82"}},
+	{"52", "Pays de la Loire", []string{"44", "49", "53", "72", "85"}},
+	{"93", "Provence-Alpes-Côte d'Azur", []string{"04", "05", "06", "13", "83", "84"}},
+}
+
+type Weighting int
+
+const (
+	SimpleMean Weighting = iota
+	ThiessenWeighted
+)
+
+func (w Weighting) String() string {
+	switch w {
+	case ThiessenWeighted:
+		return "Pondérée (polygones de Thiessen)"
+	default:
+		return "Moyenne simple"
+	}
+}
+
+type StationMember struct {
+	NumPost string
+	Weight  float64
+}
+
+// minAggregateWeight is the share of the total weight that must have data
+// for an aggregated value to be computed.
+const minAggregateWeight = 0.5
+
+const thiessenCellSize = 0.02
+
+// DepartmentOf returns the department code of a station, restoring the
+// leading zero lost when NUM_POSTE is stored as an integer.
+func DepartmentOf(numPost string) string {
+	if len(numPost) == 7 {
+		numPost = "0" + numPost
+	}
+	if len(numPost) < 2 {
+		return ""
+	}
+	return numPost[:2]
+}
+
+func DepartmentName(code string) string {
+	for _, d := range Departments {
+		if d.Code == code {
+			return d.Name
+		}
+	}
+	return code
+}
+
+func NewDepartmentStation(stations []StationInfo, code string, weighting Weighting, geoData *GeoData) (*StationInfo, error) {
+	return newAggregateStation(
+		stations,
+		[]string{code},
+		"DEP-"+code,
+		fmt.Sprintf("%s (%s)", DepartmentName(code), code),
+		weighting,
+		geoData,
+	)
+}
+
+func NewRegionStation(stations []StationInfo, region Region, weighting Weighting, geoData *GeoData) (*StationInfo, error) {
+	return newAggregateStation(
+		stations,
+		region.Departments,
+		"REG-"+region.Code,
+		region.Name,
+		weighting,
+		geoData,
+	)
+}
+
+func newAggregateStation(stations []StationInfo, departments []string, id, name string, weighting Weighting, geoData *GeoData) (*StationInfo, error) {
+	group := make([]StationInfo, 0, 100)
+	for _, station := range stations {
+		if !station.IsAggregate() && slices.Contains(departments, DepartmentOf(station.NumPost)) {
+			group = append(group, station)
+		}
+	}
+	if len(group) == 0 {
+		return nil, fmt.Errorf("aucune station chargée pour %s", name)
+	}
+
+	var weights map[string]float64
+	if weighting == ThiessenWeighted {
+		weights = ThiessenWeights(stations, group, geoData)
+	}
+
+	// the weighting is part of the id: both averages of a zone can be opened
+	// or compared side by side
+	aggregate := &StationInfo{
+		NumPost:    fmt.Sprintf("%s-%d", id, weighting),
+		CommonName: fmt.Sprintf("%s - %s", name, weighting),
+		Members:    make([]StationMember, 0, len(group)),
+	}
+	total := 0.0
+	for _, station := range group {
+		weight := 1.0
+		if weights != nil {
+			weight = weights[station.NumPost]
+		}
+		if weight <= 0 {
+			continue
+		}
+		aggregate.Members = append(aggregate.Members, StationMember{NumPost: station.NumPost, Weight: weight})
+		aggregate.Lat += station.Lat * weight
+		aggregate.Lon += station.Lon * weight
+		aggregate.Alti += station.Alti * weight
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("aucune station exploitable pour %s", name)
+	}
+	aggregate.Lat /= total
+	aggregate.Lon /= total
+	aggregate.Alti /= total
+	return aggregate, nil
+}
+
+// ThiessenWeights approximates the area of the Voronoi cell of every station
+// of the group on a regular grid: each cell is assigned to its nearest
+// station among all loaded stations and, when geoData is given, cells outside
+// of the outline are ignored.
+func ThiessenWeights(stations []StationInfo, group []StationInfo, geoData *GeoData) map[string]float64 {
+	bounds := Bounds{
+		MinLong: math.MaxFloat64, MaxLong: -math.MaxFloat64,
+		MinLat: math.MaxFloat64, MaxLat: -math.MaxFloat64,
+	}
+	for _, s := range group {
+		bounds.MinLong = math.Min(bounds.MinLong, s.Lon-0.5)
+		bounds.MaxLong = math.Max(bounds.MaxLong, s.Lon+0.5)
+		bounds.MinLat = math.Min(bounds.MinLat, s.Lat-0.5)
+		bounds.MaxLat = math.Max(bounds.MaxLat, s.Lat+0.5)
+	}
+
+	candidates := make([]StationInfo, 0, len(stations))
+	for _, s := range stations {
+		if !s.IsAggregate() && s.Lon > bounds.MinLong-1 && s.Lon < bounds.MaxLong+1 && s.Lat > bounds.MinLat-1 && s.Lat < bounds.MaxLat+1 {
+			candidates = append(candidates, s)
+		}
+	}
+	inGroup := make(map[string]bool, len(group))
+	for _, s := range group {
+		inGroup[s.NumPost] = true
+	}
+
+	clip := geoData != nil && geoData.HasOutline()
+
+	weights := make(map[string]float64, len(group))
+	for lat := bounds.MinLat; lat <= bounds.MaxLat; lat += thiessenCellSize {
+		cosLat := math.Cos(lat * math.Pi / 180)
+		for lon := bounds.MinLong; lon <= bounds.MaxLong; lon += thiessenCellSize {
+			if clip && !geoData.Contains(lon, lat) {
+				continue
+			}
+			nearest := ""
+			best := math.MaxFloat64
+			for _, s := range candidates {
+				dx := (s.Lon - lon) * cosLat
+				dy := s.Lat - lat
+				if d := dx*dx + dy*dy; d < best {
+					best = d
+					nearest = s.NumPost
+				}
+			}
+			if inGroup[nearest] {
+				weights[nearest] += cosLat
+			}
+		}
+	}
+	return weights
+}
+
+// AggregateSeries computes the weighted mean of the members series for every
+// date where enough of the total weight is available.
+func AggregateSeries(series map[string][]SeriesPoint, members []StationMember) []SeriesPoint {
+	total := 0.0
+	for _, m := range members {
+		total += m.Weight
+	}
+
+	type accumulator struct{ sum, weight float64 }
+	byDate := make(map[int64]*accumulator)
+	for _, m := range members {
+		for _, p := range series[m.NumPost] {
+			if math.IsNaN(p.Value) {
+				continue
+			}
+			acc, ok := byDate[p.T.Unix()]
+			if !ok {
+				acc = &accumulator{}
+				byDate[p.T.Unix()] = acc
+			}
+			acc.sum += p.Value * m.Weight
+			acc.weight += m.Weight
+		}
+	}
+
+	dates := make([]int64, 0, len(byDate))
+	for date, acc := range byDate {
+		if acc.weight >= total*minAggregateWeight {
+			dates = append(dates, date)
+		}
+	}
+	slices.Sort(dates)
+
+	response := make([]SeriesPoint, 0, len(dates))
+	for _, date := range dates {
+		acc := byDate[date]
+		response = append(response, SeriesPoint{
+			T:     unixToTime(date),
+			Value: acc.sum / acc.weight,
+		})
+	}
+	return response
+}
+
+// Label names the station unambiguously: several stations share the same
+// common name, so the department and the altitude are appended.
+func (s StationInfo) Label() string {
+	if s.IsAggregate() {
+		return s.CommonName
+	}
+	return fmt.Sprintf("%s (%s, %.0f m)", s.CommonName, DepartmentOf(s.NumPost), s.Alti)
+}
+
+func (s StationInfo) IsAggregate() bool {
+	return len(s.Members) > 0
+}
+
+// MemberIds returns the stations whose data make up s: the station itself or
+// the members of an aggregated pseudo-station.
+func (s StationInfo) MemberIds() []string {
+	if !s.IsAggregate() {
+		return []string{s.NumPost}
+	}
+	ids := make([]string, 0, len(s.Members))
+	for _, m := range s.Members {
+		ids = append(ids, m.NumPost)
+	}
+	return ids
+}
+
+func (s StationInfo) members() []StationMember {
+	if !s.IsAggregate() {
+		return []StationMember{{NumPost: s.NumPost, Weight: 1}}
+	}
+	return s.Members
+}
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SearchResult is a station matching a search query. Higher scores are better
+// matches.
+type SearchResult struct {
+	Station *StationInfo
+	Score   int
+}
+
+// SearchIndex looks stations up by name, commune, department or NUM_POSTE,
+// whose first five digits are the INSEE code of the commune. Matching ignores
+// case and accents and tolerates small typos. The index is immutable once
+// built.
+type SearchIndex struct {
+	stations []StationInfo
+	entries  []searchEntry
+}
+
+type searchEntry struct {
+	name            string
+	words           []string
+	numPost         string
+	department      string
+	departmentWords []string
+}
+
+// Scores of the different kinds of match; a station matches a query when
+// every word of the query matches, its score is the sum of the word scores.
+const (
+	scoreExactName      = 1000
+	scoreNamePrefix     = 500
+	scoreNumPost        = 400
+	scoreNumPostPrefix  = 200
+	scoreDepartment     = 150
+	scoreWord           = 100
+	scoreWordPrefix     = 80
+	scoreDepartmentWord = 60
+	scoreSubstring      = 50
+	scoreFuzzyWord      = 40
+	scoreFuzzyPrefix    = 30
+	scoreFuzzyDept      = 25
+)
+
+// NewSearchIndex indexes the stations, with the names of their commune when
+// communes is given.
+func NewSearchIndex(stations []StationInfo, communes Communes) *SearchIndex {
+	index := &SearchIndex{
+		stations: slices.Clone(stations),
+		entries:  make([]searchEntry, len(stations)),
+	}
+	for i, s := range index.stations {
+		name := FoldSearch(s.CommonName)
+		words := strings.Fields(name)
+		for _, commune := range communes.Of(s.NumPost) {
+			for _, w := range strings.Fields(FoldSearch(commune)) {
+				if !slices.Contains(words, w) {
+					words = append(words, w)
+				}
+			}
+		}
+		department := DepartmentOf(s.NumPost)
+		numPost := s.NumPost
+		if len(numPost) == 7 {
+			numPost = "0" + numPost
+		}
+		index.entries[i] = searchEntry{
+			name:            name,
+			words:           words,
+			numPost:         numPost,
+			department:      department,
+			departmentWords: strings.Fields(FoldSearch(DepartmentName(department))),
+		}
+	}
+	return index
+}
+
+func (i *SearchIndex) Len() int {
+	return len(i.stations)
+}
+
+// Search returns at most limit stations matching the query, best first.
+func (i *SearchIndex) Search(query string, limit int) []SearchResult {
+	folded := FoldSearch(query)
+	tokens := strings.Fields(folded)
+	if len(tokens) == 0 || limit <= 0 {
+		return nil
+	}
+
+	results := make([]SearchResult, 0, limit)
+	for j := range i.entries {
+		if score := i.entries[j].match(folded, tokens); score > 0 {
+			results = append(results, SearchResult{Station: &i.stations[j], Score: score})
+		}
+	}
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Station.CommonName, b.Station.CommonName)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func (e *searchEntry) match(query string, tokens []string) int {
+	score := 0
+	switch {
+	case query == e.name:
+		score += scoreExactName
+	case strings.HasPrefix(e.name, query):
+		score += scoreNamePrefix
+	}
+	for _, t := range tokens {
+		s := e.matchToken(t)
+		if s == 0 {
+			return 0
+		}
+		score += s
+	}
+	return score
+}
+
+func (e *searchEntry) matchToken(t string) int {
+	// Corsican departments share the 20 prefix in NUM_POSTE
+	if t == "2a" || t == "2b" {
+		t = "20"
+	}
+	if isDigits(t) {
+		switch {
+		case t == e.numPost:
+			return scoreNumPost
+		case len(t) == 2 && t == e.department:
+			return scoreDepartment
+		case len(t) > 2 && strings.HasPrefix(e.numPost, t):
+			// five digits are the INSEE code of the commune
+			return scoreNumPostPrefix
+		}
+		return 0
+	}
+
+	edits := maxEdits(t)
+	best := 0
+	for _, w := range e.words {
+		switch {
+		case w == t:
+			return scoreWord
+		case strings.HasPrefix(w, t):
+			best = max(best, scoreWordPrefix)
+		case len(t) >= 3 && strings.Contains(w, t):
+			best = max(best, scoreSubstring)
+		case edits > 0 && levenshtein(w, t) <= edits:
+			best = max(best, scoreFuzzyWord)
+		case edits > 0 && levenshtein(runePrefix(w, len([]rune(t))), t) <= edits:
+			best = max(best, scoreFuzzyPrefix)
+		}
+	}
+	for _, w := range e.departmentWords {
+		switch {
+		case strings.HasPrefix(w, t):
+			best = max(best, scoreDepartmentWord)
+		case edits > 0 && levenshtein(w, t) <= edits:
+			best = max(best, scoreFuzzyDept)
+		}
+	}
+	return best
+}
+
+// FoldSearch normalises text for searching: accents are removed, letters are
+// lower-cased, punctuation becomes spaces and "saint" is abbreviated the way
+// Météo-France names its stations.
+func FoldSearch(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	words := strings.Fields(b.String())
+	for i, w := range words {
+		switch w {
+		case "saint":
+			words[i] = "st"
+		case "sainte":
+			words[i] = "ste"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func runePrefix(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// maxEdits is the number of typos tolerated in a word of the query.
+func maxEdits(t string) int {
+	switch n := len([]rune(t)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,112 @@
+package data
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFoldSearch(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"PARIS-MONTSOURIS", "paris montsouris"},
+		{"Saint-Étienne", "st etienne"},
+		{"Sainte-Marie-aux-Chênes", "ste marie aux chenes"},
+		{"  L'Haÿ-les-Roses ", "l hay les roses"},
+		{"Île d'Yeu", "ile d yeu"},
+		{"ST-ETIENNE", "st etienne"},
+		{"Saintes", "saintes"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := FoldSearch(test.s); got != test.want {
+			t.Errorf("FoldSearch(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"brest", "", 5},
+		{"brest", "brest", 0},
+		{"kitten", "sitting", 3},
+		{"montelimar", "montelimr", 1},
+		{"guipavas", "gupiavas", 2},
+		{"ete", "été", 2},
+	}
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := levenshtein(test.b, test.a); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	stations := []StationInfo{
+		{NumPost: "75114001", CommonName: "PARIS-MONTSOURIS"},
+		{NumPost: "69299001", CommonName: "LYON-ST EXUPERY"},
+		{NumPost: "42005001", CommonName: "ST ETIENNE-BOUTHEON"},
+		{NumPost: "29075001", CommonName: "BREST-GUIPAVAS"},
+		{NumPost: "20004002", CommonName: "AJACCIO"},
+		{NumPost: "20148001", CommonName: "BASTIA"},
+		{NumPost: "26198001", CommonName: "MONTELIMAR"},
+		{NumPost: "91027002", CommonName: "ORLY"},
+		// the leading zero of the Ain is lost in the CSV files
+		{NumPost: "1089001", CommonName: "AMBERIEU"},
+	}
+	communes := Communes{"91027": {"Athis-Mons"}, "20004": {"Ajaccio"}}
+	index := NewSearchIndex(stations, communes)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"exact name", "Ajaccio", []string{"20004002"}},
+		{"name prefix", "bre", []string{"29075001"}},
+		// Paris is also the name of the department
+		{"word and department", "paris", []string{"75114001"}},
+		{"accents and saint", "Saint-Étienne", []string{"42005001"}},
+		{"several words", "st exupery lyon", []string{"69299001"}},
+		{"substring", "guipa", []string{"29075001"}},
+		{"typo", "montelimr", []string{"26198001"}},
+		{"typo in a prefix", "bouthe", []string{"42005001"}},
+		{"NUM_POSTE", "75114001", []string{"75114001"}},
+		{"NUM_POSTE without its leading zero", "01089001", []string{"1089001"}},
+		{"INSEE code", "91027", []string{"91027002"}},
+		{"department", "26", []string{"26198001"}},
+		{"Corsica", "2A", []string{"20004002", "20148001"}},
+		{"department name", "Finistère", []string{"29075001"}},
+		{"department name with a typo", "drime", []string{"26198001"}},
+		{"commune", "athis mons", []string{"91027002"}},
+		{"no match", "zzz", nil},
+		{"one word missing", "brest lyon", nil},
+		{"too short for typos", "ory", nil},
+		{"blank", "  -  ", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, r := range index.Search(test.query, 10) {
+				got = append(got, r.Station.NumPost)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Search(%q) = %v, want %v", test.query, got, test.want)
+			}
+		})
+	}
+
+	if got := NewSearchIndex(stations, nil).Search("athis", 10); len(got) != 0 {
+		t.Errorf("Search() of a commune without communes found %v", got[0].Station.CommonName)
+	}
+	if got := index.Search("20", 1); len(got) != 1 || got[0].Station.NumPost != "20004002" {
+		t.Errorf("Search() limited to one station = %v, want AJACCIO first by name", got)
+	}
+}
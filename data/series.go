@@ -0,0 +1,53 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const parquetSource = "read_parquet('data/parquet/*.parquet')"
+
+type SeriesPoint struct {
+	T     time.Time
+	Value float64
+}
+
+func MeanValue(series []SeriesPoint) float64 {
+	if len(series) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, p := range series {
+		sum += p.Value
+	}
+	return sum / float64(len(series))
+}
+
+func nullToNaN(v sql.NullFloat64) float64 {
+	if !v.Valid {
+		return math.NaN()
+	}
+	return v.Float64
+}
+
+func unixToTime(seconds int64) time.Time {
+	return time.Unix(seconds, 0).UTC()
+}
+
+// stationFilter builds a WHERE clause restricting a query to the given
+// stations. No ids means every station.
+func stationFilter(ids []string) (string, []any) {
+	if len(ids) == 0 {
+		return "TRUE", nil
+	}
+	placeholders := make([]string, 0, len(ids))
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+	return fmt.Sprintf("CAST(NUM_POSTE AS VARCHAR) IN (%s)", strings.Join(placeholders, ", ")), args
+}
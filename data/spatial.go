@@ -0,0 +1,179 @@
+package data
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+const earthRadiusKm = 6371.0
+
+// Haversine returns the great-circle distance in kilometres between two points
+// given in degrees.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dPhi := phi2 - phi1
+	dLambda := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+type StationDistance struct {
+	Station    *StationInfo
+	DistanceKm float64
+}
+
+// StationIndex is a KD-tree over the stations. Stations are stored as unit
+// vectors so that the euclidean (chord) distance between two of them grows
+// with their great-circle distance: searches prune on chords and report
+// haversine distances. The index is immutable once built.
+type StationIndex struct {
+	stations []StationInfo
+	root     *kdNode
+}
+
+type kdNode struct {
+	station     int
+	point       [3]float64
+	axis        int
+	left, right *kdNode
+}
+
+func NewStationIndex(stations []StationInfo) *StationIndex {
+	index := &StationIndex{stations: slices.Clone(stations)}
+	nodes := make([]*kdNode, len(index.stations))
+	for i, s := range index.stations {
+		nodes[i] = &kdNode{station: i, point: unitVector(s.Lat, s.Lon)}
+	}
+	index.root = buildKDTree(nodes, 0)
+	return index
+}
+
+func (i *StationIndex) Len() int {
+	return len(i.stations)
+}
+
+func buildKDTree(nodes []*kdNode, depth int) *kdNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	axis := depth % 3
+	slices.SortFunc(nodes, func(a, b *kdNode) int { return cmp.Compare(a.point[axis], b.point[axis]) })
+	middle := len(nodes) / 2
+	node := nodes[middle]
+	node.axis = axis
+	node.left = buildKDTree(nodes[:middle], depth+1)
+	node.right = buildKDTree(nodes[middle+1:], depth+1)
+	return node
+}
+
+func unitVector(lat, lon float64) [3]float64 {
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180
+	return [3]float64{
+		math.Cos(phi) * math.Cos(lambda),
+		math.Cos(phi) * math.Sin(lambda),
+		math.Sin(phi),
+	}
+}
+
+func chordDistance2(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// kmToChord converts a great-circle distance into the length of the chord of
+// the unit sphere.
+func kmToChord(km float64) float64 {
+	return 2 * math.Sin(math.Min(km/earthRadiusKm, math.Pi)/2)
+}
+
+// Nearest returns the k closest stations, closest first.
+func (i *StationIndex) Nearest(lat, lon float64, k int) []StationDistance {
+	if k <= 0 || i.root == nil {
+		return nil
+	}
+	target := unitVector(lat, lon)
+	best := make([]neighbour, 0, k+1)
+
+	var search func(n *kdNode)
+	search = func(n *kdNode) {
+		if n == nil {
+			return
+		}
+		d := chordDistance2(n.point, target)
+		if len(best) < k || d < best[len(best)-1].distance {
+			at, _ := slices.BinarySearchFunc(best, d, func(nb neighbour, d float64) int { return cmp.Compare(nb.distance, d) })
+			best = slices.Insert(best, at, neighbour{index: n.station, distance: d})
+			if len(best) > k {
+				best = best[:k]
+			}
+		}
+
+		delta := target[n.axis] - n.point[n.axis]
+		near, far := n.left, n.right
+		if delta > 0 {
+			near, far = n.right, n.left
+		}
+		search(near)
+		if len(best) < k || delta*delta < best[len(best)-1].distance {
+			search(far)
+		}
+	}
+	search(i.root)
+
+	return i.distances(best, lat, lon)
+}
+
+// Within returns the stations closer than radiusKm, closest first.
+func (i *StationIndex) Within(lat, lon, radiusKm float64) []StationDistance {
+	if i.root == nil || radiusKm < 0 {
+		return nil
+	}
+	target := unitVector(lat, lon)
+	chord := kmToChord(radiusKm)
+	limit := chord * chord
+	found := make([]neighbour, 0, 16)
+
+	var search func(n *kdNode)
+	search = func(n *kdNode) {
+		if n == nil {
+			return
+		}
+		if d := chordDistance2(n.point, target); d <= limit {
+			found = append(found, neighbour{index: n.station, distance: d})
+		}
+		delta := target[n.axis] - n.point[n.axis]
+		if delta >= -chord {
+			search(n.right)
+		}
+		if delta <= chord {
+			search(n.left)
+		}
+	}
+	search(i.root)
+
+	slices.SortFunc(found, func(a, b neighbour) int { return cmp.Compare(a.distance, b.distance) })
+	return i.distances(found, lat, lon)
+}
+
+// Closest returns the nearest station when it is closer than maxKm.
+func (i *StationIndex) Closest(lat, lon, maxKm float64) (*StationInfo, bool) {
+	nearest := i.Nearest(lat, lon, 1)
+	if len(nearest) == 0 || nearest[0].DistanceKm > maxKm {
+		return nil, false
+	}
+	return nearest[0].Station, true
+}
+
+func (i *StationIndex) distances(neighbours []neighbour, lat, lon float64) []StationDistance {
+	response := make([]StationDistance, 0, len(neighbours))
+	for _, nb := range neighbours {
+		s := &i.stations[nb.index]
+		response = append(response, StationDistance{
+			Station:    s,
+			DistanceKm: Haversine(lat, lon, s.Lat, s.Lon),
+		})
+	}
+	return response
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestHaversine(t *testing.T) {
+	tests := []struct {
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{45, 3, 45, 3, 0},
+		// a quarter of the equator, and from the equator to the pole
+		{0, 0, 0, 90, earthRadiusKm * math.Pi / 2},
+		{0, 12, 90, 0, earthRadiusKm * math.Pi / 2},
+		// one degree of a meridian
+		{46, 2, 47, 2, earthRadiusKm * math.Pi / 180},
+		{-10, 170, 10, -170, 3137.0411136},
+	}
+	for _, test := range tests {
+		if got := Haversine(test.lat1, test.lon1, test.lat2, test.lon2); math.Abs(got-test.want) > 1e-3 {
+			t.Errorf("Haversine(%v, %v, %v, %v) = %v, want %v", test.lat1, test.lon1, test.lat2, test.lon2, got, test.want)
+		}
+	}
+}
+
+// testStations spreads stations over France without any randomness.
+func testStations() []StationInfo {
+	var stations []StationInfo
+	for i := range 500 {
+		stations = append(stations, StationInfo{
+			NumPost: fmt.Sprintf("%08d", i),
+			Lat:     42 + math.Mod(float64(i)*0.6180339887, 1)*9,
+			Lon:     -4.5 + math.Mod(float64(i)*0.7548776662, 1)*12.5,
+		})
+	}
+	return stations
+}
+
+// bruteForce sorts all the stations by distance.
+func bruteForce(stations []StationInfo, lat, lon float64) []StationDistance {
+	response := make([]StationDistance, len(stations))
+	for i := range stations {
+		response[i] = StationDistance{Station: &stations[i], DistanceKm: Haversine(lat, lon, stations[i].Lat, stations[i].Lon)}
+	}
+	slices.SortFunc(response, func(a, b StationDistance) int { return cmp.Compare(a.DistanceKm, b.DistanceKm) })
+	return response
+}
+
+func TestStationIndexNearest(t *testing.T) {
+	stations := testStations()
+	index := NewStationIndex(stations)
+	tests := []struct {
+		name     string
+		lat, lon float64
+		k        int
+	}{
+		{"Paris", 48.8566, 2.3522, 1},
+		{"Lyon", 45.764, 4.8357, 5},
+		{"Brest", 48.39, -4.49, 12},
+		{"off the coast", 43, 9.5, 3},
+		{"far away", -33.9, 18.4, 2},
+		{"every station", 46, 2, 600},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := index.Nearest(test.lat, test.lon, test.k)
+			want := bruteForce(stations, test.lat, test.lon)[:min(test.k, len(stations))]
+			if len(got) != len(want) {
+				t.Fatalf("Nearest() found %d stations, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].Station.NumPost != want[i].Station.NumPost || math.Abs(got[i].DistanceKm-want[i].DistanceKm) > 1e-9 {
+					t.Errorf("station %d = %s at %v km, want %s at %v km", i, got[i].Station.NumPost, got[i].DistanceKm, want[i].Station.NumPost, want[i].DistanceKm)
+				}
+			}
+		})
+	}
+
+	if got := index.Nearest(46, 2, 0); got != nil {
+		t.Errorf("Nearest() of no station = %v, want none", got)
+	}
+	if got := NewStationIndex(nil).Nearest(46, 2, 3); got != nil {
+		t.Errorf("Nearest() in an empty index = %v, want none", got)
+	}
+}
+
+func TestStationIndexWithin(t *testing.T) {
+	stations := testStations()
+	index := NewStationIndex(stations)
+	tests := []struct {
+		lat, lon, radiusKm float64
+	}{
+		{48.8566, 2.3522, 10},
+		{45.764, 4.8357, 50},
+		{44, -1, 120},
+		{47, 2, 0},
+	}
+	for _, test := range tests {
+		got := index.Within(test.lat, test.lon, test.radiusKm)
+		var want []StationDistance
+		for _, s := range bruteForce(stations, test.lat, test.lon) {
+			if s.DistanceKm <= test.radiusKm {
+				want = append(want, s)
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("Within(%v, %v, %v) found %d stations, want %d", test.lat, test.lon, test.radiusKm, len(got), len(want))
+			continue
+		}
+		for i := range want {
+			if got[i].Station.NumPost != want[i].Station.NumPost {
+				t.Errorf("Within(%v, %v, %v) station %d = %s, want %s", test.lat, test.lon, test.radiusKm, i, got[i].Station.NumPost, want[i].Station.NumPost)
+			}
+		}
+	}
+}
+
+func TestStationIndexClosest(t *testing.T) {
+	index := NewStationIndex([]StationInfo{
+		{NumPost: "75114001", Lat: 48.8222, Lon: 2.3378},
+		{NumPost: "69029001", Lat: 45.7267, Lon: 4.9389},
+	})
+	tests := []struct {
+		lat, lon, maxKm float64
+		want            string
+	}{
+		{48.85, 2.35, 5, "75114001"},
+		{45.76, 4.84, 10, "69029001"},
+		// Clermont-Ferrand is farther than 100 km from both
+		{45.78, 3.08, 100, ""},
+	}
+	for _, test := range tests {
+		s, ok := index.Closest(test.lat, test.lon, test.maxKm)
+		got := ""
+		if ok {
+			got = s.NumPost
+		}
+		if got != test.want {
+			t.Errorf("Closest(%v, %v, %v) = %q, want %q", test.lat, test.lon, test.maxKm, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+var SPIScales = []int{1, 3, 6, 12}
+
+const (
+	spiRefStartYear = 1991
+	spiRefEndYear   = 2020
+	spiMinRefYears  = 20
+)
+
+type DroughtCategory int
+
+const (
+	ExtremelyDry DroughtCategory = iota
+	SeverelyDry
+	ModeratelyDry
+	NearNormal
+	ModeratelyWet
+	VeryWet
+	ExtremelyWet
+)
+
+var DroughtCategories = []DroughtCategory{
+	ExtremelyDry, SeverelyDry, ModeratelyDry, NearNormal, ModeratelyWet, VeryWet, ExtremelyWet,
+}
+
+func (c DroughtCategory) String() string {
+	switch c {
+	case ExtremelyDry:
+		return "Extrêmement sec"
+	case SeverelyDry:
+		return "Sévèrement sec"
+	case ModeratelyDry:
+		return "Modérément sec"
+	case ModeratelyWet:
+		return "Modérément humide"
+	case VeryWet:
+		return "Très humide"
+	case ExtremelyWet:
+		return "Extrêmement humide"
+	default:
+		return "Proche de la normale"
+	}
+}
+
+func SPICategory(spi float64) DroughtCategory {
+	switch {
+	case spi <= -2:
+		return ExtremelyDry
+	case spi <= -1.5:
+		return SeverelyDry
+	case spi <= -1:
+		return ModeratelyDry
+	case spi < 1:
+		return NearNormal
+	case spi < 1.5:
+		return ModeratelyWet
+	case spi < 2:
+		return VeryWet
+	default:
+		return ExtremelyWet
+	}
+}
+
+func GetMonthlyRain(ctx context.Context, db *sql.DB, station *StationInfo) ([]SeriesPoint, error) {
+	monthly, err := queryMonthlyRain(ctx, db, station.MemberIds())
+	if err != nil {
+		return nil, err
+	}
+	return AggregateSeries(monthly, station.members()), nil
+}
+
+func GetSPI(ctx context.Context, db *sql.DB, station *StationInfo, scale int) ([]SeriesPoint, error) {
+	monthly, err := GetMonthlyRain(ctx, db, station)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeSPI(monthly, scale), nil
+}
+
+// GetLatestSPIByStation returns the SPI of the latest month of the dataset
+// for every station, the stations without a value for that month being left
+// out so that all the values describe the same month.
+func GetLatestSPIByStation(ctx context.Context, db *sql.DB, scale int) (map[string]float64, error) {
+	monthly, err := queryMonthlyRain(ctx, db, nil)
+	if err != nil {
+		return nil, err
+	}
+	var latest time.Time
+	for _, series := range monthly {
+		if len(series) > 0 && series[len(series)-1].T.After(latest) {
+			latest = series[len(series)-1].T
+		}
+	}
+	response := make(map[string]float64, len(monthly))
+	for numPost, series := range monthly {
+		if len(series) == 0 || !series[len(series)-1].T.Equal(latest) {
+			continue
+		}
+		spi := ComputeSPI(series, scale)
+		if len(spi) > 0 && spi[len(spi)-1].T.Equal(latest) {
+			response[numPost] = spi[len(spi)-1].Value
+		}
+	}
+	return response, nil
+}
+
+// queryMonthlyRain sums the daily rain per month, keeping only the months with
+// enough observations.
+func queryMonthlyRain(ctx context.Context, db *sql.DB, ids []string) (map[string][]SeriesPoint, error) {
+	filter, args := stationFilter(ids)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT CAST(NUM_POSTE AS VARCHAR) AS ID, substr(CAST(AAAAMMJJ AS VARCHAR), 1, 6) AS MONTH,
+			sum(CAST(RR AS DOUBLE)) AS RAIN, count(RR) AS N
+		FROM %s
+		WHERE %s
+		GROUP BY ID, MONTH
+		ORDER BY ID, MONTH ASC
+	`, parquetSource, filter), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make(map[string][]SeriesPoint)
+
+	var (
+		id, month string
+		rain      sql.NullFloat64
+		n         int
+	)
+
+	for rows.Next() {
+		if err := rows.Scan(&id, &month, &rain, &n); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse("200601", month)
+		if err != nil || !rain.Valid {
+			continue
+		}
+		if float64(n) < minYearCoverage*float64(t.AddDate(0, 1, -1).Day()) {
+			continue
+		}
+		response[id] = append(response[id], SeriesPoint{T: t, Value: rain.Float64})
+	}
+	return response, rows.Err()
+}
+
+// ComputeSPI computes the Standardized Precipitation Index over the given
+// scale (in months). For each calendar month a gamma distribution is fitted
+// on the 1991-2020 reference period and the cumulative probability is
+// transformed into a standard normal deviate.
+func ComputeSPI(monthly []SeriesPoint, scale int) []SeriesPoint {
+	if len(monthly) == 0 || scale < 1 {
+		return nil
+	}
+
+	first := monthly[0].T
+	last := monthly[len(monthly)-1].T
+	count := monthsBetween(first, last) + 1
+
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	for _, p := range monthly {
+		values[monthsBetween(first, p.T)] = p.Value
+	}
+
+	sums := make([]float64, count)
+	for i := range sums {
+		sums[i] = math.NaN()
+		if i+1 < scale {
+			continue
+		}
+		sum := 0.0
+		for _, v := range values[i+1-scale : i+1] {
+			sum += v
+		}
+		sums[i] = sum
+	}
+
+	var fits [12]gammaFit
+	for month := range 12 {
+		fits[month] = fitMonth(sums, first, month, true)
+		if fits[month].samples < spiMinRefYears {
+			fits[month] = fitMonth(sums, first, month, false)
+		}
+	}
+
+	response := make([]SeriesPoint, 0, count)
+	for i, sum := range sums {
+		if math.IsNaN(sum) {
+			continue
+		}
+		t := first.AddDate(0, i, 0)
+		fit := fits[int(t.Month())-1]
+		if !fit.valid() {
+			continue
+		}
+		response = append(response, SeriesPoint{T: t, Value: fit.spi(sum)})
+	}
+	return response
+}
+
+type gammaFit struct {
+	alpha, beta float64
+	zeros       float64
+	samples     int
+}
+
+func (g gammaFit) valid() bool {
+	return g.samples > 0 && g.alpha > 0 && g.beta > 0
+}
+
+func (g gammaFit) spi(x float64) float64 {
+	p := g.zeros
+	if x > 0 {
+		p += (1 - g.zeros) * regularizedGammaP(g.alpha, x/g.beta)
+	}
+	p = math.Min(math.Max(p, 1e-6), 1-1e-6)
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+func fitMonth(sums []float64, first time.Time, month int, refOnly bool) gammaFit {
+	var n, zeros int
+	var sum, sumLog float64
+	for i, v := range sums {
+		if math.IsNaN(v) {
+			continue
+		}
+		t := first.AddDate(0, i, 0)
+		if int(t.Month())-1 != month {
+			continue
+		}
+		if refOnly && (t.Year() < spiRefStartYear || t.Year() > spiRefEndYear) {
+			continue
+		}
+		n++
+		if v <= 0 {
+			zeros++
+			continue
+		}
+		sum += v
+		sumLog += math.Log(v)
+	}
+
+	fit := gammaFit{samples: n}
+	positives := n - zeros
+	if positives < 2 {
+		return fit
+	}
+	fit.zeros = float64(zeros) / float64(n)
+
+	// Thom (1958) maximum likelihood approximation
+	mean := sum / float64(positives)
+	a := math.Log(mean) - sumLog/float64(positives)
+	if a <= 0 {
+		return fit
+	}
+	fit.alpha = (1 + math.Sqrt(1+4*a/3)) / (4 * a)
+	fit.beta = mean / fit.alpha
+	return fit
+}
+
+// regularizedGammaP is the regularized lower incomplete gamma function,
+// evaluated by series expansion or continued fraction (Numerical Recipes).
+func regularizedGammaP(a, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	lgamma, _ := math.Lgamma(a)
+	if x < a+1 {
+		sum := 1 / a
+		term := sum
+		for n := 1; n < 500; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-12 {
+				break
+			}
+		}
+		return sum * math.Exp(-x+a*math.Log(x)-lgamma)
+	}
+
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 500; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-12 {
+			break
+		}
+	}
+	return 1 - math.Exp(-x+a*math.Log(x)-lgamma)*h
+}
+
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRegularizedGammaP(t *testing.T) {
+	tests := []struct {
+		a, x, want float64
+	}{
+		// P(1, x) = 1 - exp(-x)
+		{1, 0.5, 1 - math.Exp(-0.5)},
+		{1, 5, 1 - math.Exp(-5)},
+		// P(1/2, x) = erf(sqrt(x))
+		{0.5, 0.3, 0.5614219739190001},
+		{0.5, 4, 0.9953222650189527},
+		// P(2, x) = 1 - exp(-x) (1 + x)
+		{2, 1, 1 - 2*math.Exp(-1)},
+		{2, 3, 1 - 4*math.Exp(-3)},
+		// series of P(10, 8) = 1 - exp(-8) sum(8^k / k!, k < 10)
+		{10, 8, 0.28337574127298903},
+		{3, 0, 0},
+	}
+	for _, test := range tests {
+		if got := regularizedGammaP(test.a, test.x); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("regularizedGammaP(%v, %v) = %v, want %v", test.a, test.x, got, test.want)
+		}
+	}
+}
+
+func TestFitMonth(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []float64
+		alpha, beta float64
+		zeros       float64
+		valid       bool
+	}{
+		// Thom's estimate, A = ln(mean) - mean(ln x)
+		{"three values", []float64{1, 2, 4}, 3.402463477517162, 0.6857776281072702, 0, true},
+		{"with dry months", []float64{0, 10, 20, 30, 0, 40, 50, 60}, 3.3593807719355024, 10.418586750389359, 0.25, true},
+		{"constant", []float64{5, 5, 5}, 0, 0, 0, false},
+		{"one rainy month", []float64{0, 0, 12}, 0, 0, 0, false},
+	}
+	first := time.Date(1991, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the values are the Januaries of consecutive years
+			sums := make([]float64, 12*len(test.values))
+			for i := range sums {
+				sums[i] = math.NaN()
+			}
+			for i, v := range test.values {
+				sums[12*i] = v
+			}
+			fit := fitMonth(sums, first, 0, true)
+			if fit.valid() != test.valid || fit.samples != len(test.values) {
+				t.Fatalf("fit %+v, valid %v, want valid %v over %d samples", fit, fit.valid(), test.valid, len(test.values))
+			}
+			if test.valid && (math.Abs(fit.alpha-test.alpha) > 1e-9 || math.Abs(fit.beta-test.beta) > 1e-9 || fit.zeros != test.zeros) {
+				t.Errorf("alpha %v, beta %v, zeros %v, want %v, %v, %v", fit.alpha, fit.beta, fit.zeros, test.alpha, test.beta, test.zeros)
+			}
+		})
+	}
+}
+
+func TestGammaFitSPI(t *testing.T) {
+	// the exponential distribution of mean 1
+	exponential := gammaFit{alpha: 1, beta: 1, samples: 30}
+	tests := []struct {
+		name string
+		fit  gammaFit
+		x    float64
+		want float64
+	}{
+		{"median", exponential, math.Ln2, 0},
+		// 1 - exp(-x) = Φ(1) = 0.8413447460685429
+		{"one deviation", exponential, -math.Log(1 - 0.8413447460685429), 1},
+		{"one deviation below", exponential, -math.Log(0.8413447460685429), -1},
+		{"half the months dry", gammaFit{alpha: 2, beta: 10, zeros: 0.5, samples: 30}, 0, 0},
+		{"capped", exponential, 1000, math.Sqrt2 * math.Erfinv(1-2e-6)},
+	}
+	for _, test := range tests {
+		if got := test.fit.spi(test.x); math.Abs(got-test.want) > 1e-6 {
+			t.Errorf("%s : spi(%v) = %v, want %v", test.name, test.x, got, test.want)
+		}
+	}
+}
+
+func TestComputeSPIGaps(t *testing.T) {
+	missing := time.Date(2000, time.June, 1, 0, 0, 0, 0, time.UTC)
+	var monthly []SeriesPoint
+	for year := 1991; year <= 2020; year++ {
+		for month := time.January; month <= time.December; month++ {
+			date := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+			if !date.Equal(missing) {
+				monthly = append(monthly, SeriesPoint{T: date, Value: float64(50 + 10*((7*year+3*int(month))%10))})
+			}
+		}
+	}
+
+	tests := []struct {
+		scale int
+		// left out are the months without an index around the missing month
+		left []time.Month
+		want int
+	}{
+		{1, []time.Month{time.June}, 359},
+		{3, []time.Month{time.June, time.July, time.August}, 355},
+		{12, nil, 360 - 11 - 12},
+	}
+	for _, test := range tests {
+		spi := ComputeSPI(monthly, test.scale)
+		if len(spi) != test.want {
+			t.Errorf("SPI %d : %d months, want %d", test.scale, len(spi), test.want)
+		}
+		dates := make(map[time.Time]bool, len(spi))
+		for i, p := range spi {
+			dates[p.T] = true
+			if i > 0 && !p.T.After(spi[i-1].T) {
+				t.Fatalf("SPI %d : %v after %v", test.scale, p.T, spi[i-1].T)
+			}
+			if math.IsNaN(p.Value) || math.Abs(p.Value) > 5 {
+				t.Fatalf("SPI %d of %v = %v", test.scale, p.T, p.Value)
+			}
+		}
+		for _, month := range test.left {
+			if date := time.Date(2000, month, 1, 0, 0, 0, 0, time.UTC); dates[date] {
+				t.Errorf("SPI %d : value for %v, whose window misses a month", test.scale, date.Format("01/2006"))
+			}
+		}
+		if next := time.Date(2000, time.June, 1, 0, 0, 0, 0, time.UTC).AddDate(0, test.scale, 0); test.scale < 12 && !dates[next] {
+			t.Errorf("SPI %d : no value for %v", test.scale, next.Format("01/2006"))
+		}
+	}
+}
@@ -0,0 +1,634 @@
+package data
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+var errSQLiteFormat = errors.New("fichier SQLite invalide")
+
+// b-tree page types
+const (
+	interiorIndexPage = 0x02
+	interiorTablePage = 0x05
+	leafIndexPage     = 0x0a
+	leafTablePage     = 0x0d
+)
+
+// maxBTreeDepth bounds the descent in a b-tree, so that a damaged file
+// cannot loop forever.
+const maxBTreeDepth = 32
+
+// sqliteFile reads the tables and the indexes of a SQLite database file,
+// enough to look up the tiles of an MBTiles file without a SQLite driver.
+// It is safe for concurrent use.
+type sqliteFile struct {
+	file     *os.File
+	pageSize int
+	// usable is the page size without the bytes reserved for extensions
+	usable int
+}
+
+// sqliteObject is a table or an index of the schema.
+type sqliteObject struct {
+	kind  string
+	name  string
+	table string
+	root  uint32
+	sql   string
+}
+
+func openSQLite(path string) (*sqliteFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 100)
+	if _, err := file.ReadAt(header, 0); err != nil || string(header[:16]) != "SQLite format 3\x00" {
+		file.Close()
+		return nil, errSQLiteFormat
+	}
+	pageSize := int(binary.BigEndian.Uint16(header[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		file.Close()
+		return nil, errSQLiteFormat
+	}
+	return &sqliteFile{file: file, pageSize: pageSize, usable: pageSize - int(header[20])}, nil
+}
+
+func (s *sqliteFile) Close() error {
+	return s.file.Close()
+}
+
+func (s *sqliteFile) page(n uint32) ([]byte, error) {
+	if n == 0 {
+		return nil, errSQLiteFormat
+	}
+	page := make([]byte, s.pageSize)
+	if _, err := s.file.ReadAt(page, int64(n-1)*int64(s.pageSize)); err != nil {
+		return nil, fmt.Errorf("%w : page %d : %w", errSQLiteFormat, n, err)
+	}
+	return page, nil
+}
+
+type btreePage struct {
+	data  []byte
+	kind  byte
+	cells []int
+	// right is the rightmost child of an interior page
+	right uint32
+}
+
+func (s *sqliteFile) btreePage(n uint32) (*btreePage, error) {
+	data, err := s.page(n)
+	if err != nil {
+		return nil, err
+	}
+	// the first page starts with the header of the file
+	start := 0
+	if n == 1 {
+		start = 100
+	}
+	p := &btreePage{data: data, kind: data[start]}
+	headerSize := 8
+	switch p.kind {
+	case interiorIndexPage, interiorTablePage:
+		headerSize = 12
+		p.right = binary.BigEndian.Uint32(data[start+8:])
+	case leafIndexPage, leafTablePage:
+	default:
+		return nil, fmt.Errorf("%w : page %d de type %d", errSQLiteFormat, n, p.kind)
+	}
+	count := int(binary.BigEndian.Uint16(data[start+3:]))
+	pointers := start + headerSize
+	if pointers+2*count > len(data) {
+		return nil, errSQLiteFormat
+	}
+	p.cells = make([]int, count)
+	for i := range p.cells {
+		p.cells[i] = int(binary.BigEndian.Uint16(data[pointers+2*i:]))
+		if p.cells[i] >= len(data) {
+			return nil, errSQLiteFormat
+		}
+	}
+	return p, nil
+}
+
+// sqliteCell is a cell of a b-tree page: a child page on interior pages, a
+// rowid in tables and a record on table leaves and in indexes.
+type sqliteCell struct {
+	child  uint32
+	rowid  int64
+	record []any
+}
+
+// cell reads the i-th cell of the page. Unless whole is set, the overflow
+// pages are not read and the last values of a long record are left nil.
+func (s *sqliteFile) cell(p *btreePage, i int, whole bool) (sqliteCell, error) {
+	var c sqliteCell
+	b := p.data[p.cells[i]:]
+	if p.kind == interiorIndexPage || p.kind == interiorTablePage {
+		if len(b) < 4 {
+			return c, errSQLiteFormat
+		}
+		c.child = binary.BigEndian.Uint32(b)
+		b = b[4:]
+	}
+	if p.kind == interiorTablePage {
+		rowid, n := sqliteVarint(b)
+		if n == 0 {
+			return c, errSQLiteFormat
+		}
+		c.rowid = int64(rowid)
+		return c, nil
+	}
+
+	size, n := sqliteVarint(b)
+	if n == 0 {
+		return c, errSQLiteFormat
+	}
+	b = b[n:]
+	if p.kind == leafTablePage {
+		rowid, n := sqliteVarint(b)
+		if n == 0 {
+			return c, errSQLiteFormat
+		}
+		c.rowid = int64(rowid)
+		b = b[n:]
+	}
+	payload, err := s.payload(b, int(size), p.kind != leafTablePage, whole)
+	if err != nil {
+		return c, err
+	}
+	c.record = decodeRecord(payload)
+	return c, nil
+}
+
+// payload returns the record of a cell, b starting with the part stored in
+// the page, followed by the number of the first overflow page.
+func (s *sqliteFile) payload(b []byte, size int, index, whole bool) ([]byte, error) {
+	local := s.localPayload(size, index)
+	if local > len(b) {
+		return nil, errSQLiteFormat
+	}
+	if local == size || !whole {
+		return b[:local], nil
+	}
+	if local+4 > len(b) {
+		return nil, errSQLiteFormat
+	}
+	payload := make([]byte, 0, size)
+	payload = append(payload, b[:local]...)
+	next := binary.BigEndian.Uint32(b[local:])
+	for len(payload) < size {
+		page, err := s.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = binary.BigEndian.Uint32(page)
+		payload = append(payload, page[4:4+min(s.usable-4, size-len(payload))]...)
+	}
+	return payload, nil
+}
+
+// localPayload is the number of bytes of a payload stored in its page, as
+// given by the file format.
+func (s *sqliteFile) localPayload(size int, index bool) int {
+	maxLocal := s.usable - 35
+	if index {
+		maxLocal = (s.usable-12)*64/255 - 23
+	}
+	if size <= maxLocal {
+		return size
+	}
+	minLocal := (s.usable-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(s.usable-4)
+	if local <= maxLocal {
+		return local
+	}
+	return minLocal
+}
+
+// scan calls fn with the rows of the table rooted at root, in rowid order.
+func (s *sqliteFile) scan(root uint32, whole bool, fn func(rowid int64, record []any) error) error {
+	return s.scanPage(root, whole, fn, 0)
+}
+
+func (s *sqliteFile) scanPage(n uint32, whole bool, fn func(rowid int64, record []any) error, depth int) error {
+	if depth > maxBTreeDepth {
+		return errSQLiteFormat
+	}
+	p, err := s.btreePage(n)
+	if err != nil {
+		return err
+	}
+	switch p.kind {
+	case interiorTablePage:
+		for i := range p.cells {
+			c, err := s.cell(p, i, whole)
+			if err != nil {
+				return err
+			}
+			if err := s.scanPage(c.child, whole, fn, depth+1); err != nil {
+				return err
+			}
+		}
+		return s.scanPage(p.right, whole, fn, depth+1)
+	case leafTablePage:
+		for i := range p.cells {
+			c, err := s.cell(p, i, whole)
+			if err != nil {
+				return err
+			}
+			if err := fn(c.rowid, c.record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w : page %d n'est pas une table", errSQLiteFormat, n)
+}
+
+// row returns the row of the table rooted at root with the rowid, nil when
+// there is none.
+func (s *sqliteFile) row(root uint32, rowid int64) ([]any, error) {
+	n := root
+	for range maxBTreeDepth {
+		p, err := s.btreePage(n)
+		if err != nil {
+			return nil, err
+		}
+		switch p.kind {
+		case interiorTablePage:
+			n = p.right
+			for i := range p.cells {
+				c, err := s.cell(p, i, false)
+				if err != nil {
+					return nil, err
+				}
+				if rowid <= c.rowid {
+					n = c.child
+					break
+				}
+			}
+		case leafTablePage:
+			for i := range p.cells {
+				c, err := s.cell(p, i, false)
+				if err != nil {
+					return nil, err
+				}
+				if c.rowid == rowid {
+					c, err = s.cell(p, i, true)
+					return c.record, err
+				}
+			}
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("%w : page %d n'est pas une table", errSQLiteFormat, n)
+		}
+	}
+	return nil, errSQLiteFormat
+}
+
+// find looks the key up in the index rooted at root, returning the rowid of
+// a matching entry.
+func (s *sqliteFile) find(root uint32, key []any) (int64, bool, error) {
+	n := root
+	for range maxBTreeDepth {
+		p, err := s.btreePage(n)
+		if err != nil {
+			return 0, false, err
+		}
+		if p.kind != interiorIndexPage && p.kind != leafIndexPage {
+			return 0, false, fmt.Errorf("%w : page %d n'est pas un index", errSQLiteFormat, n)
+		}
+		n = p.right
+		for i := range p.cells {
+			c, err := s.cell(p, i, true)
+			if err != nil {
+				return 0, false, err
+			}
+			order := compareKey(c.record, key)
+			if order == 0 {
+				// the rowid of the row comes after the indexed columns
+				rowid, ok := c.record[len(c.record)-1].(int64)
+				return rowid, ok, nil
+			}
+			if order > 0 {
+				n = c.child
+				break
+			}
+		}
+		if p.kind == leafIndexPage {
+			return 0, false, nil
+		}
+	}
+	return 0, false, errSQLiteFormat
+}
+
+// schema lists the tables and the indexes of the file.
+func (s *sqliteFile) schema() ([]sqliteObject, error) {
+	var objects []sqliteObject
+	err := s.scan(1, true, func(_ int64, record []any) error {
+		if len(record) < 5 {
+			return errSQLiteFormat
+		}
+		o := sqliteObject{}
+		o.kind, _ = record[0].(string)
+		o.name, _ = record[1].(string)
+		o.table, _ = record[2].(string)
+		root, _ := record[3].(int64)
+		o.root = uint32(root)
+		o.sql, _ = record[4].(string)
+		objects = append(objects, o)
+		return nil
+	})
+	return objects, err
+}
+
+// sqliteLookup finds the rows of a table by the values of some of its
+// columns, through an index on those columns when the file has one, or else
+// through a map built by reading the whole table once.
+type sqliteLookup struct {
+	db      *sqliteFile
+	table   sqliteObject
+	columns []int
+	// index is the root of the index, 0 without index
+	index uint32
+
+	once sync.Once
+	rows map[string]int64
+	err  error
+}
+
+// newLookup prepares the lookup of the rows of the table by the key columns.
+func (s *sqliteFile) newLookup(schema []sqliteObject, table string, key ...string) (*sqliteLookup, error) {
+	l := &sqliteLookup{db: s}
+	found := false
+	for _, o := range schema {
+		if o.kind == "table" && strings.EqualFold(o.name, table) {
+			l.table = o
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w : pas de table %s", errSQLiteFormat, table)
+	}
+	columns := sqliteColumns(l.table.sql)
+	for _, name := range key {
+		i := indexFold(columns, name)
+		if i < 0 {
+			return nil, fmt.Errorf("%w : pas de colonne %s dans %s", errSQLiteFormat, name, table)
+		}
+		l.columns = append(l.columns, i)
+	}
+	for _, o := range schema {
+		if o.kind != "index" || !strings.EqualFold(o.table, table) || o.sql == "" {
+			continue
+		}
+		indexed := sqliteColumns(o.sql)
+		if len(indexed) < len(key) {
+			continue
+		}
+		matches := true
+		for i, name := range key {
+			matches = matches && strings.EqualFold(indexed[i], name)
+		}
+		if matches {
+			l.index = o.root
+			break
+		}
+	}
+	return l, nil
+}
+
+// find returns the row whose key columns hold the key, nil when there is
+// none.
+func (l *sqliteLookup) find(key ...any) ([]any, error) {
+	var rowid int64
+	var found bool
+	if l.index != 0 {
+		var err error
+		if rowid, found, err = l.db.find(l.index, key); err != nil {
+			return nil, err
+		}
+	} else {
+		l.once.Do(l.readRows)
+		if l.err != nil {
+			return nil, l.err
+		}
+		rowid, found = l.rows[fmt.Sprint(key...)]
+	}
+	if !found {
+		return nil, nil
+	}
+	return l.db.row(l.table.root, rowid)
+}
+
+func (l *sqliteLookup) readRows() {
+	l.rows = make(map[string]int64)
+	l.err = l.db.scan(l.table.root, false, func(rowid int64, record []any) error {
+		key := make([]any, len(l.columns))
+		for i, c := range l.columns {
+			if c < len(record) {
+				key[i] = record[c]
+			}
+		}
+		l.rows[fmt.Sprint(key...)] = rowid
+		return nil
+	})
+}
+
+// sqliteColumns returns the column names of a CREATE TABLE or CREATE INDEX
+// statement.
+func sqliteColumns(sql string) []string {
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	var parts []string
+	depth, from := 0, start+1
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, sql[from:i])
+				from = i + 1
+			}
+		}
+	}
+	parts = append(parts, sql[from:end])
+
+	var columns []string
+	for _, part := range parts {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PRIMARY", "UNIQUE", "CONSTRAINT", "CHECK", "FOREIGN":
+			continue
+		}
+		columns = append(columns, strings.Trim(fields[0], "\"'`[]"))
+	}
+	return columns
+}
+
+func indexFold(values []string, value string) int {
+	for i, v := range values {
+		if strings.EqualFold(v, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sqliteVarint decodes a variable length integer, returning its length or
+// 0 when b is too short.
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// decodeRecord decodes the values of a record into nil, int64, float64,
+// string or []byte values. Values past the end of a truncated record are nil.
+func decodeRecord(b []byte) []any {
+	headerSize, n := sqliteVarint(b)
+	if n == 0 || headerSize > uint64(len(b)) {
+		return nil
+	}
+	var types []uint64
+	for pos := n; pos < int(headerSize); {
+		t, n := sqliteVarint(b[pos:headerSize])
+		if n == 0 {
+			return nil
+		}
+		types = append(types, t)
+		pos += n
+	}
+
+	values := make([]any, len(types))
+	body := int(headerSize)
+	for i, t := range types {
+		size := serialSize(t)
+		if body+size > len(b) {
+			break
+		}
+		values[i] = serialValue(t, b[body:body+size])
+		body += size
+	}
+	return values
+}
+
+func serialSize(t uint64) int {
+	switch {
+	case t <= 4:
+		return int(t)
+	case t == 5:
+		return 6
+	case t == 6 || t == 7:
+		return 8
+	case t >= 12:
+		return int(t-12) / 2
+	}
+	return 0
+}
+
+func serialValue(t uint64, b []byte) any {
+	switch {
+	case t == 0:
+		return nil
+	case t <= 6:
+		var v int64
+		for _, c := range b {
+			v = v<<8 | int64(c)
+		}
+		// sign extension
+		shift := 64 - 8*len(b)
+		return v << shift >> shift
+	case t == 7:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	case t == 8:
+		return int64(0)
+	case t == 9:
+		return int64(1)
+	case t >= 12 && t%2 == 0:
+		return b
+	case t >= 13:
+		return string(b)
+	}
+	return nil
+}
+
+// compareKey compares the first values of an index record with the key.
+func compareKey(record, key []any) int {
+	for i, k := range key {
+		if i >= len(record) {
+			return -1
+		}
+		if order := compareValues(record[i], k); order != 0 {
+			return order
+		}
+	}
+	return 0
+}
+
+// compareValues orders values the way SQLite does with the binary
+// collation: nulls, then numbers, texts and blobs.
+func compareValues(a, b any) int {
+	if order := cmp.Compare(valueClass(a), valueClass(b)); order != 0 {
+		return order
+	}
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+		return cmp.Compare(float64(a), b.(float64))
+	case float64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, float64(b))
+		}
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	}
+	return 0
+}
+
+func valueClass(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	}
+	return 3
+}
@@ -0,0 +1,61 @@
+# Writes the MBTiles files of the tests of the tile readers:
+#   python3 mbtiles.py
+# Pages of 512 bytes make the b-trees several levels deep with few tiles, and
+# tile 2/1/1 of plain.mbtiles overflows its page.
+import os
+import sqlite3
+
+
+def tile_data(z, x, y):
+    if (z, x, y) == (2, 1, 1):
+        return bytes(i % 251 for i in range(3000))
+    return b"%d/%d/%d" % (z, x, y)
+
+
+def create(name):
+    if os.path.exists(name):
+        os.remove(name)
+    db = sqlite3.connect(name)
+    db.execute("PRAGMA page_size = 512")
+    db.execute("CREATE TABLE metadata (name text, value text)")
+    db.executemany("INSERT INTO metadata VALUES (?, ?)", [
+        ("name", name),
+        ("format", "png"),
+        ("minzoom", "0"),
+        ("maxzoom", "4"),
+        ("attribution", "© test"),
+    ])
+    return db
+
+
+db = create("plain.mbtiles")
+db.execute("CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)")
+for z in range(5):
+    for x in range(1 << z):
+        for y in range(1 << z):
+            # rows are counted from the south
+            db.execute("INSERT INTO tiles VALUES (?, ?, ?, ?)", (z, x, (1 << z) - 1 - y, tile_data(z, x, y)))
+db.execute("CREATE UNIQUE INDEX tile_index ON tiles (zoom_level, tile_column, tile_row)")
+db.commit()
+db.execute("VACUUM")
+db.close()
+
+# deduplicated tiles, the images table being read without index
+db = create("dedup.mbtiles")
+db.execute("CREATE TABLE map (zoom_level integer, tile_column integer, tile_row integer, tile_id text)")
+db.execute("CREATE TABLE images (tile_data blob, tile_id text)")
+db.execute("CREATE UNIQUE INDEX map_index ON map (zoom_level, tile_column, tile_row)")
+db.execute("""CREATE VIEW tiles AS SELECT map.zoom_level AS zoom_level, map.tile_column AS tile_column,
+    map.tile_row AS tile_row, images.tile_data AS tile_data FROM map JOIN images ON images.tile_id = map.tile_id""")
+images = set()
+for z in range(3):
+    for x in range(1 << z):
+        for y in range(1 << z):
+            tile_id = "sea" if x % 2 == 0 else "%d/%d/%d" % (z, x, y)
+            db.execute("INSERT INTO map VALUES (?, ?, ?, ?)", (z, x, (1 << z) - 1 - y, tile_id))
+            if tile_id not in images:
+                images.add(tile_id)
+                db.execute("INSERT INTO images VALUES (?, ?)", (tile_id.encode(), tile_id))
+db.commit()
+db.execute("VACUUM")
+db.close()
@@ -0,0 +1,143 @@
+package data
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_ "golang.org/x/image/webp"
+)
+
+// MaxTileZoom is the deepest zoom level of web map tiles.
+const MaxTileZoom = 22
+
+var errVectorTiles = errors.New("les tuiles vectorielles ne sont pas prises en charge, seules les tuiles images le sont")
+
+// TileSource gives the raster tiles of a base map in the XYZ scheme of web
+// maps: the tiles are in Web Mercator and y grows southward from the top of
+// the world.
+type TileSource interface {
+	Info() TileSetInfo
+	// Tile returns the encoded image of a tile, nil when the tile set does
+	// not have it.
+	Tile(z, x, y int) ([]byte, error)
+	Close() error
+}
+
+// TileSetInfo describes a tile set, from its metadata.
+type TileSetInfo struct {
+	Name string
+	// Format is png, jpg or webp
+	Format      string
+	MinZoom     int
+	MaxZoom     int
+	Attribution string
+}
+
+// OpenTileSource opens an MBTiles or a PMTiles file according to its
+// extension.
+func OpenTileSource(path string) (TileSource, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mbtiles":
+		return openMBTiles(path)
+	case ".pmtiles":
+		return openPMTiles(path)
+	}
+	return nil, fmt.Errorf("format de fond de carte inconnu : %s", filepath.Base(path))
+}
+
+type TileKey struct {
+	Z, X, Y int
+}
+
+// TileCache decodes the tiles of a source, keeping the last used ones so
+// that moving the map does not read and decode them again. It is safe for
+// concurrent use.
+type TileCache struct {
+	source   TileSource
+	capacity int
+
+	mu    sync.Mutex
+	tiles map[TileKey]*list.Element
+	// order holds the cached tiles, the most recently used first
+	order *list.List
+}
+
+type cachedTile struct {
+	key   TileKey
+	image *image.RGBA
+}
+
+func NewTileCache(source TileSource, capacity int) *TileCache {
+	return &TileCache{
+		source:   source,
+		capacity: capacity,
+		tiles:    make(map[TileKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *TileCache) Source() TileSource {
+	return c.source
+}
+
+// Tile returns the decoded tile, nil when the source does not have it.
+func (c *TileCache) Tile(z, x, y int) (*image.RGBA, error) {
+	key := TileKey{Z: z, X: x, Y: y}
+	c.mu.Lock()
+	if e, ok := c.tiles[key]; ok {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cachedTile).image, nil
+	}
+	c.mu.Unlock()
+
+	encoded, err := c.source.Tile(z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	var tile *image.RGBA
+	if encoded != nil {
+		if tile, err = decodeTile(encoded); err != nil {
+			return nil, fmt.Errorf("tuile %d/%d/%d : %w", z, x, y, err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// missing tiles are remembered too, so that they are not looked up again
+	if _, ok := c.tiles[key]; !ok {
+		c.tiles[key] = c.order.PushFront(&cachedTile{key: key, image: tile})
+	}
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.tiles, oldest.Value.(*cachedTile).key)
+	}
+	return tile, nil
+}
+
+func (c *TileCache) Close() error {
+	return c.source.Close()
+}
+
+func decodeTile(encoded []byte) (*image.RGBA, error) {
+	img, _, err := image.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba, nil
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba, nil
+}
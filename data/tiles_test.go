@@ -0,0 +1,99 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// fakeTiles has PNG tiles in the western half of the world, and counts the
+// tiles read.
+type fakeTiles struct {
+	reads map[TileKey]int
+}
+
+func (f *fakeTiles) Info() TileSetInfo {
+	return TileSetInfo{Format: "png", MaxZoom: 4}
+}
+
+func (f *fakeTiles) Tile(z, x, y int) ([]byte, error) {
+	f.reads[TileKey{Z: z, X: x, Y: y}]++
+	if z == 4 {
+		return []byte("not an image"), nil
+	}
+	if x >= 1<<z/2 && z > 0 {
+		return nil, nil
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.NRGBA{R: uint8(z), G: uint8(x), B: uint8(y), A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (f *fakeTiles) Close() error {
+	return nil
+}
+
+func TestTileCache(t *testing.T) {
+	source := &fakeTiles{reads: make(map[TileKey]int)}
+	cache := NewTileCache(source, 2)
+	tile := func(z, x, y int) *image.RGBA {
+		t.Helper()
+		img, err := cache.Tile(z, x, y)
+		if err != nil {
+			t.Fatalf("Tile(%d, %d, %d) : %v", z, x, y, err)
+		}
+		return img
+	}
+	reads := func(z, x, y, want int) {
+		t.Helper()
+		if got := source.reads[TileKey{Z: z, X: x, Y: y}]; got != want {
+			t.Errorf("tile %d/%d/%d read %d times, want %d", z, x, y, got, want)
+		}
+	}
+
+	img := tile(2, 1, 3)
+	if img == nil || img.RGBAAt(0, 0) != (color.RGBA{R: 2, G: 1, B: 3, A: 255}) {
+		t.Fatalf("Tile(2, 1, 3) = %v", img)
+	}
+	if tile(2, 1, 3) != img {
+		t.Error("the cached tile is not returned")
+	}
+	reads(2, 1, 3, 1)
+
+	// missing tiles are cached too
+	if tile(2, 3, 0) != nil || tile(2, 3, 0) != nil {
+		t.Error("Tile(2, 3, 0) is not missing")
+	}
+	reads(2, 3, 0, 1)
+
+	// 2/1/3 was used last, 2/3/0 is evicted
+	tile(2, 1, 3)
+	tile(1, 0, 0)
+	tile(2, 1, 3)
+	reads(2, 1, 3, 1)
+	tile(2, 3, 0)
+	reads(2, 3, 0, 2)
+	// then 1/0/0, the least recently used
+	tile(1, 0, 0)
+	reads(1, 0, 0, 2)
+	reads(2, 1, 3, 1)
+
+	if _, err := cache.Tile(4, 0, 0); err == nil || !strings.Contains(err.Error(), "4/0/0") {
+		t.Errorf("Tile(4, 0, 0) error = %v, want an error naming the tile", err)
+	}
+}
+
+func TestOpenTileSourceUnknown(t *testing.T) {
+	_, err := OpenTileSource("carte.geojson")
+	if err == nil || errors.Is(err, errSQLiteFormat) {
+		t.Errorf("OpenTileSource(carte.geojson) error = %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+const TrendSignificance = 0.05
+
+var ErrNotEnoughData = errors.New("not enough data")
+
+type Variable int
+
+const (
+	Rain Variable = iota
+	MeanTemperature
+)
+
+func (v Variable) String() string {
+	switch v {
+	case MeanTemperature:
+		return "Température moyenne"
+	default:
+		return "Cumul de pluie"
+	}
+}
+
+func (v Variable) Unit() string {
+	switch v {
+	case MeanTemperature:
+		return "°C"
+	default:
+		return "mm"
+	}
+}
+
+func (v Variable) sqlAggregate() (value, count string) {
+	switch v {
+	case MeanTemperature:
+		return "avg((CAST(TN AS DOUBLE) + CAST(TX AS DOUBLE)) / 2)", "count(TN + TX)"
+	default:
+		return "sum(CAST(RR AS DOUBLE))", "count(RR)"
+	}
+}
+
+type TrendResult struct {
+	N              int
+	S              float64
+	Z              float64
+	PValue         float64
+	Significant    bool
+	SlopePerDecade float64
+	Intercept      float64
+	Fitted         []SeriesPoint
+}
+
+func GetPeriodSeries(ctx context.Context, db *sql.DB, station *StationInfo, variable Variable, period Period) ([]SeriesPoint, error) {
+	series, err := queryPeriodSeries(ctx, db, station.MemberIds(), variable, period)
+	if err != nil {
+		return nil, err
+	}
+	return AggregateSeries(series, station.members()), nil
+}
+
+func GetTrendByStation(ctx context.Context, db *sql.DB, variable Variable, period Period) (map[string]TrendResult, error) {
+	series, err := queryPeriodSeries(ctx, db, nil, variable, period)
+	if err != nil {
+		return nil, err
+	}
+	response := make(map[string]TrendResult, len(series))
+	for numPost, s := range series {
+		trend, err := ComputeTrend(s)
+		if err != nil {
+			continue
+		}
+		response[numPost] = *trend
+	}
+	return response, nil
+}
+
+// queryPeriodSeries aggregates the variable per station and period, keeping
+// only the periods with enough observations. Points are dated on the first
+// day of their period.
+func queryPeriodSeries(ctx context.Context, db *sql.DB, ids []string, variable Variable, period Period) (map[string][]SeriesPoint, error) {
+	value, count := variable.sqlAggregate()
+	key, periodFilter := period.sqlKey("D")
+	filter, args := stationFilter(ids)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT ID, P, VALUE
+		FROM (
+			SELECT CAST(NUM_POSTE AS VARCHAR) AS ID, %s AS P, %s AS VALUE, %s AS N
+			FROM (SELECT *, %s AS D FROM %s WHERE %s)
+			WHERE %s
+			GROUP BY ID, P
+		)
+		WHERE N >= %s * %f
+		ORDER BY ID, P ASC
+	`, key, value, count, parquetDate, parquetSource, filter, periodFilter, period.sqlDays("P"), minYearCoverage), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make(map[string][]SeriesPoint)
+
+	var (
+		id    string
+		start time.Time
+		v     sql.NullFloat64
+	)
+
+	for rows.Next() {
+		if err := rows.Scan(&id, &start, &v); err != nil {
+			return nil, err
+		}
+		if !v.Valid {
+			continue
+		}
+		response[id] = append(response[id], SeriesPoint{
+			T:     time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC),
+			Value: v.Float64,
+		})
+	}
+	return response, rows.Err()
+}
+
+// ComputeTrend runs a Mann-Kendall test (with ties correction) on the series
+// and estimates its slope with the Theil-Sen estimator.
+func ComputeTrend(series []SeriesPoint) (*TrendResult, error) {
+	n := len(series)
+	if n < 4 {
+		return nil, ErrNotEnoughData
+	}
+
+	x := make([]float64, n)
+	for i, p := range series {
+		x[i] = decimalYear(p.T)
+	}
+
+	s := 0.0
+	slopes := make([]float64, 0, n*(n-1)/2)
+	for i := 0; i < n-1; i++ {
+		for j := i + 1; j < n; j++ {
+			diff := series[j].Value - series[i].Value
+			switch {
+			case diff > 0:
+				s++
+			case diff < 0:
+				s--
+			}
+			if x[j] != x[i] {
+				slopes = append(slopes, diff/(x[j]-x[i]))
+			}
+		}
+	}
+
+	ties := make(map[float64]int)
+	for _, p := range series {
+		ties[p.Value]++
+	}
+	variance := float64(n*(n-1)*(2*n+5)) / 18
+	for _, t := range ties {
+		if t > 1 {
+			variance -= float64(t*(t-1)*(2*t+5)) / 18
+		}
+	}
+
+	z := 0.0
+	if variance > 0 {
+		switch {
+		case s > 0:
+			z = (s - 1) / math.Sqrt(variance)
+		case s < 0:
+			z = (s + 1) / math.Sqrt(variance)
+		}
+	}
+	pValue := math.Erfc(math.Abs(z) / math.Sqrt2)
+
+	slices.Sort(slopes)
+	slope := median(slopes)
+
+	residuals := make([]float64, n)
+	for i, p := range series {
+		residuals[i] = p.Value - slope*x[i]
+	}
+	slices.Sort(residuals)
+	intercept := median(residuals)
+
+	fitted := make([]SeriesPoint, n)
+	for i, p := range series {
+		fitted[i] = SeriesPoint{T: p.T, Value: intercept + slope*x[i]}
+	}
+
+	return &TrendResult{
+		N:              n,
+		S:              s,
+		Z:              z,
+		PValue:         pValue,
+		Significant:    pValue < TrendSignificance,
+		SlopePerDecade: slope * 10,
+		Intercept:      intercept,
+		Fitted:         fitted,
+	}, nil
+}
+
+func median(sorted []float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func decimalYear(t time.Time) float64 {
+	return float64(t.Year()) + float64(t.YearDay()-1)/float64(daysInYear(t.Year()))
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// yearlySeries gives the values to the years from 2000 on.
+func yearlySeries(values ...float64) []SeriesPoint {
+	series := make([]SeriesPoint, len(values))
+	for i, v := range values {
+		series[i] = SeriesPoint{T: time.Date(2000+i, time.January, 1, 0, 0, 0, 0, time.UTC), Value: v}
+	}
+	return series
+}
+
+func TestComputeTrend(t *testing.T) {
+	tests := []struct {
+		name           string
+		series         []SeriesPoint
+		s, z, pValue   float64
+		slopePerDecade float64
+		intercept      float64
+		significant    bool
+	}{
+		{"increasing", yearlySeries(1, 2, 3, 4, 5), 10, 2.2045407685048604, 0.027486336111510353, 10, -1999, true},
+		// the variance is reduced by the three pairs of ties
+		{"ties", yearlySeries(1, 1, 2, 2, 3, 3, 4), 18, 2.6442268215625844, 0.008187776844595674, 5, -999, true},
+		// the outlier barely changes the slope of Sen
+		{"outlier", yearlySeries(10, 9, 8, 100, 6, 5), -9, -1.502938298604359, 0.13285495573105385, -10, 2010, false},
+		{"constant", yearlySeries(3, 3, 3, 3), 0, 0, 1, 0, 3, false},
+		{"noisy", yearlySeries(5.2, 4.8, 6.1, 5.9, 7.3, 6.8, 8.0, 7.7, 9.1, 8.6), 35, 3.041052449399714, 0.002357527595795495, 4.5, -895.125, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trend, err := ComputeTrend(test.series)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trend.N != len(test.series) || trend.S != test.s || trend.Significant != test.significant {
+				t.Errorf("N %d, S %v, significant %v, want %d, %v, %v", trend.N, trend.S, trend.Significant, len(test.series), test.s, test.significant)
+			}
+			if math.Abs(trend.Z-test.z) > 1e-9 || math.Abs(trend.PValue-test.pValue) > 1e-9 {
+				t.Errorf("Z %v, p %v, want %v, %v", trend.Z, trend.PValue, test.z, test.pValue)
+			}
+			if math.Abs(trend.SlopePerDecade-test.slopePerDecade) > 1e-9 || math.Abs(trend.Intercept-test.intercept) > 1e-6 {
+				t.Errorf("slope %v per decade, intercept %v, want %v, %v", trend.SlopePerDecade, trend.Intercept, test.slopePerDecade, test.intercept)
+			}
+			last := trend.Fitted[len(trend.Fitted)-1]
+			if want := test.intercept + test.slopePerDecade/10*decimalYear(last.T); math.Abs(last.Value-want) > 1e-6 {
+				t.Errorf("fitted %v in %d, want %v", last.Value, last.T.Year(), want)
+			}
+		})
+	}
+
+	if _, err := ComputeTrend(yearlySeries(1, 2, 3)); !errors.Is(err, ErrNotEnoughData) {
+		t.Errorf("error with 3 values = %v, want %q", err, ErrNotEnoughData)
+	}
+}
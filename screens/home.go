@@ -1,6 +1,7 @@
 package screens
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
@@ -8,12 +9,9 @@ import (
 	appcontext "meteo/context"
 	"meteo/data"
 	"meteo/screens/home"
-	"slices"
-	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 )
@@ -25,16 +23,27 @@ type HomeScreen struct {
 	sidebar       *home.HomeSidebar
 	homeMap       *home.HomeMap
 	stations      []data.StationInfo
-	stationList   binding.List[string]
+	communes      data.Communes
 	mapDimension  common.Dimension
 	tabsContainer *container.DocTabs
+	comparison    *home.ComparisonComponent
+	comparisonTab *container.TabItem
+	console       *home.ConsoleComponent
+	consoleTab    *container.TabItem
+	altitude      *home.AltitudeComponent
+	altitudeTab   *container.TabItem
+	records       *home.RecordsComponent
+	recordsTab    *container.TabItem
+	// closers release the resources of a tab when it is closed
+	closers map[*container.TabItem]func()
+	// stationTabs holds the open station tabs by NUM_POSTE
+	stationTabs map[string]*container.TabItem
 }
 
 func InitHomeScreen() *HomeScreen {
-	stationList := binding.NewStringList()
 	appContext := appcontext.GetAppContext()
 
-	sidebar := home.InitHomeSidebar(appContext.W, stationList)
+	sidebar := home.InitHomeSidebar(appContext.W)
 
 	dimension := common.Dimension{
 		Width:  600,
@@ -49,8 +58,9 @@ func InitHomeScreen() *HomeScreen {
 		sidebar:      sidebar,
 		homeMap:      homeMap,
 		stations:     make([]data.StationInfo, 0, 1000),
-		stationList:  stationList,
 		mapDimension: dimension,
+		closers:      make(map[*container.TabItem]func()),
+		stationTabs:  make(map[string]*container.TabItem),
 	}
 
 	sidebar.HandleLoadDepartment = h.loadDepartmentHandler()
@@ -60,9 +70,16 @@ func InitHomeScreen() *HomeScreen {
 
 func (h *HomeScreen) Render() fyne.CanvasObject {
 	h.homeMap.ShowDetailView = h.handleShowDetailsView
+	h.homeMap.AddToCompare = h.handleCompareStation
+	h.homeMap.LoadDepartment = h.sidebar.HandleLoadDepartment
 	iMap := h.homeMap.Render()
 
 	h.sidebar.HandleSelectStation = h.handleSelectStation
+	h.sidebar.HandleShowAggregate = h.handleShowAggregate
+	h.sidebar.HandleOpenConsole = h.handleOpenConsole
+	h.sidebar.HandleOpenAltitude = h.handleOpenAltitude
+	h.sidebar.HandleOpenRecords = h.handleOpenRecords
+	h.sidebar.HandleCompareStation = h.handleCompareStation
 
 	mapTab := container.NewTabItem("Cartes", iMap)
 
@@ -74,6 +91,7 @@ func (h *HomeScreen) Render() fyne.CanvasObject {
 		if ti == mapTab {
 			return
 		}
+		h.closeTab(ti)
 	}
 
 	split := container.NewHSplit(
@@ -85,40 +103,147 @@ func (h *HomeScreen) Render() fyne.CanvasObject {
 	return split
 }
 
-func (h *HomeScreen) handleSelectStation(name string) {
-	station, err := getStationByName(h.stations, name)
-	if err != nil {
-		dialog.NewError(err, h.window)
-		return
-	}
+func (h *HomeScreen) handleSelectStation(station *data.StationInfo) {
 	h.homeMap.HandleStationWindow(station, h.handleShowDetailsView)
 }
 
+func (h *HomeScreen) handleCompareStation(station *data.StationInfo) {
+	if h.comparison == nil {
+		h.comparison = home.InitComparisonComponent()
+		h.comparison.OnEmptied = func() {
+			h.closeTab(h.comparisonTab)
+		}
+		h.comparisonTab = container.NewTabItem("Comparaison", h.comparison.Render())
+		h.closers[h.comparisonTab] = func() {
+			h.comparison.Close()
+			h.comparison = nil
+			h.comparisonTab = nil
+		}
+		h.tabsContainer.Append(h.comparisonTab)
+	}
+	h.comparison.AddStation(station)
+	h.tabsContainer.Select(h.comparisonTab)
+}
+
+func (h *HomeScreen) handleOpenConsole() {
+	if h.console == nil {
+		h.console = home.InitConsoleComponent()
+		h.consoleTab = container.NewTabItem("SQL", h.console.Render())
+		h.closers[h.consoleTab] = func() {
+			h.console.Close()
+			h.console = nil
+			h.consoleTab = nil
+		}
+		h.tabsContainer.Append(h.consoleTab)
+	}
+	h.tabsContainer.Select(h.consoleTab)
+}
+
+func (h *HomeScreen) handleOpenAltitude() {
+	if h.altitude == nil {
+		h.altitude = home.InitAltitudeComponent()
+		h.altitude.Stations = func() []data.StationInfo { return h.stations }
+		h.altitude.VisibleStations = h.homeMap.VisibleStations
+		h.altitude.OnOpenStation = h.handleShowDetailsView
+		h.altitudeTab = container.NewTabItem("Altitude", h.altitude.Render())
+		h.closers[h.altitudeTab] = func() {
+			h.altitude.Close()
+			h.altitude = nil
+			h.altitudeTab = nil
+		}
+		h.tabsContainer.Append(h.altitudeTab)
+	}
+	h.tabsContainer.Select(h.altitudeTab)
+}
+
+func (h *HomeScreen) handleOpenRecords() {
+	if h.records == nil {
+		h.records = home.InitRecordsComponent()
+		h.records.Stations = func() []data.StationInfo { return h.stations }
+		h.records.OnOpenStation = h.handleShowDetailsView
+		h.recordsTab = container.NewTabItem("Records", h.records.Render())
+		h.closers[h.recordsTab] = func() {
+			h.records.Close()
+			h.records = nil
+			h.recordsTab = nil
+		}
+		h.tabsContainer.Append(h.recordsTab)
+	}
+	h.tabsContainer.Select(h.recordsTab)
+}
+
+func (h *HomeScreen) handleShowAggregate(zone home.AggregateZone, weighting data.Weighting) {
+	// an import replaces the stations on the UI goroutine meanwhile
+	stations, geo := h.stations, h.homeMap.GeoData()
+	go func() {
+		var (
+			station *data.StationInfo
+			err     error
+		)
+		if zone.Region != nil {
+			station, err = data.NewRegionStation(stations, *zone.Region, weighting, geo)
+		} else {
+			station, err = data.NewDepartmentStation(stations, zone.Department, weighting, geo)
+		}
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, h.window)
+				return
+			}
+			h.handleShowDetailsView(station)
+		})
+	}()
+}
+
 func (h *HomeScreen) handleShowDetailsView(station *data.StationInfo) {
+	if tab, ok := h.stationTabs[station.NumPost]; ok {
+		h.tabsContainer.Select(tab)
+		return
+	}
 	view := home.InitStationDetailsComponent(common.Dimension{Width: 600, Height: 600})
-	c := view.Render(station)
+	viewTab := container.NewTabItem(station.Label(), view.Render(station))
+	h.stationTabs[station.NumPost] = viewTab
+	h.closers[viewTab] = func() {
+		delete(h.stationTabs, station.NumPost)
+		view.Close()
+	}
+	h.tabsContainer.Append(viewTab)
+	h.tabsContainer.Select(viewTab)
+}
 
-	if c != nil {
-		viewTab := container.NewTabItem(station.CommonName, c)
-		h.tabsContainer.Append(viewTab)
-	} else {
-		dialog.ShowError(fmt.Errorf("impossible de charger les données de la station %s", station.CommonName), h.window)
+// closeTab removes the tab after releasing its resources.
+func (h *HomeScreen) closeTab(ti *container.TabItem) {
+	if closer, ok := h.closers[ti]; ok {
+		delete(h.closers, ti)
+		closer()
 	}
+	h.tabsContainer.Remove(ti)
 }
 
 func (h *HomeScreen) LoadExistingData() {
-	existingStations, err := data.GetStations(h.db)
+	existingStations, err := data.GetStations(context.Background(), h.db)
 	if err != nil {
 		h.logger.Error("Failed to load existing stations", "error", err)
 		return
 	}
 	h.stations = existingStations
+	h.communes = h.loadCommunes()
 	h.refreshUI()
 }
 
+// loadCommunes reads the communes of the imported departments, the search
+// doing without them when they can't be read.
+func (h *HomeScreen) loadCommunes() data.Communes {
+	communes, err := data.LoadCommunes()
+	if err != nil {
+		h.logger.Warn("Failed to load the communes", "error", err)
+	}
+	return communes
+}
+
 func (h *HomeScreen) refreshUI() {
-	h.homeMap.AddStationsLayer(h.stations)
-	h.stationList.Set(stationsNameList(h.stations))
+	h.homeMap.SetStations(h.stations)
+	h.sidebar.SetStations(h.stations, h.communes)
 }
 
 func (h *HomeScreen) loadDepartmentHandler() func(string) {
@@ -126,59 +251,61 @@ func (h *HomeScreen) loadDepartmentHandler() func(string) {
 		if len(dpt) == 1 {
 			dpt = "0" + dpt
 		}
-		progress := dialog.NewCustomWithoutButtons(
+		ctx, cancel := context.WithCancel(context.Background())
+		progress := dialog.NewCustom(
 			"Import en cours",
+			"Annuler",
 			container.NewVBox(
 				widget.NewLabel(fmt.Sprintf("Import du département %s...", dpt)),
 				widget.NewProgressBarInfinite(),
 			),
 			h.window,
 		)
+		progress.SetOnClosed(cancel)
 		progress.Show()
 
 		abort := func(err error) {
 			fyne.Do(func() {
+				progress.SetOnClosed(nil)
 				progress.Hide()
-				dialog.ShowError(err, h.window)
+				if ctx.Err() == nil {
+					dialog.ShowError(err, h.window)
+				}
 			})
 		}
 
 		go func() {
-			err := data.DownloadParquetFile(dpt)
+			defer cancel()
+			err := data.DownloadParquetFile(ctx, h.logger, dpt)
 			if err != nil {
 				abort(err)
 				return
 			}
-			newStations, err := data.GetStations(h.db)
+			// the stations are still found by name without their commune
+			if err := data.DownloadCommunes(ctx, dpt); err != nil && ctx.Err() == nil {
+				h.logger.Warn("Failed to download the communes", "error", err, "department", dpt)
+			}
+			if err := data.ResetDailyRecords(ctx, h.db); err != nil {
+				abort(err)
+				return
+			}
+			// the parquet glob covers every imported department, so the
+			// result replaces the stations already known
+			stations, err := data.GetStations(ctx, h.db)
 			if err != nil {
 				abort(err)
 				return
 			}
-
-			h.stations = slices.Concat(h.stations, newStations)
+			communes := h.loadCommunes()
 
 			fyne.Do(func() {
+				h.stations = stations
+				h.communes = communes
 				h.refreshUI()
+				progress.SetOnClosed(nil)
 				progress.Hide()
 				dialog.ShowInformation("Import terminé", fmt.Sprintf("Département %s importé avec succès", dpt), h.window)
 			})
 		}()
 	}
 }
-
-func stationsNameList(stations []data.StationInfo) []string {
-	names := make([]string, 0, len(stations))
-	for _, station := range stations {
-		names = append(names, station.CommonName)
-	}
-	return names
-}
-
-func getStationByName(stations []data.StationInfo, name string) (*data.StationInfo, error) {
-	for i, station := range stations {
-		if strings.EqualFold(station.CommonName, name) {
-			return &stations[i], nil
-		}
-	}
-	return nil, fmt.Errorf("Can't find station %s", name)
-}
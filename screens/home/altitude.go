@@ -0,0 +1,205 @@
+package home
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"meteo/components/ui"
+	appcontext "meteo/context"
+	"meteo/data"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const visibleStationsZone = "Stations visibles sur la carte"
+
+type AltitudeComponent struct {
+	db       *sql.DB
+	logger   *slog.Logger
+	ctx      context.Context
+	cancel   context.CancelFunc
+	requests *loader
+	variable data.Variable
+	period   data.Period
+	zone     string
+	profile  []data.AltitudePoint
+
+	// Stations returns every loaded station and VisibleStations the ones
+	// currently shown on the map.
+	Stations        func() []data.StationInfo
+	VisibleStations func() []data.StationInfo
+	OnOpenStation   func(station *data.StationInfo)
+
+	selectZone *widget.Select
+	chart      *ui.ScatterChart
+	summary    *widget.Label
+	content    fyne.CanvasObject
+}
+
+func InitAltitudeComponent() *AltitudeComponent {
+	appContext := appcontext.GetAppContext()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AltitudeComponent{
+		db:       appContext.DB,
+		logger:   appContext.Logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		requests: newLoader(ctx),
+		variable: data.Rain,
+		period:   data.CalendarYearPeriod,
+		zone:     visibleStationsZone,
+	}
+}
+
+func (a *AltitudeComponent) Render() fyne.CanvasObject {
+	if a.content != nil {
+		return a.content
+	}
+
+	a.chart = ui.NewScatterChart("Altitude (m)", "")
+	a.chart.OnPointTapped = func(i int) {
+		if i < len(a.profile) && a.OnOpenStation != nil {
+			a.OnOpenStation(a.profile[i].Station)
+		}
+	}
+	a.summary = widget.NewLabel("")
+	a.summary.Wrapping = fyne.TextWrapWord
+
+	variableLabels := make([]string, 0, len(comparisonVariables))
+	for _, v := range comparisonVariables {
+		variableLabels = append(variableLabels, fmt.Sprintf("%s (%s)", v, v.Unit()))
+	}
+	selectVariable := widget.NewSelect(variableLabels, func(label string) {
+		a.variable = comparisonVariables[slices.Index(variableLabels, label)]
+		a.refresh()
+	})
+	selectPeriod := newPeriodSelect(data.Periods, a.period, func(p data.Period) {
+		a.period = p
+		a.refresh()
+	})
+	a.selectZone = widget.NewSelect(nil, func(zone string) {
+		a.zone = zone
+		a.refresh()
+	})
+	a.refreshZones()
+
+	reload := widget.NewButtonWithIcon("Actualiser", theme.ViewRefreshIcon(), func() {
+		a.refreshZones()
+		a.refresh()
+	})
+
+	selectors := container.NewVBox(
+		container.NewGridWithColumns(2, selectVariable, a.selectZone),
+		container.NewBorder(nil, nil, nil, reload, selectPeriod),
+	)
+	a.content = container.NewBorder(selectors, a.summary, nil, nil, a.chart)
+	selectVariable.SetSelectedIndex(0)
+	return a.content
+}
+
+// Close cancels the request still running, if any.
+func (a *AltitudeComponent) Close() {
+	a.cancel()
+}
+
+// refreshZones lists the departments of the loaded stations.
+func (a *AltitudeComponent) refreshZones() {
+	zones := []string{visibleStationsZone}
+	if a.Stations != nil {
+		codes := make([]string, 0, len(data.Departments))
+		for _, s := range a.Stations() {
+			code := data.DepartmentOf(s.NumPost)
+			if !s.IsAggregate() && !slices.Contains(codes, code) {
+				codes = append(codes, code)
+			}
+		}
+		slices.Sort(codes)
+		for _, code := range codes {
+			zones = append(zones, departmentLabel(data.Department{Code: code, Name: data.DepartmentName(code)}))
+		}
+	}
+	a.selectZone.Options = zones
+	if !slices.Contains(zones, a.zone) {
+		a.zone = visibleStationsZone
+	}
+	a.selectZone.Selected = a.zone
+	a.selectZone.Refresh()
+}
+
+func (a *AltitudeComponent) zoneStations() []data.StationInfo {
+	if a.zone == visibleStationsZone {
+		if a.VisibleStations == nil {
+			return nil
+		}
+		return a.VisibleStations()
+	}
+	if a.Stations == nil {
+		return nil
+	}
+	code := a.zone[:min(2, len(a.zone))]
+	return slices.DeleteFunc(slices.Clone(a.Stations()), func(s data.StationInfo) bool {
+		return data.DepartmentOf(s.NumPost) != code
+	})
+}
+
+func (a *AltitudeComponent) refresh() {
+	if a.content == nil {
+		return
+	}
+	stations := a.zoneStations()
+	variable, period := a.variable, a.period
+	a.summary.SetText(fmt.Sprintf("Calcul sur %d stations...", len(stations)))
+
+	load(a.requests, func(ctx context.Context) ([]data.AltitudePoint, error) {
+		return data.GetAltitudeProfile(ctx, a.db, stations, variable, period)
+	}, func(profile []data.AltitudePoint, err error) {
+		if err != nil && !errors.Is(err, data.ErrNotEnoughData) {
+			a.logger.Error("Can't compute altitude profile", "error", err)
+			a.summary.SetText(fmt.Sprintf("Erreur : %s", err))
+			return
+		}
+		a.profile = profile
+		a.showProfile(variable, period)
+	})
+}
+
+func (a *AltitudeComponent) showProfile(variable data.Variable, period data.Period) {
+	points := make([]ui.ScatterPoint, 0, len(a.profile))
+	for _, p := range a.profile {
+		points = append(points, ui.ScatterPoint{
+			X:     p.Station.Alti,
+			Y:     p.Value,
+			Label: fmt.Sprintf("%s : %.1f %s", p.Station.Label(), p.Value, variable.Unit()),
+		})
+	}
+	a.chart.YLabel = fmt.Sprintf("%s (%s)", variable, variable.Unit())
+
+	fit, err := data.FitAltitude(a.profile)
+	if err != nil {
+		a.chart.SetData(points, nil)
+		a.summary.SetText(fmt.Sprintf("%d stations avec au moins 10 périodes complètes : pas assez pour une régression.", len(points)))
+		return
+	}
+	a.chart.SetData(points, fit.At)
+	a.summary.SetText(altitudeSummary(fit, variable, period))
+}
+
+func altitudeSummary(fit *data.LinearFit, variable data.Variable, period data.Period) string {
+	gradient := "Gradient orographique"
+	if variable == data.MeanTemperature {
+		gradient = "Gradient thermique"
+	}
+	return fmt.Sprintf("%s (%s) : %+.2f %s / 100 m, soit %+.1f %s / km (r² = %.2f, %d stations). Valeur extrapolée au niveau de la mer : %.1f %s.",
+		gradient, period,
+		fit.Slope*100, variable.Unit(),
+		fit.Slope*1000, variable.Unit(),
+		fit.R2, fit.N,
+		fit.Intercept, variable.Unit(),
+	)
+}
@@ -0,0 +1,188 @@
+package home
+
+import (
+	"fmt"
+	"image"
+	"log/slog"
+	"math"
+	"meteo/components/ui"
+	"meteo/data"
+
+	"fyne.io/fyne/v2"
+	"github.com/fogleman/gg"
+)
+
+const (
+	baseMapLayerID = "basemap"
+	// autoTileZoom lets the zoom level of the tiles follow the scale of the map
+	autoTileZoom = -1
+	tileSize     = 256
+	// tileCacheSize is the number of decoded tiles kept in memory
+	tileCacheSize = 256
+)
+
+// baseMapLayer draws the raster tiles of a local tile set below the other
+// layers. The tiles being in Web Mercator, every pixel of the map is looked up
+// in the tiles whatever the projection.
+type baseMapLayer struct {
+	tiles *data.TileCache
+	// zoom is the zoom level of the tiles, or autoTileZoom
+	zoom   int
+	logger *slog.Logger
+}
+
+var _ ui.Layer = (*baseMapLayer)(nil)
+
+// tilePixel gives the position of a longitude and a latitude in the tile
+// pixels of zoom level 0, the world being 256 pixels wide.
+func tilePixel(lon, lat float64) (float64, float64) {
+	lat = max(-85.05112878, min(85.05112878, lat)) * math.Pi / 180
+	x := (lon + 180) / 360 * tileSize
+	y := (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * tileSize
+	return x, y
+}
+
+// zoomLevel is the zoom level whose tile pixels are closest to the pixels of
+// the map at its centre.
+func (l *baseMapLayer) zoomLevel(v ui.Viewport) int {
+	info := l.tiles.Source().Info()
+	if l.zoom != autoTileZoom {
+		return max(info.MinZoom, min(info.MaxZoom, l.zoom))
+	}
+	cx, cy := v.Dimension().Width/2, v.Dimension().Height/2
+	x0, y0 := tilePixel(v.Unproject(cx, cy))
+	x1, y1 := tilePixel(v.Unproject(cx+1, cy))
+	scale := math.Hypot(x1-x0, y1-y0)
+	if scale == 0 {
+		return info.MinZoom
+	}
+	return max(info.MinZoom, min(info.MaxZoom, int(math.Round(-math.Log2(scale)))))
+}
+
+func (l *baseMapLayer) Draw(dc *gg.Context, v ui.Viewport) {
+	w, h := dc.Width(), dc.Height()
+	z := l.zoomLevel(v)
+	world := float64(int(tileSize) << z)
+
+	positions := newScreenGrid(w, h, v, func(lon, lat float64) (float64, float64) {
+		x, y := tilePixel(lon, lat)
+		return x * world / tileSize, y * world / tileSize
+	})
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	tiles := make(map[[2]int]baseMapTile)
+	var lastErr error
+	for y := range h {
+		for x := range w {
+			px, py := positions.at(x, y)
+			if py < 0 || py >= world {
+				continue
+			}
+			// the world repeats eastward and westward
+			px = math.Mod(px, world)
+			if px < 0 {
+				px += world
+			}
+			key := [2]int{int(px) / tileSize, int(py) / tileSize}
+			tile, ok := tiles[key]
+			if !ok {
+				var err error
+				if tile, err = l.tile(z, key[0], key[1]); err != nil {
+					lastErr = err
+				}
+				tiles[key] = tile
+			}
+			if tile.image == nil {
+				continue
+			}
+			// position in the tile, which may be an ancestor of the wanted one
+			scale := float64(int(1) << tile.up)
+			b := tile.image.Bounds()
+			tx := int((px/scale - float64((key[0]>>tile.up)*tileSize)) * float64(b.Dx()) / tileSize)
+			ty := int((py/scale - float64((key[1]>>tile.up)*tileSize)) * float64(b.Dy()) / tileSize)
+			src := tile.image.PixOffset(b.Min.X+max(0, min(b.Dx()-1, tx)), b.Min.Y+max(0, min(b.Dy()-1, ty)))
+			dst := img.PixOffset(x, y)
+			copy(img.Pix[dst:dst+4], tile.image.Pix[src:src+4])
+		}
+	}
+	if lastErr != nil {
+		l.logger.Warn("Can't read base map tiles", "error", lastErr)
+	}
+	dc.DrawImage(img, 0, 0)
+}
+
+// baseMapTile is a tile, or its ancestor up levels above when the tile set
+// does not have it.
+type baseMapTile struct {
+	image *image.RGBA
+	up    int
+}
+
+func (l *baseMapLayer) tile(z, x, y int) (baseMapTile, error) {
+	minZoom := l.tiles.Source().Info().MinZoom
+	for up := 0; z-up >= minZoom; up++ {
+		img, err := l.tiles.Tile(z-up, x>>up, y>>up)
+		if err != nil {
+			return baseMapTile{}, err
+		}
+		if img != nil {
+			return baseMapTile{image: img, up: up}, nil
+		}
+	}
+	return baseMapTile{}, nil
+}
+
+func (l *baseMapLayer) HitTest(ui.Viewport, fyne.Position) (int, bool) {
+	return 0, false
+}
+
+func (l *baseMapLayer) Tooltip(int) string {
+	return ""
+}
+
+func (l *baseMapLayer) legend() *ui.Legend {
+	if attribution := l.tiles.Source().Info().Attribution; attribution != "" {
+		return &ui.Legend{Note: attribution}
+	}
+	return nil
+}
+
+// refreshBaseMapLayer shows the base map below every other layer, or removes
+// it when there is no base map.
+func (h *HomeMap) refreshBaseMapLayer() {
+	if h.baseMap == nil {
+		h.iMap.RemoveLayer(baseMapLayerID)
+		return
+	}
+	name := h.baseMap.Source().Info().Name
+	if name == "" {
+		name = "Fond de carte"
+	}
+	layer := &baseMapLayer{tiles: h.baseMap, zoom: h.baseMapZoom, logger: h.logger}
+	below := ""
+	if layers := h.iMap.Layers(); len(layers) > 0 {
+		below = layers[0].ID
+	}
+	h.iMap.SetLayerBelow(baseMapLayerID, name, layer, below).Legend = layer.legend()
+	h.refreshLegend()
+}
+
+// setBaseMap opens the tile set as the base map, the previous one being
+// closed; an empty path removes the base map.
+func (h *HomeMap) setBaseMap(path string) error {
+	var tiles *data.TileCache
+	if path != "" {
+		source, err := data.OpenTileSource(path)
+		if err != nil {
+			return fmt.Errorf("fond de carte illisible : %w", err)
+		}
+		tiles = data.NewTileCache(source, tileCacheSize)
+	}
+	if h.baseMap != nil {
+		h.baseMap.Close()
+	}
+	h.baseMap = tiles
+	h.baseMapPath = path
+	h.refreshBaseMapLayer()
+	return nil
+}
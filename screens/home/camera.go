@@ -0,0 +1,131 @@
+package home
+
+import (
+	"meteo/common"
+	"meteo/data"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	minZoom = 0.5
+	maxZoom = 64.0
+	// fitMargin is the share of the map left around the area zoomed to
+	fitMargin = 0.1
+	// stationZoomSpan is the half size in degrees of the area shown around a
+	// station
+	stationZoomSpan = 0.15
+	franceZoom      = "France entière"
+)
+
+// clampCamera keeps the centre of the map over France, so that France cannot
+// be lost off-screen.
+func (h *HomeMap) clampCamera() {
+	w, hh := h.dimension.Width, h.dimension.Height
+	centreX := h.camera.X + w/(2*h.camera.Z)
+	centreY := hh/(2*h.camera.Z) - h.camera.Y
+	h.camera.X -= centreX - max(0, min(w, centreX))
+	h.camera.Y += centreY - max(0, min(hh, centreY))
+}
+
+// handlePan moves the map by (dx, dy) pixels.
+func (h *HomeMap) handlePan(dx, dy float64) {
+	h.camera.X -= dx / h.camera.Z
+	h.camera.Y += dy / h.camera.Z
+	h.clampCamera()
+	h.refreshViewport()
+}
+
+// zoomAt multiplies the zoom by factor within the zoom limits, the point
+// (x, y) of the map staying still.
+func (h *HomeMap) zoomAt(factor, x, y float64) {
+	z := max(minZoom, min(maxZoom, h.camera.Z*factor))
+	if z == h.camera.Z {
+		return
+	}
+	h.camera = common.ZoomAt(h.camera, x, y, z)
+	h.clampCamera()
+	h.refreshViewport()
+}
+
+func (h *HomeMap) handleZoom(factor float64, pos fyne.Position) {
+	h.zoomAt(factor, float64(pos.X), float64(pos.Y))
+}
+
+// zoomTo centres the map on the area, as close as the zoom limits allow.
+func (h *HomeMap) zoomTo(area data.Bounds) {
+	camera := common.FitBounds(area, h.frame, fitMargin)
+	if z := max(minZoom, min(maxZoom, camera.Z)); z != camera.Z {
+		camera = common.ZoomAt(camera, h.dimension.Width/2, h.dimension.Height/2, z)
+	}
+	h.camera = camera
+	h.clampCamera()
+	h.refreshViewport()
+}
+
+// ZoomToFrance shows the whole of metropolitan France.
+func (h *HomeMap) ZoomToFrance() {
+	h.camera = common.Position{X: 0, Y: 0, Z: 1}
+	h.refreshViewport()
+}
+
+// ZoomToDepartment shows a department given its code, 20 standing for both
+// Corsican departments.
+func (h *HomeMap) ZoomToDepartment(code string) bool {
+	area := data.Bounds{}
+	found := false
+	for _, department := range h.departments {
+		if department.Code != code && department.StationDepartment() != code {
+			continue
+		}
+		if !found {
+			area = department.Bounds
+			found = true
+			continue
+		}
+		area.MinLong = min(area.MinLong, department.Bounds.MinLong)
+		area.MaxLong = max(area.MaxLong, department.Bounds.MaxLong)
+		area.MinLat = min(area.MinLat, department.Bounds.MinLat)
+		area.MaxLat = max(area.MaxLat, department.Bounds.MaxLat)
+	}
+	if found {
+		h.zoomTo(area)
+	}
+	return found
+}
+
+func (h *HomeMap) ZoomToStation(station *data.StationInfo) {
+	h.zoomTo(data.Bounds{
+		MinLong: station.Lon - stationZoomSpan,
+		MaxLong: station.Lon + stationZoomSpan,
+		MinLat:  station.Lat - stationZoomSpan,
+		MaxLat:  station.Lat + stationZoomSpan,
+	})
+}
+
+// newZoomSelect lists France and the departments to zoom to.
+func (h *HomeMap) newZoomSelect() *widget.Select {
+	options := []string{franceZoom}
+	codes := map[string]string{}
+	for _, department := range h.departments {
+		label := departmentLabel(data.Department{Code: department.Code, Name: department.Name})
+		options = append(options, label)
+		codes[label] = department.Code
+	}
+	var zoom *widget.Select
+	zoom = widget.NewSelect(options, func(selected string) {
+		switch selected {
+		case "":
+			return
+		case franceZoom:
+			h.ZoomToFrance()
+		default:
+			h.ZoomToDepartment(codes[selected])
+		}
+		// the same area may be chosen again once the map moved
+		zoom.ClearSelected()
+	})
+	zoom.PlaceHolder = "Zoomer sur…"
+	return zoom
+}
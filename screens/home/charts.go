@@ -0,0 +1,69 @@
+package home
+
+import (
+	"fmt"
+	"meteo/data"
+
+	"fyne.io/fyne/v2/theme"
+	"github.com/s-daehling/fyne-charts/pkg/coord"
+	chartData "github.com/s-daehling/fyne-charts/pkg/data"
+)
+
+type stationSeries struct {
+	label  string
+	unit   string
+	points []data.SeriesPoint
+	trend  *data.TrendResult
+}
+
+func toTemporalPoints(points []data.SeriesPoint) []chartData.TemporalPoint {
+	response := make([]chartData.TemporalPoint, 0, len(points))
+	for _, p := range points {
+		response = append(response, chartData.TemporalPoint{
+			T:   p.T,
+			Val: p.Value,
+		})
+	}
+	return response
+}
+
+func newSeriesChart(s stationSeries, period data.Period) (*coord.CartesianTemporalChart, error) {
+	chart := coord.NewCartesianTemporalChart(fmt.Sprintf("%s - %s", s.label, period))
+	chart.SetTAxisLabel("Période")
+	chart.SetYAxisLabel(s.unit)
+
+	if len(s.points) == 0 {
+		return chart, nil
+	}
+
+	tps, err := coord.NewTemporalPointSeries(s.label, theme.ColorNamePrimary, toTemporalPoints(s.points))
+	if err != nil {
+		return nil, err
+	}
+	if err := chart.AddLineSeries(tps, true); err != nil {
+		return nil, err
+	}
+
+	if s.trend != nil {
+		fitted, err := coord.NewTemporalPointSeries("Tendance", theme.ColorNameError, toTemporalPoints(s.trend.Fitted))
+		if err != nil {
+			return nil, err
+		}
+		if err := chart.AddLineSeries(fitted, false); err != nil {
+			return nil, err
+		}
+	}
+	return chart, nil
+}
+
+func trendSummary(trend *data.TrendResult, unit string) string {
+	if trend == nil {
+		return "Tendance : pas assez de données"
+	}
+	significance := "non significative"
+	if trend.Significant {
+		significance = "significative"
+	}
+	return fmt.Sprintf("Tendance : %+.2f %s/décennie (Mann-Kendall p = %.3f, %s)",
+		trend.SlopePerDecade, unit, trend.PValue, significance)
+}
@@ -0,0 +1,327 @@
+package home
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"meteo/components/ui"
+	"meteo/data"
+	"slices"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+type colorRamp struct {
+	name string
+	// colorBlindSafe ramps stay readable with the common colour vision
+	// deficiencies.
+	colorBlindSafe bool
+	diverging      bool
+	stops          []color.NRGBA
+}
+
+const autoRamp = "Automatique"
+
+var colorRamps = []colorRamp{
+	{name: "Viridis", colorBlindSafe: true, stops: hexColors("440154", "482878", "3e4989", "31688e", "26828e", "1f9e89", "35b779", "6ece58", "b5de2b", "fde725")},
+	{name: "Cividis", colorBlindSafe: true, stops: hexColors("00224e", "123570", "3b496c", "575d6d", "707173", "8a8678", "a59c74", "c3b369", "e1cc55", "fee838")},
+	{name: "Bleus", colorBlindSafe: true, stops: hexColors("f7fbff", "deebf7", "c6dbef", "9ecae1", "6baed6", "4292c6", "2171b5", "08519c", "08306b")},
+	{name: "Bleu - rouge", stops: hexColors("0000ff", "7f507f", "ff0000")},
+	{name: "Bleu - rouge divergent (RdBu)", colorBlindSafe: true, diverging: true, stops: hexColors("2166ac", "4393c3", "92c5de", "d1e5f0", "f7f7f7", "fddbc7", "f4a582", "d6604d", "b2182b")},
+	{name: "Brun - vert divergent (BrBG)", colorBlindSafe: true, diverging: true, stops: hexColors("8c510a", "bf812d", "dfc27d", "f6e8c3", "f5f5f5", "c7eae5", "80cdc1", "35978f", "01665e")},
+	{name: "Bleu - blanc - rouge", diverging: true, stops: hexColors("0000ff", "ffffff", "ff0000")},
+}
+
+func (r colorRamp) label() string {
+	if r.colorBlindSafe {
+		return r.name + " (daltonisme)"
+	}
+	return r.name
+}
+
+// at interpolates the ramp, t going from 0 to 1.
+func (r colorRamp) at(t float64) color.Color {
+	t = math.Max(0, math.Min(1, t))
+	position := t * float64(len(r.stops)-1)
+	i := min(int(position), len(r.stops)-2)
+	ratio := position - float64(i)
+	from, to := r.stops[i], r.stops[i+1]
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*ratio))
+	}
+	return color.NRGBA{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B), A: 255}
+}
+
+func hexColors(values ...string) []color.NRGBA {
+	colors := make([]color.NRGBA, 0, len(values))
+	for _, v := range values {
+		rgb, _ := strconv.ParseUint(v, 16, 32)
+		colors = append(colors, color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255})
+	}
+	return colors
+}
+
+func findRamp(name string) (colorRamp, bool) {
+	for _, r := range colorRamps {
+		if r.name == name {
+			return r, true
+		}
+	}
+	return colorRamp{}, false
+}
+
+// choroplethStyle is how the values of a metric are turned into colours and
+// sizes on the map.
+type choroplethStyle struct {
+	ramp    string
+	method  data.ClassificationMethod
+	classes int
+	sized   bool
+}
+
+func defaultChoroplethStyle() choroplethStyle {
+	return choroplethStyle{
+		ramp:    autoRamp,
+		method:  data.Quantiles,
+		classes: 5,
+	}
+}
+
+const (
+	minStationRadius     = 2.0
+	maxStationRadius     = 7.0
+	defaultStationRadius = 3.0
+)
+
+// metricClasses holds the classes of the values of a metric and their
+// encoding.
+type metricClasses struct {
+	metric *mapMetric
+	breaks []float64
+	colors []color.Color
+	// weights go from 0 to 1 and scale the symbols when sized is set
+	weights []float64
+	sized   bool
+}
+
+func classifyMetric(m *mapMetric, result *metricResult, style choroplethStyle) *metricClasses {
+	classes := &metricClasses{metric: m, sized: style.sized}
+	if len(m.categories) > 0 {
+		for i, category := range m.categories {
+			classes.colors = append(classes.colors, category.color)
+			classes.weights = append(classes.weights, float64(i)/float64(max(1, len(m.categories)-1)))
+		}
+		return classes
+	}
+
+	values := make([]float64, 0, len(result.values))
+	for _, v := range result.values {
+		values = append(values, v)
+	}
+	classes.breaks = data.Classify(values, style.classes, style.method)
+	if len(classes.breaks) < 2 {
+		return classes
+	}
+
+	ramp, ok := findRamp(style.ramp)
+	if !ok {
+		ramp = colorRamps[0]
+		if m.diverging {
+			ramp, _ = findRamp(m.ramp)
+		}
+	}
+
+	n := len(classes.breaks) - 1
+	extent := math.Max(math.Abs(classes.breaks[0]), math.Abs(classes.breaks[n]))
+	for i := range n {
+		t := 0.5
+		if m.diverging && extent > 0 {
+			// zero stays at the centre of the ramp whatever the classes
+			middle := (classes.breaks[i] + classes.breaks[i+1]) / 2
+			t = 0.5 + 0.5*middle/extent
+		} else if n > 1 {
+			t = float64(i) / float64(n-1)
+		}
+		weight := t
+		if m.diverging {
+			weight = math.Abs(2*t - 1)
+		}
+		classes.colors = append(classes.colors, ramp.at(t))
+		classes.weights = append(classes.weights, weight)
+	}
+	return classes
+}
+
+func (c *metricClasses) class(v float64) int {
+	if len(c.metric.categories) > 0 {
+		return c.metric.category(v)
+	}
+	return data.ClassOf(c.breaks, v)
+}
+
+func (c *metricClasses) color(v float64) color.Color {
+	if len(c.colors) == 0 {
+		return color.White
+	}
+	return c.colors[min(c.class(v), len(c.colors)-1)]
+}
+
+func (c *metricClasses) radius(v float64) float64 {
+	if !c.sized || len(c.weights) == 0 {
+		return defaultStationRadius
+	}
+	weight := c.weights[min(c.class(v), len(c.weights)-1)]
+	return minStationRadius + (maxStationRadius-minStationRadius)*weight
+}
+
+func (c *metricClasses) legend() *ui.Legend {
+	legend := &ui.Legend{Title: c.metric.name}
+	if c.metric.arrows {
+		legend.Note = "Triangle plein : tendance significative"
+	}
+	if len(c.metric.categories) > 0 {
+		for _, category := range c.metric.categories {
+			legend.Entries = append(legend.Entries, ui.LegendEntry{Color: category.color, Label: category.label})
+		}
+		return legend
+	}
+	precision := legendPrecision(c.breaks)
+	for i := range c.colors {
+		legend.Entries = append(legend.Entries, ui.LegendEntry{
+			Color: c.colors[i],
+			Label: fmt.Sprintf("%.*f – %.*f %s", precision, c.breaks[i], precision, c.breaks[i+1], c.metric.unit),
+		})
+	}
+	return legend
+}
+
+func legendPrecision(breaks []float64) int {
+	if len(breaks) < 2 {
+		return 1
+	}
+	switch span := breaks[len(breaks)-1] - breaks[0]; {
+	case span >= 100:
+		return 0
+	case span >= 1:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// newLegend builds the legend panel drawn over the map, gathering the legends
+// of the visible layers.
+func newLegend(legends []*ui.Legend) fyne.CanvasObject {
+	rows := container.NewVBox()
+	for _, legend := range legends {
+		if legend.Title != "" {
+			rows.Add(widget.NewLabelWithStyle(legend.Title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		}
+		for _, e := range legend.Entries {
+			swatch := canvas.NewRectangle(e.Color)
+			swatch.SetMinSize(fyne.NewSize(18, 12))
+			swatch.StrokeColor = theme.Color(theme.ColorNameForeground)
+			swatch.StrokeWidth = 0.5
+			label := canvas.NewText(e.Label, theme.Color(theme.ColorNameForeground))
+			label.TextSize = theme.CaptionTextSize()
+			rows.Add(container.NewHBox(container.NewCenter(swatch), label))
+		}
+		if legend.Note != "" {
+			text := canvas.NewText(legend.Note, theme.Color(theme.ColorNameForeground))
+			text.TextSize = theme.CaptionTextSize()
+			text.TextStyle = fyne.TextStyle{Italic: true}
+			rows.Add(text)
+		}
+	}
+	background := canvas.NewRectangle(theme.Color(theme.ColorNameOverlayBackground))
+	background.CornerRadius = theme.InputRadiusSize()
+	return container.NewStack(background, container.NewPadded(rows))
+}
+
+func (h *HomeMap) showStyleForm() {
+	rampLabels := []string{autoRamp}
+	for _, r := range colorRamps {
+		rampLabels = append(rampLabels, r.label())
+	}
+	ramp := widget.NewSelect(rampLabels, nil)
+	ramp.SetSelectedIndex(0)
+	for i, r := range colorRamps {
+		if r.name == h.style.ramp {
+			ramp.SetSelectedIndex(i + 1)
+		}
+	}
+
+	methodLabels := make([]string, 0, len(data.ClassificationMethods))
+	for _, m := range data.ClassificationMethods {
+		methodLabels = append(methodLabels, m.String())
+	}
+	method := widget.NewSelect(methodLabels, nil)
+	method.SetSelectedIndex(slices.Index(data.ClassificationMethods, h.style.method))
+
+	classLabels := make([]string, 0, 7)
+	for i := 3; i <= 9; i++ {
+		classLabels = append(classLabels, strconv.Itoa(i))
+	}
+	classes := widget.NewSelect(classLabels, nil)
+	classes.SetSelected(strconv.Itoa(h.style.classes))
+
+	sized := widget.NewCheck("Taille proportionnelle à la classe", nil)
+	sized.SetChecked(h.style.sized)
+
+	dialog.ShowForm("Style des stations", "Appliquer", "Annuler", []*widget.FormItem{
+		widget.NewFormItem("Palette", ramp),
+		widget.NewFormItem("Classification", method),
+		widget.NewFormItem("Classes", classes),
+		widget.NewFormItem("", sized),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		style := choroplethStyle{
+			ramp:   autoRamp,
+			method: data.ClassificationMethods[method.SelectedIndex()],
+			sized:  sized.Checked,
+		}
+		if i := ramp.SelectedIndex(); i > 0 {
+			style.ramp = colorRamps[i-1].name
+		}
+		style.classes, _ = strconv.Atoi(classes.Selected)
+		h.style = style
+		h.classifyMetric()
+		h.needMapRefresh.Set(true)
+	}, h.w)
+}
+
+// classifyMetric computes the classes of the current metric with the current
+// style.
+func (h *HomeMap) classifyMetric() {
+	if h.metric == nil || h.metricResult == nil {
+		h.classes = nil
+		return
+	}
+	h.classes = classifyMetric(h.metric, h.metricResult, h.style)
+}
+
+// refreshLegend shows the legends of the visible layers, from the top to the
+// bottom one.
+func (h *HomeMap) refreshLegend() {
+	legends := make([]*ui.Legend, 0)
+	layers := h.iMap.Layers()
+	for i := len(layers) - 1; i >= 0; i-- {
+		if layers[i].Visible && layers[i].Legend != nil {
+			legends = append(legends, layers[i].Legend)
+		}
+	}
+	if len(legends) == 0 {
+		h.legend.Objects = nil
+	} else {
+		h.legend.Objects = []fyne.CanvasObject{newLegend(legends)}
+	}
+	h.legend.Refresh()
+}
@@ -0,0 +1,396 @@
+package home
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"meteo/common"
+	appcontext "meteo/context"
+	"meteo/data"
+	"slices"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/s-daehling/fyne-charts/pkg/coord"
+)
+
+var comparisonVariables = []data.Variable{data.Rain, data.MeanTemperature}
+
+type comparisonSeries struct {
+	variable data.Variable
+	period   data.Period
+}
+
+var seriesColors = []fyne.ThemeColorName{
+	theme.ColorNamePrimary,
+	theme.ColorNameError,
+	theme.ColorNameSuccess,
+	theme.ColorNameWarning,
+	theme.ColorNameHyperlink,
+	theme.ColorNameForeground,
+}
+
+type ComparisonComponent struct {
+	db        *sql.DB
+	logger    *slog.Logger
+	ctx       context.Context
+	cancel    context.CancelFunc
+	requests  *loader
+	stations  []*data.StationInfo
+	cache     map[string]map[comparisonSeries][]data.SeriesPoint
+	series    comparisonSeries
+	reference int
+	from, to  int
+
+	stationsBox  *fyne.Container
+	chart        *fyne.Container
+	diffChart    *fyne.Container
+	table        *widget.Table
+	selectRef    *widget.Select
+	selectFrom   *widget.Select
+	selectTo     *widget.Select
+	tableRows    []string
+	tableColumns []string
+	tableValues  [][]float64
+	content      fyne.CanvasObject
+	OnEmptied    func()
+}
+
+func InitComparisonComponent() *ComparisonComponent {
+	appContext := appcontext.GetAppContext()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ComparisonComponent{
+		db:       appContext.DB,
+		logger:   appContext.Logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		requests: newLoader(ctx),
+		cache:    make(map[string]map[comparisonSeries][]data.SeriesPoint),
+		series:   comparisonSeries{variable: data.Rain, period: data.CalendarYearPeriod},
+	}
+}
+
+func (c *ComparisonComponent) AddStation(station *data.StationInfo) {
+	for _, s := range c.stations {
+		if s.NumPost == station.NumPost {
+			return
+		}
+	}
+	c.stations = append(c.stations, station)
+	c.refresh()
+}
+
+// Close cancels the requests still running for the comparison.
+func (c *ComparisonComponent) Close() {
+	c.cancel()
+}
+
+func (c *ComparisonComponent) removeStation(index int) {
+	c.stations = slices.Delete(c.stations, index, index+1)
+	// the reference keeps its station, or falls back to the first one when it
+	// is the removed station
+	switch {
+	case index < c.reference:
+		c.reference--
+	case index == c.reference:
+		c.reference = 0
+	}
+	if len(c.stations) == 0 && c.OnEmptied != nil {
+		c.OnEmptied()
+		return
+	}
+	c.refresh()
+}
+
+func (c *ComparisonComponent) Render() fyne.CanvasObject {
+	if c.content != nil {
+		return c.content
+	}
+
+	c.stationsBox = container.NewVBox()
+	c.chart = container.NewStack()
+	c.diffChart = container.NewStack()
+
+	variableLabels := make([]string, 0, len(comparisonVariables))
+	for _, v := range comparisonVariables {
+		variableLabels = append(variableLabels, fmt.Sprintf("%s (%s)", v, v.Unit()))
+	}
+	selectSeries := widget.NewSelect(variableLabels, func(label string) {
+		c.series.variable = comparisonVariables[slices.Index(variableLabels, label)]
+		c.refresh()
+	})
+	selectPeriod := newPeriodSelect(data.Periods, c.series.period, func(p data.Period) {
+		c.series.period = p
+		c.refresh()
+	})
+	c.selectRef = widget.NewSelect(nil, func(string) {
+		if i := c.selectRef.SelectedIndex(); i >= 0 {
+			c.reference = i
+		}
+		c.refreshViews()
+	})
+	c.selectFrom = widget.NewSelect(nil, func(v string) {
+		c.from, _ = strconv.Atoi(v)
+		c.refreshViews()
+	})
+	c.selectTo = widget.NewSelect(nil, func(v string) {
+		c.to, _ = strconv.Atoi(v)
+		c.refreshViews()
+	})
+
+	c.table = widget.NewTable(
+		func() (int, int) { return len(c.tableRows) + 1, len(c.tableColumns) + 1 },
+		func() fyne.CanvasObject { return widget.NewLabel("0000000000") },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			switch {
+			case id.Row == 0 && id.Col == 0:
+				label.SetText("Période")
+			case id.Row == 0:
+				label.SetText(common.Truncate(c.tableColumns[id.Col-1], 12))
+			case id.Col == 0:
+				label.SetText(c.tableRows[id.Row-1])
+			default:
+				v := c.tableValues[id.Row-1][id.Col-1]
+				if math.IsNaN(v) {
+					label.SetText("-")
+				} else {
+					label.SetText(fmt.Sprintf("%.1f", v))
+				}
+			}
+		},
+	)
+
+	toolbar := container.NewVBox(
+		c.stationsBox,
+		container.NewGridWithColumns(2,
+			widget.NewLabel("Série"), selectSeries,
+			widget.NewLabel("Période"), selectPeriod,
+			widget.NewLabel("Référence"), c.selectRef,
+			widget.NewLabel("De"), c.selectFrom,
+			widget.NewLabel("À"), c.selectTo,
+		),
+	)
+
+	charts := container.NewAppTabs(
+		container.NewTabItem("Séries", c.chart),
+		container.NewTabItem("Écarts à la référence", c.diffChart),
+		container.NewTabItem("Tableau", c.table),
+	)
+
+	c.content = container.NewBorder(toolbar, nil, nil, nil, charts)
+	selectSeries.SetSelectedIndex(0)
+	return c.content
+}
+
+func (c *ComparisonComponent) refresh() {
+	if c.content == nil {
+		return
+	}
+
+	c.stationsBox.Objects = nil
+	for i, s := range c.stations {
+		c.stationsBox.Add(container.NewHBox(
+			widget.NewLabel(s.Label()),
+			widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { c.removeStation(i) }),
+		))
+	}
+	c.stationsBox.Refresh()
+
+	missing := make([]*data.StationInfo, 0, len(c.stations))
+	for _, s := range c.stations {
+		if _, ok := c.cache[s.NumPost][c.series]; !ok {
+			missing = append(missing, s)
+		}
+	}
+	series := c.series
+	load(c.requests, func(ctx context.Context) (map[string][]data.SeriesPoint, error) {
+		loaded := make(map[string][]data.SeriesPoint, len(missing))
+		for _, s := range missing {
+			points, err := data.GetPeriodSeries(ctx, c.db, s, series.variable, series.period)
+			if err != nil {
+				return loaded, fmt.Errorf("station %s: %w", s.NumPost, err)
+			}
+			loaded[s.NumPost] = points
+		}
+		return loaded, nil
+	}, func(loaded map[string][]data.SeriesPoint, err error) {
+		if err != nil {
+			c.logger.Error("Can't load comparison series", "error", err)
+		}
+		for numPost, points := range loaded {
+			if _, ok := c.cache[numPost]; !ok {
+				c.cache[numPost] = make(map[comparisonSeries][]data.SeriesPoint)
+			}
+			c.cache[numPost][series] = points
+		}
+		c.refreshSelectors()
+	})
+}
+
+func (c *ComparisonComponent) refreshSelectors() {
+	names := make([]string, 0, len(c.stations))
+	for _, s := range c.stations {
+		names = append(names, s.Label())
+	}
+
+	years := c.availableYears()
+	c.selectFrom.Options = years
+	c.selectTo.Options = years
+	if len(years) > 0 {
+		first, _ := strconv.Atoi(years[0])
+		last, _ := strconv.Atoi(years[len(years)-1])
+		if c.from < first || c.from > last {
+			c.from = first
+		}
+		if c.to < first || c.to > last || c.to < c.from {
+			c.to = last
+		}
+	}
+	c.selectFrom.Selected = strconv.Itoa(c.from)
+	c.selectTo.Selected = strconv.Itoa(c.to)
+	c.selectFrom.Refresh()
+	c.selectTo.Refresh()
+
+	c.selectRef.Options = names
+	if len(names) > 0 {
+		c.selectRef.Selected = names[c.reference]
+	}
+	c.selectRef.Refresh()
+
+	c.refreshViews()
+}
+
+func (c *ComparisonComponent) availableYears() []string {
+	years := make([]int, 0, 200)
+	for _, s := range c.stations {
+		for _, p := range c.cache[s.NumPost][c.series] {
+			if !slices.Contains(years, p.T.Year()) {
+				years = append(years, p.T.Year())
+			}
+		}
+	}
+	slices.Sort(years)
+	labels := make([]string, 0, len(years))
+	for _, y := range years {
+		labels = append(labels, strconv.Itoa(y))
+	}
+	return labels
+}
+
+func (c *ComparisonComponent) inPeriod(p data.SeriesPoint) bool {
+	return p.T.Year() >= c.from && p.T.Year() <= c.to
+}
+
+func (c *ComparisonComponent) filtered(station *data.StationInfo) []data.SeriesPoint {
+	points := make([]data.SeriesPoint, 0, 1000)
+	for _, p := range c.cache[station.NumPost][c.series] {
+		if c.inPeriod(p) {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+func (c *ComparisonComponent) refreshViews() {
+	if c.content == nil || len(c.stations) == 0 {
+		return
+	}
+	label := fmt.Sprintf("%s (%s) - %s", c.series.variable, c.series.variable.Unit(), c.series.period)
+
+	chart := coord.NewCartesianTemporalChart(label)
+	diffChart := coord.NewCartesianTemporalChart(fmt.Sprintf("Écart à %s", c.stations[c.reference].CommonName))
+	reference := make(map[int64]float64)
+	for _, p := range c.filtered(c.stations[c.reference]) {
+		reference[p.T.Unix()] = p.Value
+	}
+
+	for i, s := range c.stations {
+		points := c.filtered(s)
+		name := fmt.Sprintf("%d. %s", i+1, s.CommonName)
+		colorName := seriesColors[i%len(seriesColors)]
+
+		tps, err := coord.NewTemporalPointSeries(name, colorName, toTemporalPoints(points))
+		if err == nil {
+			err = chart.AddLineSeries(tps, false)
+		}
+		if err != nil {
+			c.logger.Error("Can't build comparison chart", "error", err, "station", s.NumPost)
+		}
+
+		if i == c.reference {
+			continue
+		}
+		diff := make([]data.SeriesPoint, 0, len(points))
+		for _, p := range points {
+			if ref, ok := reference[p.T.Unix()]; ok {
+				diff = append(diff, data.SeriesPoint{T: p.T, Value: p.Value - ref})
+			}
+		}
+		dps, err := coord.NewTemporalPointSeries(name, colorName, toTemporalPoints(diff))
+		if err == nil {
+			err = diffChart.AddLineSeries(dps, false)
+		}
+		if err != nil {
+			c.logger.Error("Can't build difference chart", "error", err, "station", s.NumPost)
+		}
+	}
+
+	c.chart.Objects = []fyne.CanvasObject{chart}
+	c.chart.Refresh()
+	c.diffChart.Objects = []fyne.CanvasObject{diffChart}
+	c.diffChart.Refresh()
+
+	c.refreshTable()
+}
+
+func (c *ComparisonComponent) refreshTable() {
+	byDate := make(map[int64][]float64)
+	dates := make([]int64, 0, 1000)
+	for i, s := range c.stations {
+		for _, p := range c.filtered(s) {
+			values, ok := byDate[p.T.Unix()]
+			if !ok {
+				values = make([]float64, len(c.stations))
+				for j := range values {
+					values[j] = math.NaN()
+				}
+				byDate[p.T.Unix()] = values
+				dates = append(dates, p.T.Unix())
+			}
+			values[i] = p.Value
+		}
+	}
+	slices.Sort(dates)
+
+	c.tableColumns = make([]string, 0, len(c.stations)*2)
+	for _, s := range c.stations {
+		c.tableColumns = append(c.tableColumns, s.CommonName)
+	}
+	for i, s := range c.stations {
+		if i != c.reference {
+			c.tableColumns = append(c.tableColumns, "Δ "+s.CommonName)
+		}
+	}
+
+	c.tableRows = make([]string, 0, len(dates))
+	c.tableValues = make([][]float64, 0, len(dates))
+	for _, date := range dates {
+		values := byDate[date]
+		row := slices.Clone(values)
+		for i := range c.stations {
+			if i != c.reference {
+				row = append(row, values[i]-values[c.reference])
+			}
+		}
+		c.tableRows = append(c.tableRows, c.series.period.Label(time.Unix(date, 0).UTC()))
+		c.tableValues = append(c.tableValues, row)
+	}
+	c.table.Refresh()
+}
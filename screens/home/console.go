@@ -0,0 +1,312 @@
+package home
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	appcontext "meteo/context"
+	"meteo/data"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	consolePageSize   = 100
+	consoleHistoryMax = 50
+	defaultQuery      = "SELECT NUM_POSTE, NOM_USUEL, count(*) AS JOURS\nFROM read_parquet('data/parquet/*.parquet')\nGROUP BY ALL\nORDER BY JOURS DESC"
+)
+
+type ConsoleComponent struct {
+	w      fyne.Window
+	db     *sql.DB
+	logger *slog.Logger
+
+	result       *data.QueryResult
+	page         int
+	history      []string
+	cancel       context.CancelFunc
+	cancelExport context.CancelFunc
+
+	editor       *widget.Entry
+	table        *widget.Table
+	historyList  *widget.List
+	status       *widget.Label
+	pageLabel    *widget.Label
+	runButton    *widget.Button
+	cancelButton *widget.Button
+	exportButton *widget.Button
+	previous     *widget.Button
+	next         *widget.Button
+	content      fyne.CanvasObject
+}
+
+func InitConsoleComponent() *ConsoleComponent {
+	appContext := appcontext.GetAppContext()
+	return &ConsoleComponent{
+		w:       appContext.W,
+		db:      appContext.DB,
+		logger:  appContext.Logger,
+		history: make([]string, 0, consoleHistoryMax),
+	}
+}
+
+func (c *ConsoleComponent) Render() fyne.CanvasObject {
+	if c.content != nil {
+		return c.content
+	}
+
+	c.editor = widget.NewMultiLineEntry()
+	c.editor.TextStyle = fyne.TextStyle{Monospace: true}
+	c.editor.SetText(defaultQuery)
+	c.editor.SetMinRowsVisible(6)
+
+	c.runButton = widget.NewButtonWithIcon("Exécuter", theme.MediaPlayIcon(), c.run)
+	c.runButton.Importance = widget.HighImportance
+	c.cancelButton = widget.NewButtonWithIcon("Annuler", theme.MediaStopIcon(), func() {
+		if c.cancel != nil {
+			c.cancel()
+		}
+	})
+	c.cancelButton.Disable()
+	c.exportButton = widget.NewButtonWithIcon("Exporter en CSV", theme.DocumentSaveIcon(), c.export)
+	c.exportButton.Disable()
+	c.status = widget.NewLabel("")
+
+	c.table = widget.NewTable(
+		func() (int, int) {
+			if c.result == nil {
+				return 0, 0
+			}
+			return len(c.pageRows()), len(c.result.Columns)
+		},
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			rows := c.pageRows()
+			if id.Row < len(rows) && id.Col < len(rows[id.Row]) {
+				o.(*widget.Label).SetText(rows[id.Row][id.Col])
+			}
+		},
+	)
+	c.table.ShowHeaderRow = true
+	c.table.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	}
+	c.table.UpdateHeader = func(id widget.TableCellID, o fyne.CanvasObject) {
+		if c.result != nil && id.Col >= 0 && id.Col < len(c.result.Columns) {
+			o.(*widget.Label).SetText(c.result.Columns[id.Col])
+		}
+	}
+
+	c.previous = widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() { c.showPage(c.page - 1) })
+	c.next = widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() { c.showPage(c.page + 1) })
+	c.pageLabel = widget.NewLabel("")
+	c.previous.Disable()
+	c.next.Disable()
+
+	c.historyList = widget.NewList(
+		func() int { return len(c.history) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(historyLabel(c.history[id]))
+		},
+	)
+	c.historyList.OnSelected = func(id widget.ListItemID) {
+		c.editor.SetText(c.history[id])
+		c.historyList.UnselectAll()
+	}
+
+	toolbar := container.NewHBox(c.runButton, c.cancelButton, c.exportButton, c.status)
+	pagination := container.NewHBox(c.previous, c.pageLabel, c.next)
+	results := container.NewBorder(nil, pagination, nil, nil, c.table)
+
+	editor := container.NewBorder(nil, toolbar, nil, nil, c.editor)
+	history := container.NewBorder(widget.NewLabel("Historique"), nil, nil, nil, c.historyList)
+
+	main := container.NewVSplit(editor, results)
+	main.Offset = 0.3
+	split := container.NewHSplit(main, history)
+	split.Offset = 0.8
+
+	c.content = split
+	return c.content
+}
+
+// Close cancels the running query and export, if any.
+func (c *ConsoleComponent) Close() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if c.cancelExport != nil {
+		c.cancelExport()
+	}
+}
+
+func (c *ConsoleComponent) run() {
+	query := strings.TrimSpace(c.editor.Text)
+	if query == "" || c.cancel != nil {
+		return
+	}
+	c.addToHistory(query)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.runButton.Disable()
+	c.cancelButton.Enable()
+	c.status.SetText("Exécution en cours...")
+
+	go func() {
+		result, err := data.RunQuery(ctx, c.db, query)
+		fyne.Do(func() {
+			cancelled := ctx.Err() != nil
+			cancel()
+			c.cancel = nil
+			c.runButton.Enable()
+			c.cancelButton.Disable()
+
+			if err != nil {
+				if cancelled {
+					c.status.SetText("Requête annulée")
+					return
+				}
+				c.logger.Error("SQL console query failed", "error", err)
+				c.status.SetText("Erreur")
+				dialog.ShowError(err, c.w)
+				return
+			}
+
+			c.result = result
+			c.exportButton.Enable()
+			status := fmt.Sprintf("%d lignes en %s", len(result.Rows), result.Duration.Round(time.Millisecond))
+			if result.Truncated {
+				status = fmt.Sprintf("%s (limité à %d lignes)", status, data.MaxQueryRows)
+			}
+			c.status.SetText(status)
+			for i := range result.Columns {
+				c.table.SetColumnWidth(i, 150)
+			}
+			c.showPage(0)
+		})
+	}()
+}
+
+func (c *ConsoleComponent) pageRows() [][]string {
+	if c.result == nil {
+		return nil
+	}
+	start := min(c.page*consolePageSize, len(c.result.Rows))
+	end := min(start+consolePageSize, len(c.result.Rows))
+	return c.result.Rows[start:end]
+}
+
+func (c *ConsoleComponent) pageCount() int {
+	if c.result == nil || len(c.result.Rows) == 0 {
+		return 1
+	}
+	return (len(c.result.Rows) + consolePageSize - 1) / consolePageSize
+}
+
+func (c *ConsoleComponent) showPage(page int) {
+	c.page = max(0, min(page, c.pageCount()-1))
+	c.pageLabel.SetText(fmt.Sprintf("Page %d / %d", c.page+1, c.pageCount()))
+	if c.page > 0 {
+		c.previous.Enable()
+	} else {
+		c.previous.Disable()
+	}
+	if c.page < c.pageCount()-1 {
+		c.next.Enable()
+	} else {
+		c.next.Disable()
+	}
+	c.table.ScrollToTop()
+	c.table.Refresh()
+}
+
+func (c *ConsoleComponent) addToHistory(query string) {
+	for i, q := range c.history {
+		if q == query {
+			c.history = append(c.history[:i], c.history[i+1:]...)
+			break
+		}
+	}
+	c.history = append([]string{query}, c.history...)
+	if len(c.history) > consoleHistoryMax {
+		c.history = c.history[:consoleHistoryMax]
+	}
+	c.historyList.Refresh()
+}
+
+// export writes the rows of the last result, as displayed, to a CSV file.
+func (c *ConsoleComponent) export() {
+	if c.result == nil || c.cancelExport != nil {
+		return
+	}
+	result := c.result
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, c.w)
+			return
+		}
+		if writer == nil {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		c.cancelExport = cancel
+		c.exportButton.Disable()
+		progress := dialog.NewCustom(
+			"Export en cours",
+			"Annuler",
+			container.NewVBox(
+				widget.NewLabel(fmt.Sprintf("Export vers %s...", writer.URI().Name())),
+				widget.NewProgressBarInfinite(),
+			),
+			c.w,
+		)
+		progress.SetOnClosed(cancel)
+		progress.Show()
+
+		go func() {
+			count, err := data.WriteQueryCSV(ctx, result, writer)
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+			fyne.Do(func() {
+				cancelled := ctx.Err() != nil
+				cancel()
+				c.cancelExport = nil
+				c.exportButton.Enable()
+				progress.SetOnClosed(nil)
+				progress.Hide()
+				if cancelled {
+					return
+				}
+				if err != nil {
+					c.logger.Error("SQL console export failed", "error", err)
+					dialog.ShowError(err, c.w)
+					return
+				}
+				dialog.ShowInformation("Export terminé", fmt.Sprintf("%d lignes exportées dans %s", count, writer.URI().Name()), c.w)
+			})
+		}()
+	}, c.w)
+	save.SetFileName("requete.csv")
+	save.SetFilter(storage.NewExtensionFileFilter([]string{".csv"}))
+	save.Show()
+}
+
+func historyLabel(query string) string {
+	line := strings.Join(strings.Fields(query), " ")
+	if len([]rune(line)) > 60 {
+		return string([]rune(line)[:60]) + "…"
+	}
+	return line
+}
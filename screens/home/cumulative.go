@@ -0,0 +1,132 @@
+package home
+
+import (
+	"context"
+	"fmt"
+	"meteo/data"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/s-daehling/fyne-charts/pkg/coord"
+)
+
+func (c *StationDetailsComponent) renderCumulative(station *data.StationInfo) fyne.CanvasObject {
+	content := container.NewStack(widget.NewProgressBarInfinite())
+	load(newLoader(c.ctx), func(ctx context.Context) ([]data.SeriesPoint, error) {
+		return data.GetDailyRain(ctx, c.db, station)
+	}, func(daily []data.SeriesPoint, err error) {
+		content.Objects = []fyne.CanvasObject{c.renderEnvelope(station, daily, err)}
+		content.Refresh()
+	})
+	return content
+}
+
+func (c *StationDetailsComponent) renderEnvelope(station *data.StationInfo, daily []data.SeriesPoint, err error) fyne.CanvasObject {
+	if err != nil {
+		c.logger.Error("Can't fetch daily rain", "error", err, "station", station.NumPost)
+		return widget.NewLabel("Impossible de charger les cumuls quotidiens")
+	}
+	if len(daily) == 0 {
+		return widget.NewLabel("Aucune mesure de précipitations pour cette station")
+	}
+
+	period := data.CalendarYearPeriod
+	var starts []time.Time
+
+	summary := widget.NewLabel("")
+	chartContainer := container.NewStack()
+
+	selectYear := widget.NewSelect(nil, func(label string) {
+		for _, start := range starts {
+			if period.Label(start) != label {
+				continue
+			}
+			envelope, err := data.ComputeRainEnvelope(daily, start, period)
+			if err != nil {
+				c.logger.Error("Can't compute rain envelope", "error", err, "station", station.NumPost, "year", label)
+				return
+			}
+
+			chart, err := newEnvelopeChart(envelope, period)
+			if err != nil {
+				c.logger.Error("Can't build chart", "error", err, "year", label)
+				return
+			}
+			chartContainer.Objects = []fyne.CanvasObject{chart}
+			chartContainer.Refresh()
+			summary.SetText(envelopeSummary(envelope))
+		}
+	})
+
+	loadYears := func() {
+		starts = data.RainYears(daily, period)
+		labels := make([]string, 0, len(starts))
+		for i := len(starts) - 1; i >= 0; i-- {
+			labels = append(labels, period.Label(starts[i]))
+		}
+		selectYear.Options = labels
+		selectYear.SetSelectedIndex(0)
+	}
+	loadYears()
+
+	selectPeriod := newPeriodSelect(yearlyPeriods, period, func(p data.Period) {
+		period = p
+		loadYears()
+	})
+
+	return container.NewBorder(
+		container.NewVBox(selectPeriod, selectYear, summary),
+		nil, nil, nil,
+		chartContainer,
+	)
+}
+
+func newEnvelopeChart(envelope *data.RainEnvelope, period data.Period) (*coord.CartesianTemporalChart, error) {
+	chart := coord.NewCartesianTemporalChart(fmt.Sprintf("Cumul de pluie %s", period.Label(envelope.Start)))
+	chart.SetTAxisLabel("Date")
+	chart.SetYAxisLabel("mm")
+
+	lines := []struct {
+		name   string
+		color  fyne.ThemeColorName
+		points []data.SeriesPoint
+	}{
+		{"Minimum", theme.ColorNameForeground, envelope.Min},
+		{"1er décile", theme.ColorNameWarning, envelope.Deciles[0]},
+		{"Médiane", theme.ColorNameSuccess, envelope.Median},
+		{"9e décile", theme.ColorNameHyperlink, envelope.Deciles[8]},
+		{"Maximum", theme.ColorNameForeground, envelope.Max},
+		{period.Label(envelope.Start), theme.ColorNamePrimary, envelope.Current},
+	}
+	for _, l := range lines {
+		if len(l.points) == 0 {
+			continue
+		}
+		tps, err := coord.NewTemporalPointSeries(l.name, l.color, toTemporalPoints(l.points))
+		if err != nil {
+			return nil, err
+		}
+		if err := chart.AddLineSeries(tps, false); err != nil {
+			return nil, err
+		}
+	}
+	return chart, nil
+}
+
+func envelopeSummary(envelope *data.RainEnvelope) string {
+	if len(envelope.Current) == 0 {
+		return "Aucune mesure pour cette année"
+	}
+	last := envelope.Current[len(envelope.Current)-1]
+	if envelope.PastYears == 0 {
+		return fmt.Sprintf("Au %s : %.1f mm (aucune année antérieure complète pour comparer)",
+			last.T.Format("02/01/2006"), last.Value)
+	}
+	median := envelope.Median[len(envelope.Current)-1].Value
+	return fmt.Sprintf("Au %s : %.1f mm, médiane %.1f mm (%+.0f %%), décile %d sur %d années",
+		last.T.Format("02/01/2006"), last.Value, median, (last.Value/median-1)*100,
+		envelope.Decile(), envelope.PastYears)
+}
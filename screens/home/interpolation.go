@@ -0,0 +1,205 @@
+package home
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"meteo/components/ui"
+	"meteo/data"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/fogleman/gg"
+)
+
+// gridOpacity is the opacity the grid layer starts with
+const gridOpacity = 0.7
+
+func (h *HomeMap) showInterpolationForm() {
+	if h.metric == nil || h.metricResult == nil {
+		dialog.ShowError(errors.New("choisissez d'abord un indicateur à interpoler"), h.w)
+		return
+	}
+
+	defaults := h.interpolationOptions
+
+	methods := []data.InterpolationMethod{data.InverseDistanceWeighting, data.OrdinaryKriging}
+	methodLabels := make([]string, 0, len(methods))
+	for _, m := range methods {
+		methodLabels = append(methodLabels, m.String())
+	}
+	method := widget.NewSelect(methodLabels, nil)
+	method.SetSelectedIndex(int(defaults.Method))
+
+	models := []data.VariogramModel{data.SphericalVariogram, data.ExponentialVariogram, data.GaussianVariogram}
+	modelLabels := make([]string, 0, len(models))
+	for _, m := range models {
+		modelLabels = append(modelLabels, m.String())
+	}
+	model := widget.NewSelect(modelLabels, nil)
+	model.SetSelectedIndex(int(defaults.Variogram))
+
+	power := widget.NewEntry()
+	power.SetText(strconv.FormatFloat(defaults.Power, 'f', -1, 64))
+	radius := widget.NewEntry()
+	radius.SetText(strconv.FormatFloat(defaults.RadiusKm, 'f', -1, 64))
+	neighbours := widget.NewEntry()
+	neighbours.SetText(strconv.Itoa(defaults.MaxNeighbours))
+	resolution := widget.NewEntry()
+	resolution.SetText(strconv.Itoa(defaults.Resolution))
+
+	show := widget.NewCheck("Afficher la grille", nil)
+	show.SetChecked(true)
+
+	dialog.ShowForm("Interpolation", "Calculer", "Annuler", []*widget.FormItem{
+		widget.NewFormItem("Méthode", method),
+		widget.NewFormItem("Puissance (IDW)", power),
+		widget.NewFormItem("Rayon (km, 0 = illimité)", radius),
+		widget.NewFormItem("Voisins max", neighbours),
+		widget.NewFormItem("Variogramme (krigeage)", model),
+		widget.NewFormItem("Résolution (cellules)", resolution),
+		widget.NewFormItem("", show),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		if !show.Checked {
+			h.grid = nil
+			h.needMapRefresh.Set(true)
+			return
+		}
+
+		opts := data.InterpolationOptions{
+			Method:    methods[method.SelectedIndex()],
+			Variogram: models[model.SelectedIndex()],
+		}
+		var err error
+		if opts.Power, err = strconv.ParseFloat(power.Text, 64); err != nil {
+			dialog.ShowError(fmt.Errorf("puissance invalide : %s", power.Text), h.w)
+			return
+		}
+		if opts.RadiusKm, err = strconv.ParseFloat(radius.Text, 64); err != nil {
+			dialog.ShowError(fmt.Errorf("rayon invalide : %s", radius.Text), h.w)
+			return
+		}
+		if opts.MaxNeighbours, err = strconv.Atoi(neighbours.Text); err != nil {
+			dialog.ShowError(fmt.Errorf("nombre de voisins invalide : %s", neighbours.Text), h.w)
+			return
+		}
+		if opts.Resolution, err = strconv.Atoi(resolution.Text); err != nil {
+			dialog.ShowError(fmt.Errorf("résolution invalide : %s", resolution.Text), h.w)
+			return
+		}
+		h.interpolationOptions = opts
+		h.interpolate(opts)
+	}, h.w)
+}
+
+func (h *HomeMap) interpolate(opts data.InterpolationOptions) {
+	samples := make([]data.SamplePoint, 0, len(h.stations))
+	for _, station := range h.stations {
+		if v, ok := h.metricResult.values[station.NumPost]; ok {
+			samples = append(samples, data.SamplePoint{Lon: station.Lon, Lat: station.Lat, Value: v})
+		}
+	}
+
+	if h.interpolationProgress != nil {
+		h.interpolationProgress.SetOnClosed(nil)
+		h.interpolationProgress.Hide()
+	}
+	progress := dialog.NewCustom(
+		"Calcul en cours",
+		"Annuler",
+		container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("Interpolation (%s)...", opts.Method)),
+			widget.NewProgressBarInfinite(),
+		),
+		h.w,
+	)
+	progress.SetOnClosed(h.interpolationRequests.Cancel)
+	progress.Show()
+	h.interpolationProgress = progress
+
+	bounds := *h.geoData.Bounds
+	load(h.interpolationRequests, func(ctx context.Context) (*data.Grid, error) {
+		return data.Interpolate(ctx, samples, bounds, h.geoData, opts)
+	}, func(grid *data.Grid, err error) {
+		progress.SetOnClosed(nil)
+		progress.Hide()
+		h.interpolationProgress = nil
+		if err != nil {
+			h.logger.Error("Can't interpolate metric", "error", err, "metric", h.metric.name)
+			dialog.ShowError(err, h.w)
+			return
+		}
+		h.grid = grid
+		h.needMapRefresh.Set(true)
+		if grid.Variogram != nil {
+			h.logger.Info("Variogram fitted", "model", grid.Variogram.Model.String(),
+				"nugget", grid.Variogram.Nugget, "sill", grid.Variogram.Sill, "range", grid.Variogram.Range)
+		}
+	})
+}
+
+func (h *HomeMap) refreshGridLayer() {
+	if h.grid == nil || h.classes == nil {
+		h.iMap.RemoveLayer(gridLayerID)
+		return
+	}
+	_, shown := h.iMap.Layer(gridLayerID)
+	h.iMap.SetLayerBelow(gridLayerID, "Grille interpolée", &gridLayer{grid: h.grid, classes: h.classes}, stationsLayerID)
+	if !shown {
+		h.iMap.SetLayerOpacity(gridLayerID, gridOpacity)
+	}
+}
+
+// gridLayer draws the cells of an interpolated grid. The grid is a surface
+// rather than a set of features: its value is added to the tooltips by the
+// map instead.
+type gridLayer struct {
+	grid    *data.Grid
+	classes *metricClasses
+}
+
+var _ ui.Layer = (*gridLayer)(nil)
+
+func (l *gridLayer) Draw(dc *gg.Context, v ui.Viewport) {
+	grid := l.grid
+	cellLon, cellLat := grid.CellSize()
+
+	for row := range grid.Rows {
+		lat := grid.Bounds.MaxLat - float64(row)*cellLat
+		for col := range grid.Cols {
+			value := grid.At(col, row)
+			if math.IsNaN(value) {
+				continue
+			}
+			lon := grid.Bounds.MinLong + float64(col)*cellLon
+			// cells are no rectangles once projected
+			dc.MoveTo(v.Project(lon, lat))
+			dc.LineTo(v.Project(lon+cellLon, lat))
+			dc.LineTo(v.Project(lon+cellLon, lat-cellLat))
+			dc.LineTo(v.Project(lon, lat-cellLat))
+			dc.ClosePath()
+
+			// cells are opaque, the layer opacity letting the map show
+			// through; the stroke closes the seams between neighbouring cells
+			dc.SetColor(l.classes.color(value))
+			dc.SetLineWidth(0.5)
+			dc.FillPreserve()
+			dc.Stroke()
+		}
+	}
+}
+
+func (l *gridLayer) HitTest(ui.Viewport, fyne.Position) (int, bool) {
+	return 0, false
+}
+
+func (l *gridLayer) Tooltip(int) string {
+	return ""
+}
@@ -0,0 +1,567 @@
+package home
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"meteo/common"
+	"meteo/components/ui"
+	"meteo/data"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/fogleman/gg"
+)
+
+// Identifiers of the layers of the map, the layers added by the user being
+// identified by their rank.
+const (
+	departmentsLayerID = "departments"
+	outlineLayerID     = "outline"
+	gridLayerID        = "grid"
+	stationsLayerID    = "stations"
+)
+
+// layerColors are given in turn to the layers added by the user.
+var layerColors = hexColors("1f78b4", "e31a1c", "33a02c", "ff7f00", "6a3d9a", "b15928")
+
+// geoStyle is how the features of a GeoJSON layer are drawn.
+type geoStyle struct {
+	stroke    color.NRGBA
+	lineWidth float64
+	fill      color.NRGBA
+	// fillOpacity goes from 0 (polygons not filled) to 1
+	fillOpacity float64
+	pointRadius float64
+}
+
+func (s geoStyle) fillColor() color.NRGBA {
+	fill := s.fill
+	fill.A = uint8(float64(fill.A) * s.fillOpacity)
+	return fill
+}
+
+// geoLayer is a GeoJSON file drawn over the map, such as rivers, watersheds
+// or custom zones.
+type geoLayer struct {
+	id         string
+	name       string
+	collection *data.FeatureCollection
+	style      geoStyle
+}
+
+func defaultGeoStyle(i int) geoStyle {
+	c := layerColors[i%len(layerColors)]
+	return geoStyle{
+		stroke:      c,
+		lineWidth:   1.5,
+		fill:        c,
+		fillOpacity: 0.25,
+		pointRadius: 3,
+	}
+}
+
+// drawGeometry draws any geometry, project turning longitudes and latitudes
+// into pixels.
+func drawGeometry(dc *gg.Context, g *data.Geometry, style geoStyle, project func(lon, lat float64) (float64, float64)) {
+	path := func(line []data.Coordinate, closed bool) {
+		for k, c := range line {
+			if len(c) < 2 {
+				continue
+			}
+			x, y := project(c[0], c[1])
+			if k == 0 {
+				dc.MoveTo(x, y)
+			} else {
+				dc.LineTo(x, y)
+			}
+		}
+		if closed {
+			dc.ClosePath()
+		} else {
+			// starts the next line on its own
+			dc.NewSubPath()
+		}
+	}
+
+	dc.SetLineWidth(style.lineWidth)
+	for _, polygon := range g.Polygons() {
+		for _, ring := range polygon {
+			path(ring, true)
+		}
+		if style.fillOpacity > 0 {
+			dc.SetFillRuleEvenOdd()
+			dc.SetColor(style.fillColor())
+			dc.FillPreserve()
+		}
+		dc.SetColor(style.stroke)
+		dc.Stroke()
+	}
+
+	if lines := g.Lines(); len(lines) > 0 {
+		for _, line := range lines {
+			path(line, false)
+		}
+		dc.SetColor(style.stroke)
+		dc.Stroke()
+	}
+
+	dc.SetColor(style.stroke)
+	for _, p := range g.Points() {
+		if len(p) < 2 {
+			continue
+		}
+		x, y := project(p[0], p[1])
+		dc.DrawCircle(x, y, style.pointRadius)
+		dc.Fill()
+	}
+}
+
+// hitGeometry tells whether the geometry is drawn at the position: inside
+// filled polygons, or close to lines, polygon boundaries and points. Positions
+// are known to have two coordinates, checked when the GeoJSON was parsed.
+func hitGeometry(g *data.Geometry, style geoStyle, v ui.Viewport, pos fyne.Position) bool {
+	reach := style.lineWidth/2 + ui.HitTolerance
+	nearLine := func(line []data.Coordinate, closed bool) bool {
+		for k := 1; k < len(line); k++ {
+			x0, y0 := v.Project(line[k-1][0], line[k-1][1])
+			x1, y1 := v.Project(line[k][0], line[k][1])
+			if ui.SegmentDistance(pos, x0, y0, x1, y1) <= reach {
+				return true
+			}
+		}
+		if closed && len(line) > 1 {
+			x0, y0 := v.Project(line[len(line)-1][0], line[len(line)-1][1])
+			x1, y1 := v.Project(line[0][0], line[0][1])
+			return ui.SegmentDistance(pos, x0, y0, x1, y1) <= reach
+		}
+		return false
+	}
+
+	if style.fillOpacity > 0 {
+		lon, lat := v.Unproject(float64(pos.X), float64(pos.Y))
+		if g.Contains(lon, lat) {
+			return true
+		}
+	}
+	for _, polygon := range g.Polygons() {
+		for _, ring := range polygon {
+			if nearLine(ring, true) {
+				return true
+			}
+		}
+	}
+	for _, line := range g.Lines() {
+		if nearLine(line, false) {
+			return true
+		}
+	}
+	for _, p := range g.Points() {
+		x, y := v.Project(p[0], p[1])
+		if ui.PointDistance(pos, x, y) <= style.pointRadius+ui.HitTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// featureLayer draws the features of a GeoJSON collection with a single
+// style; it backs the outline of France and the layers added by the user.
+type featureLayer struct {
+	name       string
+	collection *data.FeatureCollection
+	style      geoStyle
+}
+
+var _ ui.Layer = (*featureLayer)(nil)
+
+func (l *featureLayer) Draw(dc *gg.Context, v ui.Viewport) {
+	for _, feature := range l.collection.Features {
+		if feature.Geometry != nil {
+			drawGeometry(dc, feature.Geometry, l.style, v.Project)
+		}
+	}
+}
+
+func (l *featureLayer) HitTest(v ui.Viewport, pos fyne.Position) (int, bool) {
+	margin := max(l.style.lineWidth/2, l.style.pointRadius) + ui.HitTolerance
+	for i := len(l.collection.Features) - 1; i >= 0; i-- {
+		g := l.collection.Features[i].Geometry
+		if g == nil {
+			continue
+		}
+		if bounds := g.Bounds(); !bounds.IsEmpty() && !boundsNear(bounds, v, pos, margin) {
+			continue
+		}
+		if hitGeometry(g, l.style, v, pos) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// boundsNear quickly discards the features far from the position.
+func boundsNear(b data.Bounds, v ui.Viewport, pos fyne.Position, margin float64) bool {
+	x0, y0 := v.Project(b.MinLong, b.MaxLat)
+	x1, y1 := v.Project(b.MaxLong, b.MinLat)
+	x, y := float64(pos.X), float64(pos.Y)
+	return x >= min(x0, x1)-margin && x <= max(x0, x1)+margin &&
+		y >= min(y0, y1)-margin && y <= max(y0, y1)+margin
+}
+
+func (l *featureLayer) Tooltip(feature int) string {
+	if name := l.collection.Features[feature].Name(); name != "" {
+		return fmt.Sprintf("%s (%s)", name, l.name)
+	}
+	return l.name
+}
+
+func (layer *geoLayer) legend() *ui.Legend {
+	swatch := layer.style.stroke
+	if layer.style.fillOpacity > 0 {
+		swatch = layer.style.fillColor()
+	}
+	return &ui.Legend{Entries: []ui.LegendEntry{{Color: swatch, Label: layer.name}}}
+}
+
+// screenGridStep is the spacing in pixels of the points of a screenGrid.
+const screenGridStep = 16
+
+// screenGrid gives the position of every pixel of the map in a raster, such
+// as tiles or an elevation model. Positions are computed exactly on a grid of
+// pixels only, and linearly interpolated in between, which is fast and close
+// enough as projections barely bend over a few pixels.
+type screenGrid struct {
+	cols   int
+	xs, ys []float64
+}
+
+func newScreenGrid(w, h int, v ui.Viewport, position func(lon, lat float64) (float64, float64)) *screenGrid {
+	g := &screenGrid{cols: w/screenGridStep + 2}
+	rows := h/screenGridStep + 2
+	g.xs, g.ys = make([]float64, g.cols*rows), make([]float64, g.cols*rows)
+	for j := range rows {
+		for i := range g.cols {
+			lon, lat := v.Unproject(float64(i*screenGridStep), float64(j*screenGridStep))
+			g.xs[j*g.cols+i], g.ys[j*g.cols+i] = position(lon, lat)
+		}
+	}
+	return g
+}
+
+// at is the position of the pixel (x, y) of the map.
+func (g *screenGrid) at(x, y int) (float64, float64) {
+	i, fx := x/screenGridStep, float64(x%screenGridStep)/screenGridStep
+	j, fy := y/screenGridStep, float64(y%screenGridStep)/screenGridStep
+	k := j*g.cols + i
+	bilinear := func(v []float64) float64 {
+		top := v[k] + (v[k+1]-v[k])*fx
+		bottom := v[k+g.cols] + (v[k+g.cols+1]-v[k+g.cols])*fx
+		return top + (bottom-top)*fy
+	}
+	return bilinear(g.xs), bilinear(g.ys)
+}
+
+// viewport is the part of the map shown by the camera.
+func (h *HomeMap) viewport() ui.Viewport {
+	return ui.Viewport{Camera: h.camera, Frame: h.frame}
+}
+
+// refreshViewport draws the map again after the camera moved.
+func (h *HomeMap) refreshViewport() {
+	h.iMap.SetViewport(h.viewport())
+}
+
+// refreshLayers draws every layer again after the stations, the metric or a
+// style changed. New user layers are put below the interpolated grid and the
+// stations.
+func (h *HomeMap) refreshLayers() {
+	loaded := h.loadedDepartments.Load()
+	departments := h.iMap.SetLayer(departmentsLayerID, "Départements", &departmentLayer{shapes: h.departments, loaded: loaded})
+	departments.Legend = nil
+	if len(h.departments) > 0 {
+		departments.Legend = &ui.Legend{Entries: []ui.LegendEntry{{Color: loadedDepartmentColor, Label: "Département importé"}}}
+	}
+	h.iMap.SetLayer(outlineLayerID, "Contour de la France", &featureLayer{
+		name:       "Contour de la France",
+		collection: h.geoData.Outline,
+		style:      geoStyle{stroke: color.NRGBA{R: 255, G: 255, B: 255, A: 255}, lineWidth: 2},
+	})
+	for _, layer := range h.geoLayers {
+		below := stationsLayerID
+		if _, ok := h.iMap.Layer(gridLayerID); ok {
+			below = gridLayerID
+		}
+		mapLayer := h.iMap.SetLayerBelow(layer.id, layer.name, &featureLayer{
+			name:       layer.name,
+			collection: layer.collection,
+			style:      layer.style,
+		}, below)
+		mapLayer.Legend = layer.legend()
+	}
+	h.refreshGridLayer()
+	h.AddStationsLayer(h.stations)
+	h.refreshLegend()
+	h.refreshLayerPanel()
+}
+
+func (h *HomeMap) removeGeoLayer(layer *geoLayer) {
+	h.geoLayers = slices.DeleteFunc(h.geoLayers, func(l *geoLayer) bool { return l == layer })
+	h.iMap.RemoveLayer(layer.id)
+}
+
+// departmentLayer shades the departments whose stations are loaded and draws
+// the departments boundaries.
+type departmentLayer struct {
+	shapes []data.DepartmentShape
+	loaded *map[string]bool
+}
+
+var _ ui.Layer = (*departmentLayer)(nil)
+
+func (l *departmentLayer) isLoaded(i int) bool {
+	return l.loaded != nil && (*l.loaded)[l.shapes[i].StationDepartment()]
+}
+
+func (l *departmentLayer) Draw(dc *gg.Context, v ui.Viewport) {
+	for i, department := range l.shapes {
+		for _, polygon := range department.Polygons {
+			for _, ring := range polygon {
+				for k, c := range ring {
+					x, y := v.Project(c[0], c[1])
+					if k == 0 {
+						dc.MoveTo(x, y)
+					} else {
+						dc.LineTo(x, y)
+					}
+				}
+				dc.ClosePath()
+			}
+		}
+		if l.isLoaded(i) {
+			dc.SetFillRuleEvenOdd()
+			dc.SetColor(loadedDepartmentColor)
+			dc.FillPreserve()
+		}
+		dc.SetColor(departmentBoundaryColor)
+		dc.SetLineWidth(0.5)
+		dc.Stroke()
+	}
+}
+
+func (l *departmentLayer) HitTest(v ui.Viewport, pos fyne.Position) (int, bool) {
+	lon, lat := v.Unproject(float64(pos.X), float64(pos.Y))
+	for i := range l.shapes {
+		if l.shapes[i].Contains(lon, lat) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (l *departmentLayer) Tooltip(feature int) string {
+	department := l.shapes[feature]
+	text := departmentLabel(data.Department{Code: department.Code, Name: department.Name})
+	if !l.isLoaded(feature) {
+		text += " (cliquer pour importer)"
+	}
+	return text
+}
+
+func (h *HomeMap) toggleLayerPanel() {
+	if h.layerPanel.Visible() {
+		h.layerPanel.Hide()
+		return
+	}
+	h.refreshLayerPanel()
+	h.layerPanel.Show()
+}
+
+// refreshLayerPanel lists the layers from the top to the bottom one, with
+// their visibility, opacity and order.
+func (h *HomeMap) refreshLayerPanel() {
+	if !h.layerPanel.Visible() {
+		return
+	}
+	rows := container.NewVBox(widget.NewLabelWithStyle("Couches", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	layers := h.iMap.Layers()
+	for i := len(layers) - 1; i >= 0; i-- {
+		layer := layers[i]
+		visible := widget.NewCheck(common.Truncate(layer.Name, 20), func(checked bool) {
+			h.iMap.SetLayerVisible(layer.ID, checked)
+		})
+		visible.SetChecked(layer.Visible)
+
+		opacity := widget.NewSlider(0, 1)
+		opacity.Step = 0.05
+		opacity.SetValue(layer.Opacity)
+		opacity.OnChanged = func(v float64) {
+			h.iMap.SetLayerOpacity(layer.ID, v)
+		}
+
+		up := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+			h.iMap.MoveLayer(layer.ID, 1)
+		})
+		down := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {
+			h.iMap.MoveLayer(layer.ID, -1)
+		})
+		if i == len(layers)-1 {
+			up.Disable()
+		}
+		if i == 0 {
+			down.Disable()
+		}
+		buttons := container.NewHBox(up, down)
+
+		if index := slices.IndexFunc(h.geoLayers, func(l *geoLayer) bool { return l.id == layer.ID }); index >= 0 {
+			geo := h.geoLayers[index]
+			buttons.Add(widget.NewButtonWithIcon("", theme.ColorPaletteIcon(), func() {
+				h.showLayerStyleForm(geo, "Style de la couche", "Appliquer", func() {
+					h.needMapRefresh.Set(true)
+				})
+			}))
+			buttons.Add(widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				h.removeGeoLayer(geo)
+			}))
+		}
+		rows.Add(container.NewBorder(nil, nil, visible, buttons, opacity))
+	}
+
+	width := canvas.NewRectangle(color.Transparent)
+	width.SetMinSize(fyne.NewSize(380, 0))
+	background := canvas.NewRectangle(theme.Color(theme.ColorNameOverlayBackground))
+	background.CornerRadius = theme.InputRadiusSize()
+	h.layerPanel.Objects = []fyne.CanvasObject{background, width, container.NewPadded(rows)}
+	h.layerPanel.Refresh()
+}
+
+// showAddLayerDialog asks for a GeoJSON file, then for the style of the new
+// layer.
+func (h *HomeMap) showAddLayerDialog() {
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, h.w)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(err, h.w)
+			return
+		}
+		collection, err := data.ParseGeoJSON(content)
+		if err != nil {
+			h.logger.Error("Can't parse GeoJSON layer", "error", err, "uri", reader.URI())
+			dialog.ShowError(fmt.Errorf("fichier GeoJSON invalide : %w", err), h.w)
+			return
+		}
+		if collection.Bounds().IsEmpty() {
+			dialog.ShowError(errors.New("le fichier ne contient aucune géométrie"), h.w)
+			return
+		}
+
+		name := strings.TrimSuffix(reader.URI().Name(), filepath.Ext(reader.URI().Name()))
+		h.geoLayersAdded++
+		layer := &geoLayer{
+			id:         fmt.Sprintf("geojson-%d", h.geoLayersAdded),
+			name:       name,
+			collection: collection,
+			style:      defaultGeoStyle(len(h.geoLayers)),
+		}
+		h.showLayerStyleForm(layer, "Ajouter une couche", "Ajouter", func() {
+			h.geoLayers = append(h.geoLayers, layer)
+			h.needMapRefresh.Set(true)
+		})
+	}, h.w)
+	open.SetFilter(storage.NewExtensionFileFilter([]string{".geojson", ".json"}))
+	open.Show()
+}
+
+// showLayerStyleForm edits the name and the style of a layer, applied calls
+// being made once the form is confirmed.
+func (h *HomeMap) showLayerStyleForm(layer *geoLayer, title, confirm string, applied func()) {
+	name := widget.NewEntry()
+	name.SetText(layer.name)
+
+	stroke := newColorButton(layer.style.stroke, h.w)
+	fill := newColorButton(layer.style.fill, h.w)
+
+	opacity := widget.NewSlider(0, 1)
+	opacity.Step = 0.05
+	opacity.SetValue(layer.style.fillOpacity)
+
+	widths := []string{"0.5", "1", "1.5", "2", "3", "4"}
+	width := widget.NewSelect(widths, nil)
+	width.SetSelected(strconv.FormatFloat(layer.style.lineWidth, 'f', -1, 64))
+
+	radii := []string{"1", "2", "3", "4", "6", "8"}
+	radius := widget.NewSelect(radii, nil)
+	radius.SetSelected(strconv.FormatFloat(layer.style.pointRadius, 'f', -1, 64))
+
+	dialog.ShowForm(title, confirm, "Annuler", []*widget.FormItem{
+		widget.NewFormItem("Nom", name),
+		widget.NewFormItem("Contour", stroke.button),
+		widget.NewFormItem("Épaisseur", width),
+		widget.NewFormItem("Remplissage", fill.button),
+		widget.NewFormItem("Opacité", opacity),
+		widget.NewFormItem("Rayon des points", radius),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		if text := strings.TrimSpace(name.Text); text != "" {
+			layer.name = text
+		}
+		layer.style.stroke = stroke.color
+		layer.style.fill = fill.color
+		layer.style.fillOpacity = opacity.Value
+		if v, err := strconv.ParseFloat(width.Selected, 64); err == nil {
+			layer.style.lineWidth = v
+		}
+		if v, err := strconv.ParseFloat(radius.Selected, 64); err == nil {
+			layer.style.pointRadius = v
+		}
+		applied()
+	}, h.w)
+}
+
+// colorButton shows a colour and opens a colour picker when tapped.
+type colorButton struct {
+	color  color.NRGBA
+	button *fyne.Container
+}
+
+func newColorButton(initial color.NRGBA, w fyne.Window) *colorButton {
+	c := &colorButton{color: initial}
+	swatch := canvas.NewRectangle(initial)
+	swatch.SetMinSize(fyne.NewSize(24, 16))
+	swatch.StrokeColor = theme.Color(theme.ColorNameForeground)
+	swatch.StrokeWidth = 0.5
+	pick := widget.NewButtonWithIcon("Choisir", theme.ColorPaletteIcon(), func() {
+		picker := dialog.NewColorPicker("Couleur", "", func(picked color.Color) {
+			c.color = color.NRGBAModel.Convert(picked).(color.NRGBA)
+			c.color.A = 255
+			swatch.FillColor = c.color
+			swatch.Refresh()
+		}, w)
+		picker.Advanced = true
+		picker.SetColor(c.color)
+		picker.Show()
+	})
+	c.button = container.NewHBox(container.NewCenter(swatch), pick)
+	return c
+}
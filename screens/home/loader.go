@@ -0,0 +1,50 @@
+package home
+
+import (
+	"context"
+
+	"fyne.io/fyne/v2"
+)
+
+// loader runs the background requests of a view. Starting a request cancels
+// the previous one of the same loader, so that a superseded request never
+// overwrites a newer result, and cancelling the parent context stops all of
+// them.
+type loader struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+func newLoader(parent context.Context) *loader {
+	return &loader{parent: parent}
+}
+
+func (l *loader) next() context.Context {
+	l.Cancel()
+	ctx, cancel := context.WithCancel(l.parent)
+	l.cancel = cancel
+	return ctx
+}
+
+// Cancel stops the running request, if any.
+func (l *loader) Cancel() {
+	if l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+	}
+}
+
+// load calls fetch in the background and hands its result to done on the UI
+// goroutine, unless the request was cancelled in the meantime.
+func load[T any](l *loader, fetch func(ctx context.Context) (T, error), done func(result T, err error)) {
+	ctx := l.next()
+	go func() {
+		result, err := fetch(ctx)
+		fyne.Do(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			done(result, err)
+		})
+	}()
+}
@@ -1,8 +1,8 @@
 package home
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"image/color"
 	"log/slog"
@@ -11,10 +11,11 @@ import (
 	"meteo/components/ui"
 	appcontext "meteo/context"
 	"meteo/data"
-	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
 
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
@@ -24,263 +25,525 @@ import (
 	"github.com/fogleman/gg"
 )
 
+var (
+	loadedDepartmentColor   = color.NRGBA{R: 70, G: 130, B: 180, A: 90}
+	departmentBoundaryColor = color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+)
+
 type HomeMap struct {
-	w              fyne.Window
-	logger         *slog.Logger
-	dimension      common.Dimension
-	geoData        *data.GeoData
-	iMap           *ui.InteractiveMap
-	stations       []data.StationInfo
-	stationLayer   *canvas.Image
-	mw             *container.MultipleWindows
-	db             *sql.DB
-	camera         common.Position
+	w            fyne.Window
+	logger       *slog.Logger
+	dimension    common.Dimension
+	geoData      *data.GeoData
+	iMap         *ui.InteractiveMap
+	stations     []data.StationInfo
+	stationIndex atomic.Pointer[data.StationIndex]
+	departments  []data.DepartmentShape
+	// loadedDepartments holds the department codes having stations
+	loadedDepartments atomic.Pointer[map[string]bool]
+	mw                *container.MultipleWindows
+	popups            map[string]*container.InnerWindow
+	db                *sql.DB
+	camera            common.Position
+	projection        common.MapProjection
+	frame             common.Frame
+	baseMap           *data.TileCache
+	baseMapPath       string
+	// baseMapZoom is the zoom level of the base map tiles, or autoTileZoom
+	baseMapZoom int
+	// reliefPath is the elevation model chosen last, which may still be
+	// read
+	reliefPath     string
+	relief         *relief
+	reliefTints    bool
+	reliefRequests *loader
+	reliefProgress dialog.Dialog
 	needMapRefresh binding.Bool
-	mapMode        binding.Int
+	metric         *mapMetric
+	metricResult   *metricResult
+	period         data.Period
+	metricRequests *loader
+	metricProgress dialog.Dialog
+	style          choroplethStyle
+	classes        *metricClasses
+	legend         *fyne.Container
+	geoLayers      []*geoLayer
+	geoLayersAdded int
+	layerPanel     *fyne.Container
+	grid           *data.Grid
 	ShowDetailView func(station *data.StationInfo)
+	AddToCompare   func(station *data.StationInfo)
+	LoadDepartment func(code string)
+
+	interpolationOptions  data.InterpolationOptions
+	interpolationRequests *loader
+	interpolationProgress dialog.Dialog
 }
 
 func InitHomeMap(dimension common.Dimension) *HomeMap {
-	mapModeBinding := binding.NewInt()
-	mapModeBinding.Set(int(ui.NORMAL))
 	appContext := appcontext.GetAppContext()
 	return &HomeMap{
-		w:         appContext.W,
-		logger:    appContext.Logger,
-		db:        appContext.DB,
-		dimension: dimension,
-		mw:        container.NewMultipleWindows(),
+		w:          appContext.W,
+		logger:     appContext.Logger,
+		db:         appContext.DB,
+		dimension:  dimension,
+		mw:         container.NewMultipleWindows(),
+		popups:     make(map[string]*container.InnerWindow),
+		projection: common.Lambert93,
 		camera: common.Position{
 			X: 0,
 			Y: 0,
 			Z: 1,
 		},
-		mapMode:        mapModeBinding,
-		needMapRefresh: binding.NewBool(),
+		baseMapZoom:           autoTileZoom,
+		needMapRefresh:        binding.NewBool(),
+		period:                data.CalendarYearPeriod,
+		style:                 defaultChoroplethStyle(),
+		metricRequests:        newLoader(context.Background()),
+		reliefRequests:        newLoader(context.Background()),
+		interpolationRequests: newLoader(context.Background()),
+		interpolationOptions:  data.DefaultInterpolationOptions(),
 	}
 }
 
 func (h *HomeMap) Render() *fyne.Container {
-	geojson := readGeoJsonFile(h.logger)
 	geoData := &data.GeoData{
-		FranceGeoJSON: geojson,
+		Outline: readGeoJsonFile(h.logger),
 	}
 	geoData.Bounds = geoData.ComputeBounds()
 	h.geoData = geoData
+	h.frame = common.NewFrame(h.projection, h.dimension, *geoData.Bounds)
 
-	mapImg := h.renderMap(geoData)
-	h.iMap = ui.NewInteractiveMap(mapImg, h.dimension.Width, h.dimension.Height, h.mapMode)
+	departments, err := data.LoadDepartmentShapes(data.DepartmentsGeoJSONPath)
+	if err != nil {
+		h.logger.Warn("Departments layer unavailable", "error", err, "filepath", data.DepartmentsGeoJSONPath)
+	}
+	h.departments = departments
+
+	h.iMap = ui.NewInteractiveMap(h.viewport())
 
 	h.iMap.OnHover = h.handleMapHovered
 	h.iMap.OnTap = h.handleMapTapped
-
-	h.iMap.OnDrag = h.handleOnDrag()
-
+	h.iMap.OnZoom = h.handleZoom
+
+	h.iMap.OnPan = h.handlePan
+
+	h.legend = container.NewStack()
+	legend := container.NewBorder(nil, container.NewHBox(h.legend, layout.NewSpacer()), nil, nil)
+	h.layerPanel = container.NewStack()
+	h.layerPanel.Hide()
+	layerPanel := container.NewBorder(nil, nil, container.NewVBox(h.layerPanel), nil)
+	h.iMap.OnLayersChanged = func() {
+		h.refreshLegend()
+		h.refreshLayerPanel()
+	}
+	h.refreshLayers()
 	actions := h.createMapActions()
 
 	h.needMapRefresh.AddListener(binding.NewDataListener(func() {
 		needRefresh, err := h.needMapRefresh.Get()
 		if err == nil {
 			if needRefresh {
-				h.iMap.Image = h.renderMap(geoData)
-				h.AddStationsLayer(h.stations)
-				h.iMap.Refresh()
-				h.stationLayer.Refresh()
+				h.refreshLayers()
 				h.needMapRefresh.Set(false)
 			}
 		}
 
 	}))
 
-	return container.NewStack(h.iMap, actions, h.mw)
+	return container.NewStack(h.iMap, legend, layerPanel, actions, h.mw)
 }
 
-func (h *HomeMap) handleOnDrag() func(dx, dy float64) {
-	return func(dx, dy float64) {
-		h.needMapRefresh.Set(true)
-		h.camera.X -= dx
-		h.camera.Y += dy
-	}
-
+func (h *HomeMap) GeoData() *data.GeoData {
+	return h.geoData
 }
 
 func (h *HomeMap) createMapActions() *fyne.Container {
 
-	moveMapButton := widget.NewButtonWithIcon("Move", ui.ResourceMovePng, func() {
-		mode, _ := h.mapMode.Get()
-		switch ui.MapMode(mode) {
-		case ui.MOVE:
-			h.mapMode.Set(int(ui.NORMAL))
-		case ui.NORMAL:
-			h.mapMode.Set(int(ui.MOVE))
-		}
+	zoomInButton := widget.NewButtonWithIcon("Zoom In", theme.ZoomInIcon(), func() {
+		h.zoomAt(ui.ZoomStep, h.dimension.Width/2, h.dimension.Height/2)
 	})
 
-	zoomInButton := widget.NewButtonWithIcon("Zoom In", theme.ZoomInIcon(), func() {
-		h.camera.Z += 0.1
+	zoomOutButton := widget.NewButtonWithIcon("Zoom Out", theme.ZoomOutIcon(), func() {
+		h.zoomAt(1/ui.ZoomStep, h.dimension.Width/2, h.dimension.Height/2)
+	})
+
+	zoomSelect := h.newZoomSelect()
+
+	metrics := mapMetrics()
+	selectMetric := widget.NewSelect(metricNames(metrics), func(name string) {
+		for _, m := range metrics {
+			if m.name == name {
+				h.loadMetric(m)
+				return
+			}
+		}
+		h.metricRequests.Cancel()
+		h.metric = nil
+		h.metricResult = nil
+		h.classes = nil
+		h.grid = nil
 		h.needMapRefresh.Set(true)
 	})
+	selectMetric.SetSelected(noMetric)
 
-	zoomOutButton := widget.NewButtonWithIcon("Zoom Out", theme.ZoomOutIcon(), func() {
-		if h.camera.Z == 0.1 {
+	selectPeriod := newPeriodSelect(data.Periods, h.period, func(p data.Period) {
+		h.period = p
+		if h.metric != nil {
+			h.loadMetric(*h.metric)
+		}
+	})
+
+	interpolateButton := widget.NewButtonWithIcon("Interpolation", theme.GridIcon(), h.showInterpolationForm)
+	layersButton := widget.NewButtonWithIcon("Couches", theme.ListIcon(), h.toggleLayerPanel)
+	settingsButton := widget.NewButtonWithIcon("Paramètres", theme.SettingsIcon(), h.showMapSettingsForm)
+	styleButton := widget.NewButtonWithIcon("Style", theme.ColorPaletteIcon(), h.showStyleForm)
+	addLayerButton := widget.NewButtonWithIcon("Ajouter une couche", theme.ContentAddIcon(), h.showAddLayerDialog)
+
+	actions := container.NewVBox(zoomInButton, zoomOutButton, zoomSelect, selectMetric, selectPeriod, styleButton, interpolateButton, layersButton, addLayerButton, settingsButton)
+	return container.NewHBox(layout.NewSpacer(), actions)
+}
+
+func (h *HomeMap) loadMetric(m mapMetric) {
+	if h.metricProgress != nil {
+		h.metricProgress.SetOnClosed(nil)
+		h.metricProgress.Hide()
+	}
+	progress := dialog.NewCustom(
+		"Calcul en cours",
+		"Annuler",
+		container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("Calcul de l'indicateur %s...", m.name)),
+			widget.NewProgressBarInfinite(),
+		),
+		h.w,
+	)
+	progress.SetOnClosed(h.metricRequests.Cancel)
+	progress.Show()
+	h.metricProgress = progress
+
+	period := h.period
+	load(h.metricRequests, func(ctx context.Context) (*metricResult, error) {
+		return m.compute(ctx, h.db, period)
+	}, func(result *metricResult, err error) {
+		progress.SetOnClosed(nil)
+		progress.Hide()
+		h.metricProgress = nil
+		if err != nil {
+			h.logger.Error("Can't compute map metric", "error", err, "metric", m.name)
+			dialog.ShowError(err, h.w)
 			return
 		}
-		h.camera.Z -= 0.1
+		h.metric = &m
+		h.metricResult = result
+		h.classifyMetric()
+		h.grid = nil
 		h.needMapRefresh.Set(true)
 	})
+}
 
-	h.mapMode.AddListener(binding.NewDataListener(func() {
-		mode, _ := h.mapMode.Get()
-		switch ui.MapMode(mode) {
-		case ui.MOVE:
-			moveMapButton.FocusGained()
-		case ui.NORMAL:
-			moveMapButton.FocusLost()
+// SetStations replaces the stations shown on the map and rebuilds the
+// spatial index used for hover and tap lookups.
+func (h *HomeMap) SetStations(stations []data.StationInfo) {
+	loaded := make(map[string]bool)
+	for _, s := range stations {
+		if !s.IsAggregate() {
+			loaded[data.DepartmentOf(s.NumPost)] = true
 		}
-	}))
+	}
+	h.loadedDepartments.Store(&loaded)
+	h.stationIndex.Store(data.NewStationIndex(stations))
+	h.AddStationsLayer(stations)
+	// the departments shading depends on the loaded stations
+	h.needMapRefresh.Set(true)
+}
 
-	actions := container.NewVBox(moveMapButton, zoomInButton, zoomOutButton)
-	return container.NewHBox(layout.NewSpacer(), actions)
+// VisibleStations returns the stations inside the current view of the map.
+func (h *HomeMap) VisibleStations() []data.StationInfo {
+	visible := make([]data.StationInfo, 0, len(h.stations))
+	v := h.iMap.Viewport()
+	for _, station := range h.stations {
+		x, y := v.Project(station.Lon, station.Lat)
+		if x >= 0 && x <= h.dimension.Width && y >= 0 && y <= h.dimension.Height {
+			visible = append(visible, station)
+		}
+	}
+	return visible
 }
 
 func (h *HomeMap) AddStationsLayer(stations []data.StationInfo) {
 	h.stations = stations
-	stationsImg := h.renderStations(stations)
-	h.iMap.RemoveLayer(h.stationLayer)
-	h.iMap.AddLayer(stationsImg)
-	h.stationLayer = stationsImg
+	layer := h.iMap.SetLayer(stationsLayerID, "Stations", &stationLayer{
+		stations: stations,
+		index:    h.stationIndex.Load(),
+		metric:   h.metric,
+		result:   h.metricResult,
+		classes:  h.classes,
+	})
+	layer.Legend = nil
+	if h.classes != nil {
+		layer.Legend = h.classes.legend()
+	}
 }
 
-func readGeoJsonFile(logger *slog.Logger) data.FranceGeoJSON {
-	filepath := "./data/geo/metropole-version-simplifiee.geojson"
-	file, err := os.ReadFile(filepath)
+func readGeoJsonFile(logger *slog.Logger) *data.FeatureCollection {
+	outline, err := data.LoadGeoJSON(data.FranceGeoJSONPath)
 	if err != nil {
-		logger.Error("Can't read file", "error", err, "filepath", filepath)
+		logger.Error("Can't read outline", "error", err, "filepath", data.FranceGeoJSONPath)
+		return &data.FeatureCollection{}
 	}
+	return outline
+}
 
-	geojson := data.FranceGeoJSON{}
-	err = json.Unmarshal(file, &geojson)
-	if err != nil {
-		logger.Error("Can't parse to json", "error", err)
-	}
-	return geojson
+// stationLayer draws the stations, coloured and sized by the classes of the
+// metric when there is one.
+type stationLayer struct {
+	stations []data.StationInfo
+	index    *data.StationIndex
+	metric   *mapMetric
+	result   *metricResult
+	classes  *metricClasses
 }
 
-func (h *HomeMap) renderMap(g *data.GeoData) *canvas.Image {
-	var prevX, prevY float64
+var _ ui.Layer = (*stationLayer)(nil)
+
+// radius is the size in pixels of the symbol of a station.
+func (l *stationLayer) radius(station *data.StationInfo) float64 {
+	if l.classes == nil {
+		return 0.5
+	}
+	v, ok := l.result.values[station.NumPost]
+	if !ok {
+		return 1
+	}
+	radius := l.classes.radius(v)
+	if l.metric.arrows {
+		// the tip of the triangles
+		return 5 * radius / defaultStationRadius
+	}
+	return radius
+}
 
-	dc := gg.NewContext(int(h.dimension.Width), int(h.dimension.Height))
+func (l *stationLayer) Draw(dc *gg.Context, v ui.Viewport) {
 	dc.SetColor(color.White)
 	dc.SetLineWidth(2)
 
-	for i := range g.Geometry.Coordinates {
-		for j := range g.Geometry.Coordinates[i] {
-			for k := range g.Geometry.Coordinates[i][j] {
-				outline := g.Geometry.Coordinates[i][j][k]
+	if l.classes == nil {
+		for _, station := range l.stations {
+			x, y := v.Project(station.Lon, station.Lat)
+			dc.DrawCircle(x, y, 0.5)
+			dc.Fill()
+		}
+		return
+	}
 
-				x, y := common.Projection(outline[0], outline[1], h.camera, h.dimension, *g.Bounds)
-				if k != 0 {
-					dc.MoveTo(prevX, prevY)
-					dc.LineTo(x, y)
-				}
-				prevX, prevY = x, y
-			}
-			dc.ClosePath()
+	for _, station := range l.stations {
+		x, y := v.Project(station.Lon, station.Lat)
+		value, ok := l.result.values[station.NumPost]
+		if !ok {
+			dc.SetColor(color.Gray{Y: 100})
+			dc.DrawCircle(x, y, 1)
+			dc.Fill()
+			continue
+		}
+
+		dc.SetColor(l.classes.color(value))
+		radius := l.classes.radius(value)
+		if !l.metric.arrows {
+			dc.DrawCircle(x, y, radius)
+			dc.Fill()
+			continue
+		}
+
+		// upward triangle for an increasing trend, hollow when not significant
+		direction := -1.0
+		if value < 0 {
+			direction = 1.0
+		}
+		scale := radius / defaultStationRadius
+		dc.MoveTo(x, y+5*scale*direction)
+		dc.LineTo(x-4*scale, y-3*scale*direction)
+		dc.LineTo(x+4*scale, y-3*scale*direction)
+		dc.ClosePath()
+		if l.result.significant[station.NumPost] {
+			dc.Fill()
+		} else {
+			dc.SetLineWidth(1)
 			dc.Stroke()
 		}
 	}
-
-	img := canvas.NewImageFromImage(dc.Image())
-	img.FillMode = canvas.ImageFillContain
-	return img
 }
 
-func (h *HomeMap) renderStations(stations []data.StationInfo) *canvas.Image {
-	dc := gg.NewContext(int(h.dimension.Width), int(h.dimension.Height))
-	dc.SetColor(color.White)
-	dc.SetLineWidth(2)
-
-	for _, station := range stations {
-		x, y := common.Projection(station.Lon, station.Lat, h.camera, h.dimension, *h.geoData.Bounds)
-		dc.DrawCircle(x, y, 0.5)
-		dc.Fill()
+// HitTest looks for the stations around the position in the spatial index,
+// then keeps the closest one whose symbol is under the cursor.
+func (l *stationLayer) HitTest(v ui.Viewport, pos fyne.Position) (int, bool) {
+	if l.index == nil {
+		return 0, false
 	}
+	reach := maxStationRadius + ui.HitTolerance
+	lon, lat := v.Unproject(float64(pos.X), float64(pos.Y))
+	edgeLon, edgeLat := v.Unproject(float64(pos.X)+reach, float64(pos.Y)+reach)
+	radiusKm := data.Haversine(lat, lon, edgeLat, edgeLon)
+
+	found, best := -1, math.MaxFloat64
+	for _, candidate := range l.index.Within(lat, lon, radiusKm) {
+		x, y := v.Project(candidate.Station.Lon, candidate.Station.Lat)
+		distance := ui.PointDistance(pos, x, y)
+		if distance > l.radius(candidate.Station)+ui.HitTolerance || distance >= best {
+			continue
+		}
+		i := slices.IndexFunc(l.stations, func(s data.StationInfo) bool { return s.NumPost == candidate.Station.NumPost })
+		if i >= 0 {
+			found, best = i, distance
+		}
+	}
+	return found, found >= 0
+}
 
-	img := canvas.NewImageFromImage(dc.Image())
-	img.FillMode = canvas.ImageFillContain
-	return img
+func (l *stationLayer) Tooltip(feature int) string {
+	station := &l.stations[feature]
+	text := station.Label()
+	if l.classes == nil {
+		return text
+	}
+	value, ok := l.result.values[station.NumPost]
+	if !ok {
+		return text
+	}
+	if len(l.metric.categories) > 0 {
+		return fmt.Sprintf("%s : %s", text, l.metric.categories[l.classes.class(value)].label)
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s : %.1f %s", text, value, l.metric.unit))
 }
 
-func (h *HomeMap) handleMapHovered(pos fyne.Position) string {
-	mode, _ := h.mapMode.Get()
-	if ui.MapMode(mode) == ui.NORMAL {
-		lon, lat := common.ProjectionFromXY(float64(pos.X), float64(pos.Y), h.camera, h.dimension, *h.geoData.Bounds)
-		station, err := data.GetClosestStation(h.db, lat, lon)
-		if err != nil {
-			return ""
+func (h *HomeMap) handleMapHovered(_ context.Context, pos fyne.Position) string {
+	text := ""
+	// the layers belong to the UI goroutine
+	fyne.DoAndWait(func() {
+		if layer, feature, ok := h.iMap.FeatureAt(pos); ok {
+			text = layer.Layer.Tooltip(feature)
 		}
-		return station.CommonName
-	}
-	return ""
+		if h.grid != nil {
+			lon, lat := h.iMap.Viewport().Unproject(float64(pos.X), float64(pos.Y))
+			if v := h.grid.ValueAt(lon, lat); !math.IsNaN(v) {
+				text = strings.TrimSpace(fmt.Sprintf("%s (interpolé : %.1f)", text, v))
+			}
+		}
+		if layer, ok := h.iMap.Layer(reliefLayerID); ok && layer.Visible && h.relief != nil {
+			lon, lat := h.iMap.Viewport().Unproject(float64(pos.X), float64(pos.Y))
+			if elevation := h.relief.elevationAt(lon, lat); !math.IsNaN(elevation) {
+				text = strings.TrimSpace(fmt.Sprintf("%s (altitude : %.0f m)", text, elevation))
+			}
+		}
+	})
+	return text
 }
 
 func (h *HomeMap) handleMapTapped(pos fyne.Position) {
-	mode, _ := h.mapMode.Get()
-	switch ui.MapMode(mode) {
-	case ui.NORMAL:
-		lon, lat := common.ProjectionFromXY(float64(pos.X), float64(pos.Y), h.camera, h.dimension, *h.geoData.Bounds)
-		station, err := data.GetClosestStation(h.db, lat, lon)
-		if err != nil {
-			dialog.NewError(err, h.w)
+	layer, feature, ok := h.iMap.FeatureAt(pos)
+	if !ok {
+		return
+	}
+	switch l := layer.Layer.(type) {
+	case *stationLayer:
+		h.HandleStationWindow(&l.stations[feature], h.ShowDetailView)
+	case *departmentLayer:
+		if l.isLoaded(feature) || h.LoadDepartment == nil {
 			return
 		}
-		h.HandleStationWindow(station, h.ShowDetailView)
-	case ui.MOVE:
-
+		department := l.shapes[feature]
+		code := department.StationDepartment()
+		dialog.ShowConfirm(
+			"Charger un département",
+			fmt.Sprintf("Importer les stations du département %s ?", departmentLabel(data.Department{Code: department.Code, Name: department.Name})),
+			func(ok bool) {
+				if ok {
+					h.LoadDepartment(code)
+				}
+			},
+			h.w,
+		)
 	}
 }
 
 func (h *HomeMap) HandleStationWindow(station *data.StationInfo, showDetailsHandler func(station *data.StationInfo)) {
-	content := buildStationMetadataDisplay(h.db, h.w, station)
-	if content != nil {
-		wrapped := container.New(
-			layout.NewGridWrapLayout(fyne.NewSize(250, 150)), content)
-		vbox := container.NewVBox(
-			wrapped,
-		)
-		if showDetailsHandler != nil {
-			vbox.Add(
-				widget.NewButtonWithIcon("Données de la station", theme.SearchIcon(), func() {
-					showDetailsHandler(station)
-				}),
-			)
-		}
-		iw := container.NewInnerWindow(station.CommonName, vbox)
-		iw.CloseIntercept = func() {
-			for i, win := range h.mw.Windows {
-				if win == iw {
-					h.mw.Windows = append(h.mw.Windows[:i], h.mw.Windows[i+1:]...)
-					break
-				}
-			}
-			h.mw.Refresh()
-		}
+	// a station already shown is brought to the front instead of opened twice
+	if iw, ok := h.popups[station.NumPost]; ok {
+		h.removePopup(iw)
 		h.mw.Windows = append(h.mw.Windows, iw)
 		h.mw.Refresh()
+		return
+	}
+	// the statistics are filled in once read
+	metadata := container.NewStack(widget.NewProgressBarInfinite())
+	wrapped := container.New(
+		layout.NewGridWrapLayout(fyne.NewSize(250, 150)), metadata)
+	vbox := container.NewVBox(
+		wrapped,
+	)
+	if showDetailsHandler != nil {
+		vbox.Add(
+			widget.NewButtonWithIcon("Données de la station", theme.SearchIcon(), func() {
+				showDetailsHandler(station)
+			}),
+		)
+	}
+	vbox.Add(
+		widget.NewButtonWithIcon("Zoomer sur la station", theme.ZoomFitIcon(), func() {
+			h.ZoomToStation(station)
+		}),
+	)
+	if h.AddToCompare != nil {
+		vbox.Add(
+			widget.NewButtonWithIcon("Comparer", theme.ContentAddIcon(), func() {
+				h.AddToCompare(station)
+			}),
+		)
+	}
+	requests := newLoader(context.Background())
+	iw := container.NewInnerWindow(station.Label(), vbox)
+	iw.CloseIntercept = func() {
+		requests.Cancel()
+		h.removePopup(iw)
+		delete(h.popups, station.NumPost)
+		h.mw.Refresh()
 	}
+	h.popups[station.NumPost] = iw
+	h.mw.Windows = append(h.mw.Windows, iw)
+	h.mw.Refresh()
+
+	period := h.period
+	load(requests, func(ctx context.Context) ([]data.RainByStation, error) {
+		return data.GetRainByStation(ctx, h.db, station.NumPost, period)
+	}, func(rain []data.RainByStation, err error) {
+		if err != nil {
+			h.logger.Error("Can't read station rain", "error", err, "station", station.NumPost)
+			iw.CloseIntercept()
+			dialog.ShowError(err, h.w)
+			return
+		}
+		metadata.Objects = []fyne.CanvasObject{buildStationMetadataDisplay(station, period, rain)}
+		metadata.Refresh()
+	})
 }
 
-func buildStationMetadataDisplay(db *sql.DB, w fyne.Window, station *data.StationInfo) *fyne.Container {
-	weatherData, err := data.GetRainByStation(db, station.NumPost)
-	if err != nil {
-		dialog.NewError(err, w)
-		return nil
+func (h *HomeMap) removePopup(iw *container.InnerWindow) {
+	for i, win := range h.mw.Windows {
+		if win == iw {
+			h.mw.Windows = append(h.mw.Windows[:i], h.mw.Windows[i+1:]...)
+			break
+		}
 	}
+}
 
+func buildStationMetadataDisplay(station *data.StationInfo, period data.Period, weatherData []data.RainByStation) *fyne.Container {
 	grid := container.New(layout.NewGridLayout(2))
 	grid.Add(widget.NewLabel("Nom"))
 	grid.Add(widget.NewLabel(common.Truncate(station.CommonName, 10)))
 
+	grid.Add(widget.NewLabel("Période"))
+	grid.Add(widget.NewLabel(common.Truncate(period.String(), 10)))
+
 	min, max, avg := getMinMaxAvgRainByStation(weatherData)
 	grid.Add(widget.NewLabel("Moyenne"))
 	grid.Add(widget.NewLabel(fmt.Sprintf("%.0f", avg)))
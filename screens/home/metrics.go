@@ -0,0 +1,147 @@
+package home
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"image/color"
+	"meteo/data"
+	"slices"
+)
+
+const noMetric = "Aucun indicateur"
+
+type metricResult struct {
+	values map[string]float64
+	// significant is only filled by trend metrics, which are drawn as arrows.
+	significant map[string]bool
+}
+
+type metricCategory struct {
+	label string
+	color color.Color
+}
+
+type mapMetric struct {
+	name    string
+	unit    string
+	compute func(ctx context.Context, db *sql.DB, period data.Period) (*metricResult, error)
+	// diverging metrics are centred on zero and drawn with ramp unless the
+	// user picks another one
+	diverging bool
+	ramp      string
+	// categorical metrics have fixed classes instead of classified values
+	categories []metricCategory
+	category   func(v float64) int
+	arrows     bool
+}
+
+// recentRecordYears is the span of the record count metric.
+const recentRecordYears = 10
+
+func mapMetrics() []mapMetric {
+	metrics := make([]mapMetric, 0, len(data.ClimateIndices)+len(data.SPIScales)+7)
+	for _, variable := range []data.Variable{data.Rain, data.MeanTemperature} {
+		metrics = append(metrics, mapMetric{
+			name: fmt.Sprintf("Moyenne %s (%s)", variable, variable.Unit()),
+			unit: variable.Unit(),
+			compute: func(ctx context.Context, db *sql.DB, period data.Period) (*metricResult, error) {
+				values, err := data.GetMeanByStation(ctx, db, variable, period)
+				return &metricResult{values: values}, err
+			},
+		})
+	}
+	for _, variable := range []data.Variable{data.Rain, data.MeanTemperature} {
+		metrics = append(metrics, mapMetric{
+			name: fmt.Sprintf("Anomalie %s (%s, écart à %d-%d)", variable, variable.Unit(), data.NormalsFirstYear, data.NormalsLastYear),
+			unit: variable.Unit(),
+			compute: func(ctx context.Context, db *sql.DB, period data.Period) (*metricResult, error) {
+				values, err := data.GetAnomalyByStation(ctx, db, variable, period)
+				return &metricResult{values: values}, err
+			},
+			diverging: true,
+			ramp:      variableRamp(variable),
+		})
+	}
+	metrics = append(metrics, mapMetric{
+		name: fmt.Sprintf("Records quotidiens des %d dernières années", recentRecordYears),
+		unit: "records",
+		// the records cover the whole dataset and ignore the period
+		compute: func(ctx context.Context, db *sql.DB, _ data.Period) (*metricResult, error) {
+			values, err := data.GetRecentRecordCountByStation(ctx, db, recentRecordYears)
+			return &metricResult{values: values}, err
+		},
+	})
+	for _, info := range data.ClimateIndices {
+		index := info.Index
+		metrics = append(metrics, mapMetric{
+			name: fmt.Sprintf("%s (%s)", info.Index, info.Unit),
+			unit: info.Unit,
+			compute: func(ctx context.Context, db *sql.DB, period data.Period) (*metricResult, error) {
+				values, err := data.GetClimateIndexByStation(ctx, db, index, period)
+				return &metricResult{values: values}, err
+			},
+		})
+	}
+	droughtCategories := make([]metricCategory, 0, len(data.DroughtCategories))
+	for _, c := range data.DroughtCategories {
+		droughtCategories = append(droughtCategories, metricCategory{label: c.String(), color: droughtColor(c)})
+	}
+	for _, scale := range data.SPIScales {
+		metrics = append(metrics, mapMetric{
+			name: fmt.Sprintf("%s (dernière classe)", spiLabel(scale)),
+			// the SPI has its own accumulation scale and ignores the period
+			compute: func(ctx context.Context, db *sql.DB, _ data.Period) (*metricResult, error) {
+				values, err := data.GetLatestSPIByStation(ctx, db, scale)
+				return &metricResult{values: values}, err
+			},
+			categories: droughtCategories,
+			category: func(v float64) int {
+				return slices.Index(data.DroughtCategories, data.SPICategory(v))
+			},
+		})
+	}
+	for _, variable := range []data.Variable{data.Rain, data.MeanTemperature} {
+		metrics = append(metrics, mapMetric{
+			name: fmt.Sprintf("Tendance %s (%s/décennie)", variable, variable.Unit()),
+			unit: variable.Unit() + "/décennie",
+			compute: func(ctx context.Context, db *sql.DB, period data.Period) (*metricResult, error) {
+				trends, err := data.GetTrendByStation(ctx, db, variable, period)
+				if err != nil {
+					return nil, err
+				}
+				result := &metricResult{
+					values:      make(map[string]float64, len(trends)),
+					significant: make(map[string]bool, len(trends)),
+				}
+				for numPost, trend := range trends {
+					result.values[numPost] = trend.SlopePerDecade
+					result.significant[numPost] = trend.Significant
+				}
+				return result, nil
+			},
+			diverging: true,
+			ramp:      variableRamp(variable),
+			arrows:    true,
+		})
+	}
+	return metrics
+}
+
+func metricNames(metrics []mapMetric) []string {
+	names := make([]string, 0, len(metrics)+1)
+	names = append(names, noMetric)
+	for _, m := range metrics {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+// variableRamp is the default diverging ramp of a variable: brown to green
+// for rain, blue to red for temperatures.
+func variableRamp(variable data.Variable) string {
+	if variable == data.Rain {
+		return "Brun - vert divergent (BrBG)"
+	}
+	return "Bleu - rouge divergent (RdBu)"
+}
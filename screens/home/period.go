@@ -0,0 +1,80 @@
+package home
+
+import (
+	"meteo/data"
+	"slices"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+var monthNames = []string{
+	"Janvier", "Février", "Mars", "Avril", "Mai", "Juin",
+	"Juillet", "Août", "Septembre", "Octobre", "Novembre", "Décembre",
+}
+
+// newPeriodSelect builds the aggregation period selector shared by charts and
+// map metrics. The first month of the hydrological year can only be changed
+// when hydrological years are selected. onChanged is not called for the
+// initial period.
+func newPeriodSelect(periods func(hydrologicalStart time.Month) []data.Period, initial data.Period, onChanged func(data.Period)) fyne.CanvasObject {
+	start := data.DefaultHydrologicalStart
+	if initial.Kind == data.HydrologicalYear && initial.StartMonth != 0 {
+		start = initial.StartMonth
+	}
+	current := initial
+
+	labels := func() []string {
+		options := periods(start)
+		response := make([]string, 0, len(options))
+		for _, p := range options {
+			response = append(response, p.String())
+		}
+		return response
+	}
+
+	selectMonth := widget.NewSelect(monthNames, nil)
+	selectPeriod := widget.NewSelect(labels(), nil)
+	selectPeriod.Selected = current.String()
+
+	selectMonth.Selected = monthNames[start-1]
+	if current.Kind != data.HydrologicalYear {
+		selectMonth.Disable()
+	}
+
+	selectPeriod.OnChanged = func(label string) {
+		for _, p := range periods(start) {
+			if p.String() != label || p == current {
+				continue
+			}
+			current = p
+			if p.Kind == data.HydrologicalYear {
+				selectMonth.Enable()
+			} else {
+				selectMonth.Disable()
+			}
+			onChanged(p)
+		}
+	}
+	selectMonth.OnChanged = func(month string) {
+		start = time.Month(slices.Index(monthNames, month) + 1)
+		selectPeriod.Options = labels()
+		if current.Kind == data.HydrologicalYear {
+			current = data.Period{Kind: data.HydrologicalYear, StartMonth: start}
+			selectPeriod.Selected = current.String()
+			onChanged(current)
+		}
+		selectPeriod.Refresh()
+	}
+
+	return container.NewGridWithColumns(2, selectPeriod, selectMonth)
+}
+
+// yearlyPeriods restricts the periods to the ones spanning a whole year.
+func yearlyPeriods(hydrologicalStart time.Month) []data.Period {
+	return slices.DeleteFunc(data.Periods(hydrologicalStart), func(p data.Period) bool {
+		return !p.IsYearly()
+	})
+}
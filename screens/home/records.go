@@ -0,0 +1,244 @@
+package home
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	appcontext "meteo/context"
+	"meteo/data"
+	"slices"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+var recordColumns = []string{"Jour", "Pluie max", "TX max", "TN min", "Années"}
+
+func (c *StationDetailsComponent) renderRecords(station *data.StationInfo) fyne.CanvasObject {
+	if station.IsAggregate() {
+		return widget.NewLabel("Les records ne sont calculés que pour les stations de mesure")
+	}
+	content := container.NewStack(widget.NewProgressBarInfinite())
+	load(newLoader(c.ctx), func(ctx context.Context) ([]data.DayRecord, error) {
+		return data.GetStationRecords(ctx, c.db, station.NumPost)
+	}, func(records []data.DayRecord, err error) {
+		content.Objects = []fyne.CanvasObject{c.renderRecordTable(station, records, err)}
+		content.Refresh()
+	})
+	return content
+}
+
+func (c *StationDetailsComponent) renderRecordTable(station *data.StationInfo, records []data.DayRecord, err error) fyne.CanvasObject {
+	if err != nil {
+		c.logger.Error("Can't fetch daily records", "error", err, "station", station.NumPost)
+		return widget.NewLabel("Impossible de charger les records de la station")
+	}
+	if len(records) == 0 {
+		return widget.NewLabel("Aucune donnée quotidienne pour cette station")
+	}
+
+	summary := container.NewVBox()
+	absolute := data.AbsoluteRecords(records)
+	for _, v := range data.RecordVariables {
+		if r, ok := absolute[v]; ok {
+			value, year := r.Value(v)
+			summary.Add(widget.NewLabel(fmt.Sprintf("%s : %.1f %s le %02d/%02d/%d", v, value, v.Unit(), r.Day, r.Month, year)))
+		}
+	}
+
+	today := time.Now()
+	todayIndex := slices.IndexFunc(records, func(r data.DayRecord) bool {
+		return r.Month == today.Month() && r.Day == today.Day()
+	})
+	if todayIndex >= 0 {
+		r := records[todayIndex]
+		summary.Add(widget.NewLabelWithStyle(
+			fmt.Sprintf("Ce jour (%02d/%02d) : pluie %s, TX %s, TN %s", r.Day, r.Month, recordCell(r.RR, r.RRYear, "mm"), recordCell(r.TX, r.TXYear, "°C"), recordCell(r.TN, r.TNYear, "°C")),
+			fyne.TextAlignLeading, fyne.TextStyle{Bold: true},
+		))
+	}
+
+	table := widget.NewTable(
+		func() (int, int) { return len(records), len(recordColumns) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			r := records[id.Row]
+			label := o.(*widget.Label)
+			label.TextStyle = fyne.TextStyle{Bold: id.Row == todayIndex}
+			switch id.Col {
+			case 0:
+				label.SetText(fmt.Sprintf("%02d/%02d", r.Day, r.Month))
+			case 1:
+				label.SetText(recordCell(r.RR, r.RRYear, "mm"))
+			case 2:
+				label.SetText(recordCell(r.TX, r.TXYear, "°C"))
+			case 3:
+				label.SetText(recordCell(r.TN, r.TNYear, "°C"))
+			case 4:
+				label.SetText(fmt.Sprint(r.Years))
+			}
+		},
+	)
+	table.ShowHeaderRow = true
+	table.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	}
+	table.UpdateHeader = func(id widget.TableCellID, o fyne.CanvasObject) {
+		if id.Col >= 0 {
+			o.(*widget.Label).SetText(recordColumns[id.Col])
+		}
+	}
+	table.SetColumnWidth(0, 70)
+	for i := 1; i < 4; i++ {
+		table.SetColumnWidth(i, 140)
+	}
+	if todayIndex >= 0 {
+		table.ScrollTo(widget.TableCellID{Row: todayIndex})
+	}
+
+	return container.NewBorder(summary, nil, nil, nil, table)
+}
+
+func recordCell(value float64, year int, unit string) string {
+	if math.IsNaN(value) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f %s (%d)", value, unit, year)
+}
+
+var brokenRecordColumns = []string{"Station", "Record", "Valeur", "Ancien record", "Années"}
+
+// RecordsComponent lists the stations which broke a daily record on a date.
+type RecordsComponent struct {
+	db       *sql.DB
+	logger   *slog.Logger
+	ctx      context.Context
+	cancel   context.CancelFunc
+	requests *loader
+	records  []data.BrokenRecord
+
+	// Stations returns the loaded stations, used to name the results.
+	Stations      func() []data.StationInfo
+	OnOpenStation func(station *data.StationInfo)
+
+	stations map[string]*data.StationInfo
+	status   *widget.Label
+	table    *widget.Table
+	content  fyne.CanvasObject
+}
+
+func InitRecordsComponent() *RecordsComponent {
+	appContext := appcontext.GetAppContext()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RecordsComponent{
+		db:       appContext.DB,
+		logger:   appContext.Logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		requests: newLoader(ctx),
+		stations: make(map[string]*data.StationInfo),
+	}
+}
+
+func (r *RecordsComponent) Render() fyne.CanvasObject {
+	if r.content != nil {
+		return r.content
+	}
+
+	r.status = widget.NewLabel("")
+	date := widget.NewDateEntry()
+	yesterday := time.Now().AddDate(0, 0, -1)
+	date.SetDate(&yesterday)
+	search := widget.NewButtonWithIcon("Rechercher", theme.SearchIcon(), func() {
+		if date.Date != nil {
+			r.search(*date.Date)
+		}
+	})
+
+	r.table = widget.NewTable(
+		func() (int, int) { return len(r.records), len(brokenRecordColumns) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(r.cell(r.records[id.Row], id.Col))
+		},
+	)
+	r.table.ShowHeaderRow = true
+	r.table.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	}
+	r.table.UpdateHeader = func(id widget.TableCellID, o fyne.CanvasObject) {
+		if id.Col >= 0 {
+			o.(*widget.Label).SetText(brokenRecordColumns[id.Col])
+		}
+	}
+	r.table.OnSelected = func(id widget.TableCellID) {
+		r.table.UnselectAll()
+		if id.Row < len(r.records) && r.OnOpenStation != nil {
+			if station, ok := r.stations[r.records[id.Row].NumPost]; ok {
+				r.OnOpenStation(station)
+			}
+		}
+	}
+	r.table.SetColumnWidth(0, 220)
+	r.table.SetColumnWidth(1, 260)
+	r.table.SetColumnWidth(2, 90)
+	r.table.SetColumnWidth(3, 140)
+
+	top := container.NewBorder(nil, nil, widget.NewLabel("Date"), search, date)
+	r.content = container.NewBorder(container.NewVBox(top, r.status), nil, nil, nil, r.table)
+	r.search(yesterday)
+	return r.content
+}
+
+// Close cancels the request still running, if any.
+func (r *RecordsComponent) Close() {
+	r.cancel()
+}
+
+func (r *RecordsComponent) search(date time.Time) {
+	r.status.SetText(fmt.Sprintf("Recherche des records du %s...", date.Format("02/01/2006")))
+	load(r.requests, func(ctx context.Context) ([]data.BrokenRecord, error) {
+		return data.GetBrokenRecords(ctx, r.db, date)
+	}, func(records []data.BrokenRecord, err error) {
+		if err != nil {
+			r.logger.Error("Can't fetch broken records", "error", err)
+			r.status.SetText(fmt.Sprintf("Erreur : %s", err))
+			return
+		}
+		r.stations = make(map[string]*data.StationInfo)
+		if r.Stations != nil {
+			stations := r.Stations()
+			for i := range stations {
+				r.stations[stations[i].NumPost] = &stations[i]
+			}
+		}
+		r.records = records
+		r.status.SetText(fmt.Sprintf("%d records battus le %s", len(records), date.Format("02/01/2006")))
+		r.table.ScrollToTop()
+		r.table.Refresh()
+	})
+}
+
+func (r *RecordsComponent) cell(record data.BrokenRecord, col int) string {
+	unit := record.Variable.Unit()
+	switch col {
+	case 0:
+		if station, ok := r.stations[record.NumPost]; ok {
+			return station.Label()
+		}
+		return record.NumPost
+	case 1:
+		return record.Variable.String()
+	case 2:
+		return fmt.Sprintf("%.1f %s", record.Value, unit)
+	case 3:
+		return fmt.Sprintf("%.1f %s (%d)", record.Previous, unit, record.PreviousYear)
+	default:
+		return fmt.Sprint(record.Years)
+	}
+}
@@ -0,0 +1,220 @@
+package home
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"meteo/common"
+	"meteo/components/ui"
+	"meteo/data"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/fogleman/gg"
+)
+
+const (
+	reliefLayerID = "relief"
+	// reliefOpacity is the opacity the relief layer starts with
+	reliefOpacity = 0.6
+	// the sun lights the relief from the north-west, 45° above the horizon,
+	// as on most maps
+	sunAzimuth         = 315.0
+	sunAltitude        = 45.0
+	reliefExaggeration = 1.5
+)
+
+// hypsometricTints colour the elevations, from the lowlands to the high
+// mountains.
+var hypsometricTints = []struct {
+	elevation float64
+	color     color.NRGBA
+}{
+	{0, color.NRGBA{R: 0x71, G: 0xab, B: 0x73, A: 255}},
+	{200, color.NRGBA{R: 0xa8, G: 0xc6, B: 0x8f, A: 255}},
+	{500, color.NRGBA{R: 0xe8, G: 0xe1, B: 0xa0, A: 255}},
+	{1000, color.NRGBA{R: 0xd9, G: 0xb2, B: 0x7c, A: 255}},
+	{1500, color.NRGBA{R: 0xb9, G: 0x85, B: 0x58, A: 255}},
+	{2000, color.NRGBA{R: 0x9c, G: 0x6a, B: 0x4e, A: 255}},
+	{3000, color.NRGBA{R: 0xdc, G: 0xdc, B: 0xdc, A: 255}},
+	{4000, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 255}},
+}
+
+func hypsometricTint(elevation float64) color.NRGBA {
+	i := 1
+	for i < len(hypsometricTints)-1 && elevation > hypsometricTints[i].elevation {
+		i++
+	}
+	from, to := hypsometricTints[i-1], hypsometricTints[i]
+	ratio := math.Max(0, math.Min(1, (elevation-from.elevation)/(to.elevation-from.elevation)))
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*ratio))
+	}
+	return color.NRGBA{R: mix(from.color.R, to.color.R), G: mix(from.color.G, to.color.G), B: mix(from.color.B, to.color.B), A: 255}
+}
+
+// relief is an elevation model shown on the map, with its shading computed
+// once.
+type relief struct {
+	path string
+	dem  *data.DEM
+	// projection is the coordinate system of the model
+	projection common.MapProjection
+	shade      []float32
+}
+
+func loadRelief(path string) (*relief, error) {
+	dem, err := data.LoadDEM(path)
+	if err != nil {
+		return nil, err
+	}
+	projection, ok := common.EPSGProjection(dem.EPSG)
+	if !ok {
+		return nil, fmt.Errorf("système de coordonnées EPSG:%d non pris en charge", dem.EPSG)
+	}
+	return &relief{
+		path:       path,
+		dem:        dem,
+		projection: projection,
+		shade:      dem.Hillshade(sunAzimuth, sunAltitude, reliefExaggeration),
+	}, nil
+}
+
+// pixel is the position in samples of the model of a longitude and a
+// latitude.
+func (r *relief) pixel(lon, lat float64) (float64, float64) {
+	return r.dem.Pixel(r.projection.Forward(lon, lat))
+}
+
+// elevationAt returns the elevation of the model at a longitude and a
+// latitude, NaN outside the model.
+func (r *relief) elevationAt(lon, lat float64) float64 {
+	return r.dem.ElevationAt(r.pixel(lon, lat))
+}
+
+// reliefLayer shades the relief, optionally over the hypsometric tints. Like
+// the interpolated grid, it is a surface whose value is added to the
+// tooltips by the map.
+type reliefLayer struct {
+	relief *relief
+	tints  bool
+}
+
+var _ ui.Layer = (*reliefLayer)(nil)
+
+func (l *reliefLayer) Draw(dc *gg.Context, v ui.Viewport) {
+	w, h := dc.Width(), dc.Height()
+	dem := l.relief.dem
+	positions := newScreenGrid(w, h, v, l.relief.pixel)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			col, row := positions.at(x, y)
+			light := data.SampleGrid(l.relief.shade, dem.Width, dem.Height, col, row)
+			if math.IsNaN(light) {
+				continue
+			}
+			c := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			if l.tints {
+				c = hypsometricTint(dem.ElevationAt(col, row))
+				// the tints stay readable in the shade
+				light = 0.4 + 0.6*light
+			}
+			i := img.PixOffset(x, y)
+			img.Pix[i] = uint8(float64(c.R) * light)
+			img.Pix[i+1] = uint8(float64(c.G) * light)
+			img.Pix[i+2] = uint8(float64(c.B) * light)
+			img.Pix[i+3] = 255
+		}
+	}
+	dc.DrawImage(img, 0, 0)
+}
+
+func (l *reliefLayer) HitTest(ui.Viewport, fyne.Position) (int, bool) {
+	return 0, false
+}
+
+func (l *reliefLayer) Tooltip(int) string {
+	return ""
+}
+
+func (l *reliefLayer) legend() *ui.Legend {
+	if !l.tints {
+		return nil
+	}
+	legend := &ui.Legend{Title: "Altitude"}
+	for _, tint := range hypsometricTints {
+		legend.Entries = append(legend.Entries, ui.LegendEntry{Color: tint.color, Label: fmt.Sprintf("%.0f m", tint.elevation)})
+	}
+	return legend
+}
+
+// refreshReliefLayer shows the relief above the base map and below every
+// other layer, or removes it when there is no elevation model.
+func (h *HomeMap) refreshReliefLayer() {
+	if h.relief == nil {
+		h.iMap.RemoveLayer(reliefLayerID)
+		return
+	}
+	_, shown := h.iMap.Layer(reliefLayerID)
+	layer := &reliefLayer{relief: h.relief, tints: h.reliefTints}
+	below := ""
+	for _, l := range h.iMap.Layers() {
+		if l.ID != baseMapLayerID && l.ID != reliefLayerID {
+			below = l.ID
+			break
+		}
+	}
+	h.iMap.SetLayerBelow(reliefLayerID, "Relief", layer, below).Legend = layer.legend()
+	if !shown {
+		h.iMap.SetLayerOpacity(reliefLayerID, reliefOpacity)
+	}
+	h.refreshLegend()
+}
+
+// setRelief reads the elevation model in the background, then shows its
+// relief; an empty path removes the relief. A model still being read when
+// another one is chosen is dropped.
+func (h *HomeMap) setRelief(path string) {
+	if h.reliefProgress != nil {
+		h.reliefProgress.Hide()
+		h.reliefProgress = nil
+	}
+	h.reliefPath = path
+	if path == "" {
+		h.reliefRequests.Cancel()
+		h.relief = nil
+		h.refreshReliefLayer()
+		return
+	}
+	progress := dialog.NewCustomWithoutButtons("Relief", container.NewVBox(
+		widget.NewLabel("Lecture du modèle numérique de terrain..."),
+		widget.NewProgressBarInfinite(),
+	), h.w)
+	progress.Show()
+	h.reliefProgress = progress
+
+	load(h.reliefRequests, func(context.Context) (*relief, error) {
+		return loadRelief(path)
+	}, func(relief *relief, err error) {
+		progress.Hide()
+		h.reliefProgress = nil
+		if err != nil {
+			// the relief shown, if any, stays
+			h.reliefPath = ""
+			if h.relief != nil {
+				h.reliefPath = h.relief.path
+			}
+			h.logger.Error("Can't read elevation model", "error", err, "filepath", path)
+			dialog.ShowError(fmt.Errorf("relief illisible : %w", err), h.w)
+			return
+		}
+		h.logger.Info("Elevation model loaded", "filepath", path, "width", relief.dem.Width, "height", relief.dem.Height, "min", relief.dem.Min, "max", relief.dem.Max)
+		h.relief = relief
+		h.refreshReliefLayer()
+	})
+}
@@ -0,0 +1,138 @@
+package home
+
+import (
+	"meteo/common"
+	"meteo/data"
+	"path/filepath"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	noFile       = "Aucun"
+	autoZoomName = "Automatique"
+)
+
+func (h *HomeMap) showMapSettingsForm() {
+	names := make([]string, 0, len(common.MapProjections))
+	for _, p := range common.MapProjections {
+		names = append(names, p.Name())
+	}
+	projection := widget.NewSelect(names, nil)
+	projection.SetSelected(h.projection.Name())
+
+	projectionItem := widget.NewFormItem("Projection", projection)
+	projectionItem.HintText = "Lambert-93 est la projection officielle de la France métropolitaine"
+
+	baseMapPath := h.baseMapPath
+	baseMapItem := widget.NewFormItem("Fond de carte", h.newFilePicker(&baseMapPath, ".mbtiles", ".pmtiles"))
+	baseMapItem.HintText = "Fichier MBTiles ou PMTiles de tuiles images, lu sans connexion"
+
+	minZoom, maxZoom := 0, data.MaxTileZoom
+	if h.baseMap != nil {
+		minZoom, maxZoom = h.baseMap.Source().Info().MinZoom, h.baseMap.Source().Info().MaxZoom
+	}
+	zoomNames := []string{autoZoomName}
+	for z := minZoom; z <= maxZoom; z++ {
+		zoomNames = append(zoomNames, strconv.Itoa(z))
+	}
+	zoom := widget.NewSelect(zoomNames, nil)
+	zoom.SetSelected(autoZoomName)
+	if h.baseMapZoom != autoTileZoom {
+		zoom.SetSelected(strconv.Itoa(h.baseMapZoom))
+	}
+	zoomItem := widget.NewFormItem("Niveau des tuiles", zoom)
+	zoomItem.HintText = "Automatique suit l'échelle de la carte"
+
+	reliefPath := h.reliefPath
+	reliefItem := widget.NewFormItem("Relief", h.newFilePicker(&reliefPath, ".tif", ".tiff"))
+	reliefItem.HintText = "Modèle numérique de terrain GeoTIFF (BD ALTI, SRTM…) en Lambert-93, Web Mercator ou WGS84"
+	tints := widget.NewCheck("Teintes hypsométriques", nil)
+	tints.SetChecked(h.reliefTints)
+
+	dialog.ShowForm("Paramètres de la carte", "Appliquer", "Annuler", []*widget.FormItem{
+		projectionItem,
+		baseMapItem,
+		zoomItem,
+		reliefItem,
+		widget.NewFormItem("", tints),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		zoomLevel := autoTileZoom
+		if z, err := strconv.Atoi(zoom.Selected); err == nil {
+			zoomLevel = z
+		}
+		if baseMapPath != h.baseMapPath {
+			h.baseMapZoom = zoomLevel
+			if err := h.setBaseMap(baseMapPath); err != nil {
+				h.logger.Error("Can't open base map", "error", err, "filepath", baseMapPath)
+				dialog.ShowError(err, h.w)
+			}
+		} else if zoomLevel != h.baseMapZoom {
+			h.baseMapZoom = zoomLevel
+			h.refreshBaseMapLayer()
+		}
+		if reliefPath != h.reliefPath {
+			h.reliefTints = tints.Checked
+			h.setRelief(reliefPath)
+		} else if tints.Checked != h.reliefTints {
+			h.reliefTints = tints.Checked
+			h.refreshReliefLayer()
+		}
+		if p, found := common.FindMapProjection(projection.Selected); found && p != h.projection {
+			h.setProjection(p)
+		}
+	}, h.w)
+}
+
+// newFilePicker shows the name of the file at path, with buttons to choose
+// another file with one of the extensions and to remove it.
+func (h *HomeMap) newFilePicker(path *string, extensions ...string) fyne.CanvasObject {
+	label := widget.NewLabel(fileName(*path))
+	label.Truncation = fyne.TextTruncateEllipsis
+	choose := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+		open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, h.w)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			// the file is read from its path, only as needed
+			reader.Close()
+			*path = reader.URI().Path()
+			label.SetText(fileName(*path))
+		}, h.w)
+		open.SetFilter(storage.NewExtensionFileFilter(extensions))
+		open.Show()
+	})
+	remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		*path = ""
+		label.SetText(fileName(*path))
+	})
+	return container.NewBorder(nil, nil, nil, container.NewHBox(choose, remove), label)
+}
+
+func fileName(path string) string {
+	if path == "" {
+		return noFile
+	}
+	return filepath.Base(path)
+}
+
+// setProjection draws the map with another projection, the camera going
+// back to the whole of France as the frame pixels changed.
+func (h *HomeMap) setProjection(p common.MapProjection) {
+	h.projection = p
+	h.frame = common.NewFrame(p, h.dimension, *h.geoData.Bounds)
+	h.ZoomToFrance()
+}
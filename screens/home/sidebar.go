@@ -1,50 +1,98 @@
 package home
 
 import (
+	"fmt"
+	"meteo/components/ui"
+	"meteo/data"
 	"strings"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
+// searchLimit is the number of stations listed for a query.
+const searchLimit = 50
+
 type HomeSidebar struct {
 	window               fyne.Window
-	stationList          binding.List[string]
+	index                *data.SearchIndex
+	results              []data.SearchResult
+	selected             int
+	search               *ui.SearchEntry
+	resultList           *widget.List
 	HandleLoadDepartment func(dpt string)
-	HandleSelectStation  func(name string)
+	HandleSelectStation  func(station *data.StationInfo)
+	HandleCompareStation func(station *data.StationInfo)
+	HandleShowAggregate  func(zone AggregateZone, weighting data.Weighting)
+	HandleOpenConsole    func()
+	HandleOpenAltitude   func()
+	HandleOpenRecords    func()
+}
+
+type AggregateZone struct {
+	Department string
+	Region     *data.Region
 }
 
-func InitHomeSidebar(window fyne.Window, stationList binding.List[string]) *HomeSidebar {
+func InitHomeSidebar(window fyne.Window) *HomeSidebar {
 	return &HomeSidebar{
-		window:      window,
-		stationList: stationList,
+		window:   window,
+		index:    data.NewSearchIndex(nil, nil),
+		selected: -1,
 	}
 }
 
-func (hs *HomeSidebar) Render() *fyne.Container {
-	selectStation := widget.NewSelectEntry([]string{})
-	if hs.stationList != nil {
-		hs.stationList.AddListener(binding.NewDataListener(func() {
-			stations, _ := hs.stationList.Get()
-			selectStation.SetOptions(stations)
-		}))
+// SetStations rebuilds the search index and refreshes the current results.
+func (hs *HomeSidebar) SetStations(stations []data.StationInfo, communes data.Communes) {
+	hs.index = data.NewSearchIndex(stations, communes)
+	if hs.search != nil {
+		hs.updateResults(hs.search.Text)
 	}
+}
 
-	selectStation.OnChanged = func(v string) {
-		stations, _ := hs.stationList.Get()
-		selectStation.SetOptions(findStationsByPrefix(stations, v))
-	}
+func (hs *HomeSidebar) Render() fyne.CanvasObject {
+	hs.search = ui.NewSearchEntry()
+	hs.search.SetPlaceHolder("Nom, commune, département ou numéro")
+	hs.search.OnChanged = hs.updateResults
+	hs.search.OnNavigate = hs.moveSelection
+	hs.search.OnEscape = func() { hs.search.SetText("") }
+	hs.search.OnSubmitted = func(string) { hs.openSelected() }
 
-	selectStation.OnSubmitted = func(v string) {
-		if hs.HandleSelectStation != nil {
-			hs.HandleSelectStation(v)
-		}
+	hs.resultList = widget.NewList(
+		func() int { return len(hs.results) },
+		func() fyne.CanvasObject {
+			name := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			name.Truncation = fyne.TextTruncateEllipsis
+			details := widget.NewLabel("")
+			details.SizeName = theme.SizeNameCaptionText
+			highlight := canvas.NewRectangle(theme.Color(theme.ColorNameSelection))
+			return container.NewStack(highlight, container.NewVBox(name, details))
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			station := hs.results[id].Station
+			objects := o.(*fyne.Container).Objects
+			highlight := objects[0].(*canvas.Rectangle)
+			highlight.FillColor = theme.Color(theme.ColorNameSelection)
+			highlight.Hidden = id != hs.selected
+			labels := objects[1].(*fyne.Container).Objects
+			labels[0].(*widget.Label).SetText(station.CommonName)
+			labels[1].(*widget.Label).SetText(stationDetails(station))
+		},
+	)
+	// the list selection is left empty so that tapping any row, the
+	// highlighted one included, opens its station
+	hs.resultList.OnSelected = func(id widget.ListItemID) {
+		hs.resultList.UnselectAll()
+		hs.selected = id
+		hs.resultList.Refresh()
+		hs.openSelected()
 	}
 
-	return container.NewVBox(
+	top := container.NewVBox(
 		widget.NewButton("Charger un département", func() {
 			entry := widget.NewEntry()
 			entry.SetPlaceHolder("Numéro du département (ex: 35)")
@@ -58,17 +106,122 @@ func (hs *HomeSidebar) Render() *fyne.Container {
 				}
 			}, hs.window)
 		}),
-		widget.NewLabel("Sélectionnez une station"),
-		selectStation,
+		widget.NewButton("Moyenne départementale / régionale", hs.showAggregateForm),
+		widget.NewLabel("Rechercher une station"),
+		hs.search,
+	)
+	bottom := container.NewVBox(
+		widget.NewButton("Ajouter à la comparaison", func() {
+			if station := hs.selectedStation(); station != nil && hs.HandleCompareStation != nil {
+				hs.HandleCompareStation(station)
+			}
+		}),
+		widget.NewButton("Relation avec l'altitude", func() {
+			if hs.HandleOpenAltitude != nil {
+				hs.HandleOpenAltitude()
+			}
+		}),
+		widget.NewButton("Records du jour", func() {
+			if hs.HandleOpenRecords != nil {
+				hs.HandleOpenRecords()
+			}
+		}),
+		widget.NewButton("Console SQL", func() {
+			if hs.HandleOpenConsole != nil {
+				hs.HandleOpenConsole()
+			}
+		}),
 	)
+	return container.NewBorder(top, bottom, nil, nil, hs.resultList)
+}
+
+func (hs *HomeSidebar) updateResults(query string) {
+	hs.results = hs.index.Search(query, searchLimit)
+	hs.selected = -1
+	if len(hs.results) > 0 {
+		hs.selected = 0
+	}
+	hs.resultList.ScrollToTop()
+	hs.resultList.Refresh()
+}
+
+// moveSelection highlights another result without opening it.
+func (hs *HomeSidebar) moveSelection(delta int) {
+	if len(hs.results) == 0 {
+		return
+	}
+	hs.selected = max(0, min(hs.selected+delta, len(hs.results)-1))
+	hs.resultList.ScrollTo(hs.selected)
+	hs.resultList.Refresh()
 }
 
-func findStationsByPrefix(stations []string, prefix string) []string {
-	matches := make([]string, 0, 10)
-	for _, station := range stations {
-		if strings.HasPrefix(station, prefix) {
-			matches = append(matches, station)
+func (hs *HomeSidebar) selectedStation() *data.StationInfo {
+	if hs.selected < 0 || hs.selected >= len(hs.results) {
+		return nil
+	}
+	return hs.results[hs.selected].Station
+}
+
+func (hs *HomeSidebar) openSelected() {
+	if station := hs.selectedStation(); station != nil && hs.HandleSelectStation != nil {
+		hs.HandleSelectStation(station)
+	}
+}
+
+func (hs *HomeSidebar) showAggregateForm() {
+	departments := make([]string, 0, len(data.Departments))
+	for _, d := range data.Departments {
+		departments = append(departments, departmentLabel(d))
+	}
+	regions := make([]string, 0, len(data.Regions))
+	for _, r := range data.Regions {
+		regions = append(regions, r.Name)
+	}
+
+	zone := widget.NewSelect(departments, nil)
+	kind := widget.NewRadioGroup([]string{"Département", "Région"}, func(v string) {
+		if v == "Région" {
+			zone.SetOptions(regions)
+		} else {
+			zone.SetOptions(departments)
 		}
+		zone.ClearSelected()
+	})
+	kind.Horizontal = true
+	kind.SetSelected("Département")
+
+	weightings := []data.Weighting{data.SimpleMean, data.ThiessenWeighted}
+	weightingLabels := make([]string, 0, len(weightings))
+	for _, w := range weightings {
+		weightingLabels = append(weightingLabels, w.String())
 	}
-	return matches
+	weighting := widget.NewSelect(weightingLabels, nil)
+	weighting.SetSelectedIndex(0)
+
+	dialog.ShowForm("Moyenne départementale / régionale", "Afficher", "Annuler", []*widget.FormItem{
+		widget.NewFormItem("Type", kind),
+		widget.NewFormItem("Zone", zone),
+		widget.NewFormItem("Pondération", weighting),
+	}, func(ok bool) {
+		if !ok || zone.SelectedIndex() < 0 || hs.HandleShowAggregate == nil {
+			return
+		}
+		selected := AggregateZone{}
+		if kind.Selected == "Région" {
+			selected.Region = &data.Regions[zone.SelectedIndex()]
+		} else {
+			selected.Department = data.Departments[zone.SelectedIndex()].Code
+		}
+		hs.HandleShowAggregate(selected, weightings[weighting.SelectedIndex()])
+	}, hs.window)
+}
+
+func departmentLabel(d data.Department) string {
+	return fmt.Sprintf("%s - %s", d.Code, d.Name)
+}
+
+// stationDetails describes where a station is, to tell homonyms apart.
+func stationDetails(station *data.StationInfo) string {
+	department := data.DepartmentOf(station.NumPost)
+	return fmt.Sprintf("%s (%s) · %.0f m · n° %s", data.DepartmentName(department), department, station.Alti, station.NumPost)
 }
@@ -0,0 +1,134 @@
+package home
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"meteo/data"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/fogleman/gg"
+)
+
+func droughtColor(c data.DroughtCategory) color.Color {
+	switch c {
+	case data.ExtremelyDry:
+		return color.NRGBA{R: 115, G: 0, B: 0, A: 255}
+	case data.SeverelyDry:
+		return color.NRGBA{R: 230, G: 0, B: 0, A: 255}
+	case data.ModeratelyDry:
+		return color.NRGBA{R: 255, G: 170, B: 0, A: 255}
+	case data.ModeratelyWet:
+		return color.NRGBA{R: 158, G: 224, B: 255, A: 255}
+	case data.VeryWet:
+		return color.NRGBA{R: 0, G: 160, B: 255, A: 255}
+	case data.ExtremelyWet:
+		return color.NRGBA{R: 0, G: 80, B: 200, A: 255}
+	default:
+		return color.NRGBA{R: 220, G: 220, B: 220, A: 255}
+	}
+}
+
+func (c *StationDetailsComponent) renderSPI(station *data.StationInfo) fyne.CanvasObject {
+	content := container.NewStack(widget.NewProgressBarInfinite())
+	load(newLoader(c.ctx), func(ctx context.Context) ([]data.SeriesPoint, error) {
+		return data.GetMonthlyRain(ctx, c.db, station)
+	}, func(monthly []data.SeriesPoint, err error) {
+		content.Objects = []fyne.CanvasObject{c.renderDroughts(station, monthly, err)}
+		content.Refresh()
+	})
+	return content
+}
+
+func (c *StationDetailsComponent) renderDroughts(station *data.StationInfo, monthly []data.SeriesPoint, err error) fyne.CanvasObject {
+	if err != nil {
+		c.logger.Error("Can't fetch monthly rain", "error", err, "station", station.NumPost)
+		return widget.NewLabel("Impossible de charger les cumuls mensuels")
+	}
+
+	labels := make([]string, 0, len(data.SPIScales))
+	for _, scale := range data.SPIScales {
+		labels = append(labels, spiLabel(scale))
+	}
+
+	latest := widget.NewLabel("")
+	chartContainer := container.NewStack()
+	timeline := canvas.NewImageFromImage(gg.NewContext(1, 1).Image())
+	timeline.FillMode = canvas.ImageFillStretch
+	timeline.ScaleMode = canvas.ImageScalePixels
+	timeline.SetMinSize(fyne.NewSize(400, 30))
+
+	selectScale := widget.NewSelect(labels, func(label string) {
+		for _, scale := range data.SPIScales {
+			if spiLabel(scale) != label {
+				continue
+			}
+			spi := data.ComputeSPI(monthly, scale)
+			chart, err := newSeriesChart(stationSeries{label: label, unit: "SPI", points: spi}, data.MonthlyPeriod)
+			if err != nil {
+				c.logger.Error("Can't build chart", "error", err, "series", label)
+				return
+			}
+			chartContainer.Objects = []fyne.CanvasObject{chart}
+			chartContainer.Refresh()
+
+			timeline.Image = renderDroughtTimeline(spi)
+			timeline.Refresh()
+
+			if len(spi) > 0 {
+				last := spi[len(spi)-1]
+				latest.SetText(fmt.Sprintf("Dernière valeur (%s) : %.2f - %s",
+					last.T.Format("01/2006"), last.Value, data.SPICategory(last.Value)))
+			} else {
+				latest.SetText("Pas assez de données pour calculer le SPI")
+			}
+		}
+	})
+	selectScale.SetSelectedIndex(0)
+
+	return container.NewBorder(
+		container.NewVBox(selectScale, latest),
+		container.NewVBox(timeline, droughtLegend()),
+		nil, nil,
+		chartContainer,
+	)
+}
+
+func spiLabel(scale int) string {
+	return fmt.Sprintf("SPI %d mois", scale)
+}
+
+// renderDroughtTimeline draws one vertical band per month coloured by its
+// drought category, from the first to the last month of the index. Months
+// without an index are left blank.
+func renderDroughtTimeline(spi []data.SeriesPoint) image.Image {
+	if len(spi) == 0 {
+		return gg.NewContext(1, 1).Image()
+	}
+	first := spi[0].T
+	month := func(t time.Time) int {
+		return (t.Year()-first.Year())*12 + int(t.Month()) - int(first.Month())
+	}
+	dc := gg.NewContext(month(spi[len(spi)-1].T)+1, 1)
+	for _, p := range spi {
+		dc.SetColor(droughtColor(data.SPICategory(p.Value)))
+		dc.SetPixel(month(p.T), 0)
+	}
+	return dc.Image()
+}
+
+func droughtLegend() fyne.CanvasObject {
+	legend := container.New(layout.NewGridLayout(4))
+	for _, category := range data.DroughtCategories {
+		swatch := canvas.NewRectangle(droughtColor(category))
+		swatch.SetMinSize(fyne.NewSize(12, 12))
+		legend.Add(container.NewHBox(container.NewCenter(swatch), widget.NewLabel(category.String())))
+	}
+	return legend
+}
@@ -1,42 +1,41 @@
 package home
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
 	"meteo/common"
-	"meteo/components/ui"
 	appcontext "meteo/context"
 	"meteo/data"
-	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
-	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
-	"github.com/s-daehling/fyne-charts/pkg/coord"
-	chartData "github.com/s-daehling/fyne-charts/pkg/data"
 )
 
 type StationDetailsComponent struct {
 	w           fyne.Window
 	db          *sql.DB
 	logger      *slog.Logger
+	ctx         context.Context
+	cancel      context.CancelFunc
 	camera      common.Position
 	dimension   common.Dimension
 	needRefresh binding.Bool
-	graphMode   binding.Int
 }
 
 func InitStationDetailsComponent(dimension common.Dimension) *StationDetailsComponent {
-	graphModeBinding := binding.NewInt()
-	graphModeBinding.Set(int(ui.NORMAL))
 	appContext := appcontext.GetAppContext()
+	ctx, cancel := context.WithCancel(context.Background())
 	return &StationDetailsComponent{
 		w:         appContext.W,
 		logger:    appContext.Logger,
 		db:        appContext.DB,
+		ctx:       ctx,
+		cancel:    cancel,
 		dimension: dimension,
 		camera: common.Position{
 			X: 0,
@@ -44,67 +43,127 @@ func InitStationDetailsComponent(dimension common.Dimension) *StationDetailsComp
 			Z: 1,
 		},
 		needRefresh: binding.NewBool(),
-		graphMode:   graphModeBinding,
 	}
 }
 
 func (c *StationDetailsComponent) Render(station *data.StationInfo) *fyne.Container {
-	chart := coord.NewCartesianTemporalChart("Rain")
-	chart.SetTAxisLabel("Year")
-	chart.SetYAxisLabel("mm")
+	tabs := container.NewAppTabs(
+		container.NewTabItem("Séries", c.renderSeries(station)),
+		container.NewTabItem("Cumul annuel", c.renderCumulative(station)),
+		container.NewTabItem("Sécheresse (SPI)", c.renderSPI(station)),
+		container.NewTabItem("Records", c.renderRecords(station)),
+	)
 
-	rainByYear, err := data.GetRainByStation(c.db, station.NumPost)
+	return container.NewStack(tabs)
+}
 
+// Close cancels the requests still running for the station.
+func (c *StationDetailsComponent) Close() {
+	c.cancel()
+}
+
+func (c *StationDetailsComponent) loadSeries(ctx context.Context, station *data.StationInfo, period data.Period) ([]stationSeries, error) {
+	rain, err := data.GetPeriodSeries(ctx, c.db, station, data.Rain, period)
 	if err != nil {
-		c.logger.Error(fmt.Sprint("Error while fetching data %w", err))
-		return nil
+		return nil, err
 	}
 
-	fmt.Println(len(rainByYear))
-
-	// serieData := make([]chartData.TemporalPoint, len(rainByYear))
-
-	// for i, record := range rainByYear {
-	// 	y, err := strconv.Atoi(record.Year)
-	// 	if err != nil {
-	// 		continue
-	// 	}
-	// 	serieData[i] = chartData.TemporalPoint{
-	// 		T:   time.Date(y, 0, 0, 0, 0, 0, 0, time.Local),
-	// 		Val: record.Rain,
-	// 	}
-	// }
-
-	tps, err := coord.NewTemporalPointSeries("rain", theme.ColorNamePrimary, []chartData.TemporalPoint{{
-		T:   time.Now().Add(time.Hour * 10),
-		Val: 1,
-	}, {
-		T:   time.Now().Add(time.Hour * 9),
-		Val: 2,
-	}, {
-		T:   time.Now().Add(time.Hour * 8),
-		Val: 3,
-	}})
+	temperature, err := data.GetPeriodSeries(ctx, c.db, station, data.MeanTemperature, period)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	err = chart.AddLineSeries(tps, true)
+	indices, err := data.GetClimateIndices(ctx, c.db, station, period)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	dataContainer := container.NewGridWithColumns(2)
-
-	for _, record := range rainByYear {
-		dataContainer.Add(widget.NewLabel(record.Year))
-		dataContainer.Add(widget.NewLabel(fmt.Sprintf("%.1f", record.Rain)))
+	series := []stationSeries{{
+		label:  data.Rain.String(),
+		unit:   data.Rain.Unit(),
+		points: rain,
+	}, {
+		label:  data.MeanTemperature.String(),
+		unit:   data.MeanTemperature.Unit(),
+		points: temperature,
+	}}
+	for _, info := range data.ClimateIndices {
+		series = append(series, stationSeries{
+			label:  fmt.Sprintf("%s - %s", info.Index, info.Label),
+			unit:   info.Unit,
+			points: data.ClimateIndexSeries(indices, info.Index),
+		})
 	}
+	return series, nil
+}
 
-	vbox := container.NewVBox(
-		// chart,
-		dataContainer,
+func (c *StationDetailsComponent) renderSeries(station *data.StationInfo) fyne.CanvasObject {
+	var (
+		period data.Period
+		series []stationSeries
 	)
 
-	return vbox
+	chartContainer := container.NewStack(widget.NewProgressBarInfinite())
+	dataContainer := container.NewGridWithColumns(2)
+	trendLabel := widget.NewLabel("")
+
+	selectSeries := widget.NewSelect(nil, func(label string) {
+		for _, s := range series {
+			if s.label != label {
+				continue
+			}
+			s.trend, _ = data.ComputeTrend(s.points)
+			trendLabel.SetText(trendSummary(s.trend, s.unit))
+
+			chart, err := newSeriesChart(s, period)
+			if err != nil {
+				c.logger.Error("Can't build chart", "error", err, "series", s.label)
+				return
+			}
+			chartContainer.Objects = []fyne.CanvasObject{chart}
+			chartContainer.Refresh()
+
+			dataContainer.Objects = nil
+			for _, p := range s.points {
+				dataContainer.Add(widget.NewLabel(period.Label(p.T)))
+				dataContainer.Add(widget.NewLabel(fmt.Sprintf("%.1f %s", p.Value, s.unit)))
+			}
+			dataContainer.Refresh()
+		}
+	})
+
+	requests := newLoader(c.ctx)
+	loadPeriod := func(p data.Period) {
+		trendLabel.SetText("Chargement des séries...")
+		load(requests, func(ctx context.Context) ([]stationSeries, error) {
+			return c.loadSeries(ctx, station, p)
+		}, func(loaded []stationSeries, err error) {
+			if err != nil {
+				c.logger.Error("Error while fetching data", "error", err, "station", station.NumPost, "period", p.String())
+				trendLabel.SetText("Impossible de charger les séries de la station")
+				dialog.ShowError(fmt.Errorf("impossible de charger les données de la station %s", station.CommonName), c.w)
+				return
+			}
+			period, series = p, loaded
+
+			labels := make([]string, 0, len(series))
+			for _, s := range series {
+				labels = append(labels, s.label)
+			}
+			selectSeries.Options = labels
+			if selectSeries.Selected == "" {
+				selectSeries.SetSelectedIndex(0)
+			} else {
+				selectSeries.OnChanged(selectSeries.Selected)
+			}
+		})
+	}
+	selectPeriod := newPeriodSelect(data.Periods, data.CalendarYearPeriod, loadPeriod)
+	loadPeriod(data.CalendarYearPeriod)
+
+	return container.NewBorder(
+		container.NewVBox(selectPeriod, selectSeries, trendLabel),
+		nil, nil, nil,
+		container.NewVSplit(chartContainer, container.NewVScroll(dataContainer)),
+	)
 }